@@ -3,6 +3,8 @@ package collector
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"github.com/DimKa163/go-metrics/internal/crypto"
 	"net/http"
@@ -11,28 +13,57 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/DimKa163/go-metrics/internal/certs"
 	"github.com/DimKa163/go-metrics/internal/client"
 	"github.com/DimKa163/go-metrics/internal/client/tripper"
 	"github.com/DimKa163/go-metrics/internal/models"
+	"github.com/DimKa163/go-metrics/internal/plugins"
 	"github.com/DimKa163/go-metrics/internal/runtime"
+	"github.com/DimKa163/go-metrics/internal/scrape"
+	"github.com/DimKa163/go-metrics/internal/tail"
+	"github.com/DimKa163/go-metrics/internal/telemetry"
 )
 
+const defaultHealthIntervals = 3
+
+// Source additional metric source running alongside runtime polling
+type Source interface {
+	Run(ctx context.Context) error
+}
+
 type Collector struct {
 	*Config
 	wg sync.WaitGroup
 	client.MetricClient
-	jobs chan *models.Metric
+	jobs    chan job
+	sources []Source
+	stats   *telemetry.Stats
+}
+
+// job metric queued for sending, report tracks completion of the report it belongs to
+type job struct {
+	metric *models.Metric
+	report *sync.WaitGroup
 }
 
 func NewCollector(conf *Config) (*Collector, error) {
 	tripperFc := []func(transport http.RoundTripper) http.RoundTripper{
-		func(transport http.RoundTripper) http.RoundTripper {
-			return tripper.NewRetryRoundTripper(transport)
-		},
 		func(transport http.RoundTripper) http.RoundTripper {
 			return tripper.NewGzip(transport)
 		},
 	}
+	if ip, err := tripper.OutboundIP(conf.Addr); err == nil {
+		tripperFc = append(tripperFc, func(transport http.RoundTripper) http.RoundTripper {
+			return tripper.NewRealIPTripper(transport, ip)
+		})
+	} else {
+		fmt.Printf("Failed to detect outbound address: %v\n", err)
+	}
+	if conf.Token != "" {
+		tripperFc = append(tripperFc, func(transport http.RoundTripper) http.RoundTripper {
+			return tripper.NewAuthTripper(transport, conf.Token)
+		})
+	}
 	if conf.Key != "" {
 		tripperFc = append(tripperFc, func(transport http.RoundTripper) http.RoundTripper {
 			return tripper.NewHashTripper(transport, conf.Key)
@@ -40,7 +71,7 @@ func NewCollector(conf *Config) (*Collector, error) {
 	}
 
 	if conf.PublicKeyFilePath != "" {
-		encrypter, err := crypto.NewEncrypter(conf.PublicKeyFilePath)
+		encrypter, err := crypto.NewEncrypter(conf.PublicKeyFilePath, conf.CryptoKeyID)
 		if err != nil {
 			return nil, err
 		}
@@ -48,7 +79,54 @@ func NewCollector(conf *Config) (*Collector, error) {
 			return tripper.NewCryptoTripper(transport, encrypter)
 		})
 	}
-	return &Collector{Config: conf, MetricClient: client.NewClient(fmt.Sprintf("http://%s", conf.Addr), tripperFc)}, nil
+	// retry is outermost, so every attempt is signed and encrypted anew
+	tripperFc = append(tripperFc, func(transport http.RoundTripper) http.RoundTripper {
+		return tripper.NewRetryRoundTripper(transport)
+	})
+	scheme := "http"
+	var tlsConfig *tls.Config
+	if conf.TLSCertFile != "" || conf.TLSCAFile != "" {
+		reloader, err := certs.NewReloader(conf.TLSCertFile, conf.TLSKeyFile, conf.TLSCAFile)
+		if err != nil {
+			return nil, err
+		}
+		scheme = "https"
+		tlsConfig = reloader.ClientConfig()
+	}
+	stats := telemetry.NewStats()
+	metricClient := client.NewClient(fmt.Sprintf("%s://%s", scheme, conf.Addr), tlsConfig, tripperFc)
+	c := &Collector{
+		Config:       conf,
+		MetricClient: telemetry.NewClient(metricClient, stats),
+		stats:        stats,
+	}
+	for _, target := range conf.Scrape {
+		scraper, err := scrape.NewScraper(target, c.MetricClient)
+		if err != nil {
+			return nil, err
+		}
+		c.sources = append(c.sources, scraper)
+	}
+	limiter := plugins.NewLimiter(conf.PluginConcurrency)
+	for _, plugin := range conf.Plugins {
+		runner, err := plugins.NewRunner(plugin, c.MetricClient, limiter)
+		if err != nil {
+			return nil, err
+		}
+		c.sources = append(c.sources, runner)
+	}
+	if len(conf.Tail) > 0 {
+		tailer, err := tail.NewTailer(conf.Tail, c.MetricClient, tail.Options{
+			StatePath:      conf.TailStatePath,
+			PollInterval:   time.Duration(conf.PollInterval) * time.Second,
+			ReportInterval: time.Duration(conf.ReportInterval) * time.Second,
+		})
+		if err != nil {
+			return nil, err
+		}
+		c.sources = append(c.sources, tailer)
+	}
+	return c, nil
 }
 
 // Run worker
@@ -57,11 +135,25 @@ func (c *Collector) Run(buildVersion string, buildDate string, buildCommit strin
 	defer cancel()
 	var count int64
 	values := make(map[string]float64)
-	c.jobs = make(chan *models.Metric, c.Limit*4)
+	c.jobs = make(chan job, c.Limit*4)
+	c.stats.SetQueueDepth(func() int { return len(c.jobs) })
+	if c.TelemetryAddr != "" {
+		intervals := c.HealthIntervals
+		if intervals <= 0 {
+			intervals = defaultHealthIntervals
+		}
+		server := telemetry.NewServer(c.TelemetryAddr, c.stats, time.Duration(intervals*c.ReportInterval)*time.Second)
+		if err := server.Start(ctx); err != nil {
+			return err
+		}
+	}
 	var err error
 	for i := 0; i < c.Limit; i++ {
 		go c.worker()
 	}
+	for _, source := range c.sources {
+		go c.runSource(ctx, source)
+	}
 	pollTicker := time.NewTicker(time.Duration(c.PollInterval) * time.Second)
 	reportTicker := time.NewTicker(time.Duration(c.ReportInterval) * time.Second)
 	printBuildInfo(buildVersion, buildDate, buildCommit)
@@ -72,6 +164,7 @@ func (c *Collector) Run(buildVersion string, buildDate string, buildCommit strin
 			close(c.jobs)
 			return ctx.Err()
 		case <-pollTicker.C:
+			startTime := time.Now()
 			err = runtime.ReadMemoryStats(values)
 			if err != nil {
 				fmt.Printf("Error reading stats: %v\n", err)
@@ -83,39 +176,60 @@ func (c *Collector) Run(buildVersion string, buildDate string, buildCommit strin
 				continue
 			}
 			count++
+			c.stats.ObservePoll(time.Since(startTime))
 		case <-reportTicker.C:
+			startTime := time.Now()
+			report := &sync.WaitGroup{}
 			for k, v := range values {
-				c.wg.Add(1)
-				c.jobs <- models.CreateGauge(k, v)
+				c.enqueue(models.CreateGauge(k, v), report)
 			}
-			c.wg.Add(1)
-			c.jobs <- models.CreateCounter("PollCount", count)
-
+			c.enqueue(models.CreateCounter("PollCount", count), report)
+			go func() {
+				report.Wait()
+				c.stats.ObserveReport(time.Since(startTime))
+			}()
 		}
 	}
 }
 
+func (c *Collector) enqueue(metric *models.Metric, report *sync.WaitGroup) {
+	c.wg.Add(1)
+	report.Add(1)
+	c.jobs <- job{metric: metric, report: report}
+}
+
 func (c *Collector) worker() {
-	for metric := range c.jobs {
-		if metric == nil {
-			continue
-		}
-		fmt.Println(metric)
-		if metric.Type == models.CounterType {
-			if err := c.UpdateCounter(metric.ID, *metric.Delta); err != nil {
-				fmt.Println(err)
-				continue
-			}
-		} else if metric.Type == models.GaugeType {
-			if err := c.UpdateGauge(metric.ID, *metric.Value); err != nil {
-				fmt.Println(err)
-				continue
-			}
-		}
+	for j := range c.jobs {
+		c.send(j.metric)
+		j.report.Done()
 		c.wg.Done()
 	}
 }
 
+func (c *Collector) send(metric *models.Metric) {
+	if metric == nil {
+		return
+	}
+	fmt.Println(metric)
+	var err error
+	switch metric.Type {
+	case models.CounterType:
+		err = c.UpdateCounter(metric.ID, *metric.Delta)
+	case models.GaugeType:
+		err = c.UpdateGauge(metric.ID, *metric.Value)
+	}
+	if err != nil {
+		fmt.Println(err)
+		c.stats.Drop(1)
+	}
+}
+
+func (c *Collector) runSource(ctx context.Context, source Source) {
+	if err := source.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+		fmt.Printf("Source stopped: %v\n", err)
+	}
+}
+
 func printBuildInfo(buildVersion string, buildDate string, buildCommit string) {
 	fmt.Printf("Build version: %s\n", ifNan(buildVersion))
 	fmt.Printf("Build date: %s\n", ifNan(buildDate))
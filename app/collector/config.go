@@ -1,10 +1,31 @@
 package collector
 
+import (
+	"github.com/DimKa163/go-metrics/internal/plugins"
+	"github.com/DimKa163/go-metrics/internal/scrape"
+	"github.com/DimKa163/go-metrics/internal/tail"
+)
+
 type Config struct {
 	Addr              string `arg:"a" envArg:"ADDRESS" json:"address"`
 	ReportInterval    int    `arg:"r" envArg:"REPORT_INTERVAL" json:"report_interval"`
 	PollInterval      int    `arg:"p" envArg:"POLL_INTERVAL" json:"poll_interval"`
 	Key               string `arg:"k" envArg:"KEY" json:"key"`
+	Token             string `arg:"token" envArg:"TOKEN" json:"token"`
 	Limit             int    `arg:"r" envArg:"RATE_LIMIT" json:"rate_limit"`
 	PublicKeyFilePath string `arg:"c" envArg:"CRYPTO_KEY" json:"crypto_key"`
+	CryptoKeyID       string `arg:"crypto-key-id" envArg:"CRYPTO_KEY_ID" json:"crypto_key_id"`
+	PluginConcurrency int    `arg:"plugin-concurrency" envArg:"PLUGIN_CONCURRENCY" json:"plugin_concurrency"`
+	TailStatePath     string `arg:"tail-state" envArg:"TAIL_STATE_PATH" json:"tail_state"`
+	TelemetryAddr     string `arg:"telemetry" envArg:"TELEMETRY_ADDRESS" json:"telemetry_address"`
+	HealthIntervals   int    `arg:"health-intervals" envArg:"HEALTH_INTERVALS" json:"health_intervals"`
+	TLSCertFile       string `arg:"tls-cert" envArg:"TLS_CERT" json:"tls_cert"`
+	TLSKeyFile        string `arg:"tls-key" envArg:"TLS_KEY" json:"tls_key"`
+	TLSCAFile         string `arg:"tls-ca" envArg:"TLS_CA" json:"tls_ca"`
+	// Scrape Prometheus endpoints bridged into keeper, configured through config file only
+	Scrape []scrape.Target `json:"scrape"`
+	// Plugins external commands run on schedule, configured through config file only
+	Plugins []plugins.Plugin `json:"plugins"`
+	// Tail log files turned into counters and gauges, configured through config file only
+	Tail []tail.File `json:"tail"`
 }
@@ -8,5 +8,37 @@ type Config struct {
 	LogLevel           string `arg:"l" envArg:"LOG_LEVEL"`
 	DatabaseDSN        string `arg:"d" envArg:"DATABASE_DSN" json:"database_dsn"`
 	Key                string `arg:"k" envArg:"KEY" json:"key"`
+	SignatureSkew      int64  `arg:"signature-skew" envArg:"SIGNATURE_SKEW" json:"signature_skew"`
+	NonceCacheSize     int    `arg:"nonce-cache" envArg:"NONCE_CACHE_SIZE" json:"nonce_cache_size"`
 	PrivateKeyFilePath string `arg:"c" envArg:"CRYPTO_KEY" json:"crypto_key"`
+	PushGroupTTL       int64  `arg:"push-ttl" envArg:"PUSH_GROUP_TTL" json:"push_group_ttl"`
+	TLSCertFile        string `arg:"tls-cert" envArg:"TLS_CERT" json:"tls_cert"`
+	TLSKeyFile         string `arg:"tls-key" envArg:"TLS_KEY" json:"tls_key"`
+	TLSClientCAFile    string `arg:"tls-client-ca" envArg:"TLS_CLIENT_CA" json:"tls_client_ca"`
+	TrustedSubnet      string `arg:"t" envArg:"TRUSTED_SUBNET" json:"trusted_subnet"`
+	TrustedReadSubnet  string `arg:"trusted-read-subnet" envArg:"TRUSTED_READ_SUBNET" json:"trusted_read_subnet"`
+	TrustedProxies     string `arg:"trusted-proxies" envArg:"TRUSTED_PROXIES" json:"trusted_proxies"`
+	RequestRate        int    `arg:"request-rate" envArg:"REQUEST_RATE" json:"request_rate"`
+	RequestBurst       int    `arg:"request-burst" envArg:"REQUEST_BURST" json:"request_burst"`
+	MetricRate         int    `arg:"metric-rate" envArg:"METRIC_RATE" json:"metric_rate"`
+	MetricBurst        int    `arg:"metric-burst" envArg:"METRIC_BURST" json:"metric_burst"`
+	MetricNamePattern  string `arg:"metric-name-pattern" envArg:"METRIC_NAME_PATTERN" json:"metric_name_pattern"`
+	MetricNameMax      int    `arg:"metric-name-max" envArg:"METRIC_NAME_MAX" json:"metric_name_max"`
+	MetricAllow        string `arg:"metric-allow" envArg:"METRIC_ALLOW" json:"metric_allow"`
+	MetricBlock        string `arg:"metric-block" envArg:"METRIC_BLOCK" json:"metric_block"`
+	MaxTenantMetrics   int    `arg:"max-tenant-metrics" envArg:"MAX_TENANT_METRICS" json:"max_tenant_metrics"`
+	MaxAgentMetrics    int    `arg:"max-agent-metrics" envArg:"MAX_AGENT_METRICS" json:"max_agent_metrics"`
+	AuditFile          string `arg:"audit-file" envArg:"AUDIT_FILE" json:"audit_file"`
+	AuditFileSize      int64  `arg:"audit-file-size" envArg:"AUDIT_FILE_SIZE" json:"audit_file_size"`
+	AuditFileBackups   int    `arg:"audit-file-backups" envArg:"AUDIT_FILE_BACKUPS" json:"audit_file_backups"`
+	AuditURL           string `arg:"audit-url" envArg:"AUDIT_URL" json:"audit_url"`
+	AuditQueue         int    `arg:"audit-queue" envArg:"AUDIT_QUEUE" json:"audit_queue"`
+	HistorySize        int    `arg:"history-size" envArg:"HISTORY_SIZE" json:"history_size"`
+	MetricTTL          int64  `arg:"metric-ttl" envArg:"METRIC_TTL" json:"metric_ttl"`
+	MetricGC           int64  `arg:"metric-gc" envArg:"METRIC_GC" json:"metric_gc"`
+	StreamBuffer       int    `arg:"stream-buffer" envArg:"STREAM_BUFFER" json:"stream_buffer"`
+	IdempotencyTTL     int64  `arg:"idempotency-ttl" envArg:"IDEMPOTENCY_TTL" json:"idempotency_ttl"`
+	IdempotencyCache   int    `arg:"idempotency-cache" envArg:"IDEMPOTENCY_CACHE_SIZE" json:"idempotency_cache_size"`
+	AdminToken         string `arg:"admin-token" envArg:"ADMIN_TOKEN" json:"admin_token"`
+	TokensPath         string `arg:"tokens-file" envArg:"TOKENS_FILE" json:"tokens_file"`
 }
@@ -4,14 +4,18 @@ package keeper
 import (
 	"context"
 	"fmt"
+	"github.com/DimKa163/go-metrics/internal/certs"
 	"github.com/DimKa163/go-metrics/internal/crypto"
 	swaggerFiles "github.com/swaggo/files"
 	"net/http"
+	"os"
 	"os/signal"
+	"regexp"
 	"syscall"
 	"time"
 
 	docs "github.com/DimKa163/go-metrics/docs"
+	"github.com/DimKa163/go-metrics/internal/audit"
 	"github.com/DimKa163/go-metrics/internal/files"
 	"github.com/DimKa163/go-metrics/internal/logging"
 	"github.com/DimKa163/go-metrics/internal/mhttp/controllers"
@@ -19,6 +23,10 @@ import (
 	"github.com/DimKa163/go-metrics/internal/persistence"
 	"github.com/DimKa163/go-metrics/internal/persistence/mem"
 	"github.com/DimKa163/go-metrics/internal/persistence/pg"
+	"github.com/DimKa163/go-metrics/internal/query"
+	"github.com/DimKa163/go-metrics/internal/ratelimit"
+	"github.com/DimKa163/go-metrics/internal/signature"
+	"github.com/DimKa163/go-metrics/internal/stream"
 	"github.com/DimKa163/go-metrics/internal/tasks"
 	"github.com/DimKa163/go-metrics/internal/usecase"
 	"github.com/gin-contrib/pprof"
@@ -33,9 +41,23 @@ type ServiceContainer struct {
 	filer            *files.Filer
 	pg               *pgxpool.Pool
 	repository       persistence.Repository
+	snapshotter      persistence.Snapshotter
 	metricController controllers.Metrics
+	metricsV2        controllers.MetricsV2
+	pushController   controllers.Push
+	tokenController  controllers.Tokens
+	queryController  controllers.Query
+	streamController controllers.Stream
+	dashboard        controllers.Dashboard
+	transfer         controllers.Transfer
+	health           controllers.Health
 	dumpTask         *tasks.DumpTask
+	expiryTask       *tasks.GroupExpiryTask
+	metricExpiryTask *tasks.MetricExpiryTask
+	idempotencyTask  *tasks.IdempotencyPurgeTask
 	crypto           *crypto.Decrypter
+	certs            *certs.Reloader
+	auditor          *audit.Auditor
 }
 
 type Server struct {
@@ -48,11 +70,16 @@ type Server struct {
 
 func New(config *Config) (*Server, error) {
 	var repository persistence.Repository
+	var snapshotter persistence.Snapshotter
+	var tenants persistence.TenantLister
+	var tokenRepository persistence.TokenRepository
+	var idempotencyRepository persistence.IdempotencyRepository
 	var err error
 	var pgConnection *pgxpool.Pool
 	var useDumpASYNC bool
 	var useBackup bool
 	var decrypter *crypto.Decrypter
+	var reloader *certs.Reloader
 	attempts := []int{1, 3, 5}
 	filer := files.NewFiler(config.Path, attempts)
 
@@ -61,12 +88,16 @@ func New(config *Config) (*Server, error) {
 		if err != nil {
 			return nil, err
 		}
-		repository, err = pg.NewStore(pgConnection, attempts)
+		store, err := pg.NewStore(pgConnection, attempts)
 		if err != nil {
 			return nil, err
 		}
+		repository = store
+		tenants = store
+		tokenRepository = store
+		idempotencyRepository = store
 	} else {
-		repository, err = mem.NewStore(filer, mem.StoreOption{
+		store, err := mem.NewStore(filer, mem.StoreOption{
 			UseSYNC: config.StoreInterval == 0,
 			Restore: config.Restore,
 		})
@@ -74,6 +105,16 @@ func New(config *Config) (*Server, error) {
 		if err != nil {
 			return nil, err
 		}
+		repository = store
+		snapshotter = store
+		tenants = store
+		if config.AdminToken != "" {
+			tokenRepository, err = mem.NewTokenStore(config.TokensPath)
+			if err != nil {
+				return nil, err
+			}
+		}
+		idempotencyRepository = mem.NewIdempotencyStore(config.IdempotencyCache)
 		useDumpASYNC = config.StoreInterval > 0
 		useBackup = true
 	}
@@ -84,33 +125,120 @@ func New(config *Config) (*Server, error) {
 			return nil, err
 		}
 	}
+	server := &http.Server{
+		Addr: config.Addr,
+	}
+	if config.TLSCertFile != "" {
+		reloader, err = certs.NewReloader(config.TLSCertFile, config.TLSKeyFile, config.TLSClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		server.TLSConfig, err = reloader.ServerConfig()
+		if err != nil {
+			return nil, err
+		}
+	}
 	if err = logging.Initialize(config.LogLevel); err != nil {
 		return nil, err
 	}
+	policy, err := subnetPolicy(config)
+	if err != nil {
+		return nil, err
+	}
+	namePolicy, err := metricPolicy(config)
+	if err != nil {
+		return nil, err
+	}
+	auditor, err := newAuditor(config)
+	if err != nil {
+		return nil, err
+	}
 	router := gin.New()
+	// tenant set by authentication lives in request context
+	router.ContextWithFallback = true
 	router.Use(gin.Recovery())
 	router.Use(middleware.LoggingMiddleware())
+	router.Use(middleware.TrustedSubnet(policy))
+	var tokenController controllers.Tokens
+	if config.AdminToken != "" {
+		tokenService := usecase.NewTokenService(tokenRepository, config.AdminToken)
+		router.Use(middleware.Authenticate(tokenService, "/ping", "/healthz"))
+		tokenController = controllers.NewTokenController(tokenService)
+	}
+	router.Use(middleware.Identify(policy.Proxies))
+	if config.RequestRate > 0 {
+		router.Use(middleware.RequestLimit(ratelimit.NewLimiter(config.RequestRate, config.RequestBurst), policy.Proxies))
+	}
 	router.Use(middleware.GzipMiddleware())
+	// agent signs encrypted body, so signature is checked before decryption
+	if config.Key != "" {
+		guard := signature.NewReplayGuard(time.Duration(config.SignatureSkew)*time.Second, config.NonceCacheSize)
+		router.Use(middleware.Hash(config.Key, guard))
+	}
 	if decrypter != nil {
 		router.Use(middleware.CryptoMiddleware(decrypter))
 	}
-	if config.Key != "" {
-		router.Use(middleware.Hash(config.Key))
+	if config.MetricRate > 0 {
+		router.Use(middleware.MetricLimit(ratelimit.NewLimiter(config.MetricRate, config.MetricBurst), policy.Proxies))
+	}
+	idempotencyService := usecase.NewIdempotencyService(idempotencyRepository, time.Duration(config.IdempotencyTTL)*time.Second)
+	if config.IdempotencyTTL > 0 {
+		router.Use(middleware.Idempotency(idempotencyService))
+	}
+	// nil *audit.Auditor must not become non-nil interface
+	var recorder usecase.Auditor
+	var auditStats controllers.AuditStats
+	if auditor != nil {
+		recorder = auditor
+		auditStats = auditor
 	}
+	// nil *pgxpool.Pool must not become non-nil interface either
+	var pinger controllers.Pinger
+	if pgConnection != nil {
+		pinger = pgConnection
+	}
+	hub := stream.NewHub(config.StreamBuffer)
+	// streaming requests never finish by themselves, end them when shutdown begins
+	server.RegisterOnShutdown(hub.Close)
+	var history *query.History
+	if config.HistorySize > 0 {
+		history = query.NewHistory(config.HistorySize)
+	}
+	metricService := usecase.NewMetricService(repository, usecase.MetricOptions{
+		Policy:    namePolicy,
+		Auditor:   recorder,
+		History:   history,
+		Publisher: hub,
+		TTL:       time.Duration(config.MetricTTL) * time.Second,
+		GC:        time.Duration(config.MetricGC) * time.Second,
+	})
+	pushService := usecase.NewPushService(metricService, tenants)
+	server.Handler = router.Handler()
 	return &Server{
 		ServiceContainer: &ServiceContainer{
 			conf:             config,
 			pg:               pgConnection,
 			filer:            filer,
 			repository:       repository,
-			metricController: controllers.NewMetricController(usecase.NewMetricService(repository)),
-			dumpTask:         tasks.NewDumpTask(repository, filer, time.Duration(config.StoreInterval)*time.Second),
+			snapshotter:      snapshotter,
+			metricController: controllers.NewMetricController(metricService),
+			metricsV2:        controllers.NewMetricV2Controller(metricService),
+			pushController:   controllers.NewPushController(pushService),
+			tokenController:  tokenController,
+			queryController:  controllers.NewQueryController(metricService),
+			streamController: controllers.NewStreamController(hub),
+			dashboard:        controllers.NewDashboardController(metricService),
+			transfer:         controllers.NewTransferController(metricService),
+			health:           controllers.NewHealthController(pinger, auditStats),
+			dumpTask:         tasks.NewDumpTask(snapshotter, filer, time.Duration(config.StoreInterval)*time.Second),
+			expiryTask:       tasks.NewGroupExpiryTask(pushService, time.Duration(config.PushGroupTTL)*time.Second),
+			metricExpiryTask: tasks.NewMetricExpiryTask(metricService, time.Duration(config.MetricTTL)*time.Second),
+			idempotencyTask:  tasks.NewIdempotencyPurgeTask(idempotencyService, time.Duration(config.IdempotencyTTL)*time.Second),
 			crypto:           decrypter,
+			certs:            reloader,
+			auditor:          auditor,
 		},
-		Server: &http.Server{
-			Addr:    config.Addr,
-			Handler: router.Handler(),
-		},
+		Server:       server,
 		useDumpASYNC: useDumpASYNC,
 		Engine:       router,
 		useBackup:    useBackup,
@@ -131,6 +259,16 @@ func (s *Server) Map() {
 		c.String(http.StatusOK, "pong")
 	})
 	s.metricController.Map(s.Engine)
+	s.metricsV2.Map(s.Engine)
+	s.pushController.Map(s.Engine)
+	s.queryController.Map(s.Engine)
+	s.streamController.Map(s.Engine)
+	s.dashboard.Map(s.Engine)
+	s.transfer.Map(s.Engine)
+	s.health.Map(s.Engine)
+	if s.tokenController != nil {
+		s.tokenController.Map(s.Engine)
+	}
 }
 
 // Run app
@@ -140,6 +278,21 @@ func (s *Server) Run(buildVersion string, buildDate string, buildCommit string)
 	if s.useDumpASYNC {
 		s.dumpTask.Start(ctx)
 	}
+	if s.conf.PushGroupTTL > 0 {
+		s.expiryTask.Start(ctx)
+	}
+	if s.conf.MetricTTL > 0 {
+		s.metricExpiryTask.Start(ctx)
+	}
+	if s.conf.IdempotencyTTL > 0 {
+		s.idempotencyTask.Start(ctx)
+	}
+	if s.crypto != nil || s.certs != nil {
+		go s.reloadOnHangup(ctx)
+	}
+	if s.auditor != nil {
+		s.auditor.Start()
+	}
 	go func() {
 		<-ctx.Done()
 		timeoutCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
@@ -150,11 +303,106 @@ func (s *Server) Run(buildVersion string, buildDate string, buildCommit string)
 			}
 		}
 		_ = s.Server.Shutdown(timeoutCtx)
+		if s.auditor != nil {
+			if err := s.auditor.Close(timeoutCtx); err != nil {
+				logging.Log.Error("failed to flush audit log", zap.Error(err))
+			}
+		}
 	}()
 	printBuildInfo(buildVersion, buildDate, buildCommit)
+	if s.certs != nil {
+		// certificates come from TLSConfig
+		return s.ListenAndServeTLS("", "")
+	}
 	return s.ListenAndServe()
 }
 
+func (s *Server) reloadOnHangup(ctx context.Context) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			if s.crypto != nil {
+				if err := s.crypto.Reload(); err != nil {
+					logging.Log.Error("failed to reload crypto keys", zap.Error(err))
+				} else {
+					logging.Log.Info("crypto keys reloaded")
+				}
+			}
+			if s.certs != nil {
+				if err := s.certs.Reload(); err != nil {
+					logging.Log.Error("failed to reload TLS certificates", zap.Error(err))
+				} else {
+					logging.Log.Info("TLS certificates reloaded")
+				}
+			}
+		}
+	}
+}
+
+func subnetPolicy(config *Config) (middleware.SubnetPolicy, error) {
+	var policy middleware.SubnetPolicy
+	var err error
+	if policy.Write, err = middleware.ParseSubnets(config.TrustedSubnet); err != nil {
+		return policy, fmt.Errorf("trusted subnet: %w", err)
+	}
+	if policy.Read, err = middleware.ParseSubnets(config.TrustedReadSubnet); err != nil {
+		return policy, fmt.Errorf("trusted read subnet: %w", err)
+	}
+	if policy.Proxies, err = middleware.ParseSubnets(config.TrustedProxies); err != nil {
+		return policy, fmt.Errorf("trusted proxies: %w", err)
+	}
+	return policy, nil
+}
+
+func metricPolicy(config *Config) (usecase.MetricPolicy, error) {
+	policy := usecase.MetricPolicy{
+		MaxNameLength:    config.MetricNameMax,
+		MaxTenantMetrics: config.MaxTenantMetrics,
+		MaxAgentMetrics:  config.MaxAgentMetrics,
+	}
+	var err error
+	if config.MetricNamePattern != "" {
+		if policy.NamePattern, err = regexp.Compile(config.MetricNamePattern); err != nil {
+			return policy, fmt.Errorf("metric name pattern: %w", err)
+		}
+	}
+	if policy.Allow, err = usecase.ParseNamePatterns(config.MetricAllow); err != nil {
+		return policy, fmt.Errorf("metric allow list: %w", err)
+	}
+	if policy.Block, err = usecase.ParseNamePatterns(config.MetricBlock); err != nil {
+		return policy, fmt.Errorf("metric block list: %w", err)
+	}
+	// longer ids fail on insert
+	if config.DatabaseDSN != "" && (policy.MaxNameLength <= 0 || policy.MaxNameLength > pg.MaxIDLength) {
+		policy.MaxNameLength = pg.MaxIDLength
+	}
+	return policy, nil
+}
+
+// newAuditor auditor writing to configured sinks, nil when audit is disabled
+func newAuditor(config *Config) (*audit.Auditor, error) {
+	var sinks []audit.Sink
+	if config.AuditFile != "" {
+		sink, err := audit.NewFileSink(config.AuditFile, config.AuditFileSize<<20, config.AuditFileBackups)
+		if err != nil {
+			return nil, fmt.Errorf("audit file: %w", err)
+		}
+		sinks = append(sinks, sink)
+	}
+	if config.AuditURL != "" {
+		sinks = append(sinks, audit.NewHTTPSink(config.AuditURL, 10*time.Second))
+	}
+	if len(sinks) == 0 {
+		return nil, nil
+	}
+	return audit.NewAuditor(config.AuditQueue, sinks...), nil
+}
+
 func printBuildInfo(buildVersion string, buildDate string, buildCommit string) {
 	fmt.Printf("Build version: %s\n", ifNan(buildVersion))
 	fmt.Printf("Build date: %s\n", ifNan(buildDate))
@@ -170,9 +418,9 @@ func ifNan(value string) string {
 
 func (s *Server) backup(ctx context.Context) error {
 	logging.Log.Info("start backup before shutdown")
-	m, err := s.repository.GetAll(ctx)
+	tenants, err := s.snapshotter.Snapshot(ctx)
 	if err != nil {
 		return err
 	}
-	return s.filer.Dump(m)
+	return s.filer.DumpTenants(tenants)
 }
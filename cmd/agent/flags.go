@@ -14,11 +14,29 @@ func ParseFlags(config *collector.Config) {
 	environment.BindIntEnv("POLL_INTERVAL")
 	environment.BindStringArg("k", "", "key")
 	environment.BindStringEnv("KEY")
+	environment.BindStringArg("token", "", "tenant API token")
+	environment.BindStringEnv("TOKEN")
 	environment.BindIntArg("l", 4, "rate limit")
 	environment.BindIntEnv("RATE_LIMIT")
 	environment.BindStringArg("c", "", "config")
 	environment.BindStringEnv("CONFIG")
 	environment.BindStringArg("crypto-key", "", "crypto key")
 	environment.BindStringEnv("CRYPTO_KEY")
+	environment.BindStringArg("crypto-key-id", "", "pinned encryption key id")
+	environment.BindStringEnv("CRYPTO_KEY_ID")
+	environment.BindIntArg("plugin-concurrency", 0, "max plugin commands running at once, 0 is unlimited")
+	environment.BindIntEnv("PLUGIN_CONCURRENCY")
+	environment.BindStringArg("tail-state", "", "file storing log tail offsets")
+	environment.BindStringEnv("TAIL_STATE_PATH")
+	environment.BindStringArg("telemetry", "", "agent telemetry listener address, disabled when empty")
+	environment.BindStringEnv("TELEMETRY_ADDRESS")
+	environment.BindIntArg("health-intervals", 3, "report intervals without successful send before agent is unhealthy")
+	environment.BindIntEnv("HEALTH_INTERVALS")
+	environment.BindStringArg("tls-cert", "", "client certificate presented to keeper, reloaded when file changes")
+	environment.BindStringEnv("TLS_CERT")
+	environment.BindStringArg("tls-key", "", "client certificate key")
+	environment.BindStringEnv("TLS_KEY")
+	environment.BindStringArg("tls-ca", "", "CA bundle verifying keeper certificate, system roots when empty")
+	environment.BindStringEnv("TLS_CA")
 	environment.Parse(config)
 }
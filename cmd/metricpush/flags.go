@@ -0,0 +1,50 @@
+package main
+
+import (
+	"github.com/DimKa163/go-metrics/internal/environment"
+)
+
+type Config struct {
+	Addr              string `arg:"a" envArg:"ADDRESS"`
+	Job               string `arg:"job" envArg:"PUSH_JOB"`
+	Instance          string `arg:"instance" envArg:"PUSH_INSTANCE"`
+	File              string `arg:"f"`
+	Format            string `arg:"format"`
+	Replace           bool   `arg:"replace"`
+	Delete            bool   `arg:"delete"`
+	Key               string `arg:"k" envArg:"KEY"`
+	Token             string `arg:"token" envArg:"TOKEN"`
+	PublicKeyFilePath string `arg:"crypto-key" envArg:"CRYPTO_KEY"`
+	CryptoKeyID       string `arg:"crypto-key-id" envArg:"CRYPTO_KEY_ID"`
+	TLSCertFile       string `arg:"tls-cert" envArg:"TLS_CERT"`
+	TLSKeyFile        string `arg:"tls-key" envArg:"TLS_KEY"`
+	TLSCAFile         string `arg:"tls-ca" envArg:"TLS_CA"`
+}
+
+func ParseFlags(config *Config) error {
+	environment.BindStringArg("a", "localhost:8080", "keeper address")
+	environment.BindStringEnv("ADDRESS")
+	environment.BindStringArg("job", "", "job name")
+	environment.BindStringEnv("PUSH_JOB")
+	environment.BindStringArg("instance", "", "instance name")
+	environment.BindStringEnv("PUSH_INSTANCE")
+	environment.BindStringArg("f", "-", "file with metrics, - reads stdin")
+	environment.BindStringArg("format", "", "input format: lines, json or influx, detected when empty")
+	environment.BindBooleanArg("replace", false, "replace whole group instead of merging")
+	environment.BindBooleanArg("delete", false, "delete group")
+	environment.BindStringArg("k", "", "key")
+	environment.BindStringEnv("KEY")
+	environment.BindStringArg("token", "", "tenant API token")
+	environment.BindStringEnv("TOKEN")
+	environment.BindStringArg("crypto-key", "", "crypto key")
+	environment.BindStringEnv("CRYPTO_KEY")
+	environment.BindStringArg("crypto-key-id", "", "pinned encryption key id")
+	environment.BindStringEnv("CRYPTO_KEY_ID")
+	environment.BindStringArg("tls-cert", "", "client certificate presented to keeper")
+	environment.BindStringEnv("TLS_CERT")
+	environment.BindStringArg("tls-key", "", "client certificate key")
+	environment.BindStringEnv("TLS_KEY")
+	environment.BindStringArg("tls-ca", "", "CA bundle verifying keeper certificate, system roots when empty")
+	environment.BindStringEnv("TLS_CA")
+	return environment.Parse(config)
+}
@@ -0,0 +1,123 @@
+// metricpush send metrics of short-lived job to keeper in one shot
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/DimKa163/go-metrics/internal/certs"
+	"github.com/DimKa163/go-metrics/internal/client"
+	"github.com/DimKa163/go-metrics/internal/client/tripper"
+	"github.com/DimKa163/go-metrics/internal/crypto"
+	"github.com/DimKa163/go-metrics/internal/models"
+	"github.com/DimKa163/go-metrics/internal/plugins"
+)
+
+func main() {
+	var config Config
+	if err := ParseFlags(&config); err != nil {
+		log.Fatal(err)
+	}
+	if err := run(&config); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(config *Config) error {
+	if config.Job == "" {
+		return errors.New("job is required")
+	}
+	tripperFc, err := transports(config)
+	if err != nil {
+		return err
+	}
+	scheme := "http"
+	var tlsConfig *tls.Config
+	if config.TLSCertFile != "" || config.TLSCAFile != "" {
+		reloader, err := certs.NewReloader(config.TLSCertFile, config.TLSKeyFile, config.TLSCAFile)
+		if err != nil {
+			return err
+		}
+		scheme = "https"
+		tlsConfig = reloader.ClientConfig()
+	}
+	pushClient := client.NewPushClient(fmt.Sprintf("%s://%s", scheme, config.Addr), tlsConfig, tripperFc)
+	key := models.GroupKey{Job: config.Job, Instance: config.Instance}
+	if config.Delete {
+		return pushClient.DeleteGroup(key)
+	}
+	metrics, err := readMetrics(config.File, config.Format)
+	if err != nil {
+		return err
+	}
+	if err = pushClient.Push(key, metrics, config.Replace); err != nil {
+		return err
+	}
+	fmt.Printf("Pushed %d metrics to job %q\n", len(metrics), config.Job)
+	return nil
+}
+
+func readMetrics(path string, format string) ([]*models.Metric, error) {
+	var reader io.Reader = os.Stdin
+	if path != "" && path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		reader = file
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	if format == "" {
+		format = plugins.FormatLines
+		if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+			format = plugins.FormatJSON
+		}
+	}
+	parse, err := plugins.NewParser(format)
+	if err != nil {
+		return nil, err
+	}
+	return parse(data)
+}
+
+func transports(config *Config) ([]func(transport http.RoundTripper) http.RoundTripper, error) {
+	tripperFc := []func(transport http.RoundTripper) http.RoundTripper{
+		func(transport http.RoundTripper) http.RoundTripper {
+			return tripper.NewGzip(transport)
+		},
+	}
+	if config.Token != "" {
+		tripperFc = append(tripperFc, func(transport http.RoundTripper) http.RoundTripper {
+			return tripper.NewAuthTripper(transport, config.Token)
+		})
+	}
+	if config.Key != "" {
+		tripperFc = append(tripperFc, func(transport http.RoundTripper) http.RoundTripper {
+			return tripper.NewHashTripper(transport, config.Key)
+		})
+	}
+	if config.PublicKeyFilePath != "" {
+		encrypter, err := crypto.NewEncrypter(config.PublicKeyFilePath, config.CryptoKeyID)
+		if err != nil {
+			return nil, err
+		}
+		tripperFc = append(tripperFc, func(transport http.RoundTripper) http.RoundTripper {
+			return tripper.NewCryptoTripper(transport, encrypter)
+		})
+	}
+	// retry is outermost, so every attempt is signed and encrypted anew
+	tripperFc = append(tripperFc, func(transport http.RoundTripper) http.RoundTripper {
+		return tripper.NewRetryRoundTripper(transport)
+	})
+	return tripperFc, nil
+}
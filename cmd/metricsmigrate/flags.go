@@ -0,0 +1,25 @@
+package main
+
+import (
+	"github.com/DimKa163/go-metrics/internal/environment"
+)
+
+type Config struct {
+	From      string `arg:"from" envArg:"MIGRATE_FROM"`
+	To        string `arg:"to" envArg:"MIGRATE_TO"`
+	BatchSize int    `arg:"batch"`
+	DryRun    bool   `arg:"dry-run"`
+	MetricTTL int64  `arg:"metric-ttl" envArg:"METRIC_TTL"`
+}
+
+func ParseFlags(config *Config) error {
+	environment.BindStringArg("from", "", "source repository: postgres DSN or dump file path")
+	environment.BindStringEnv("MIGRATE_FROM")
+	environment.BindStringArg("to", "", "target repository: postgres DSN or dump file path")
+	environment.BindStringEnv("MIGRATE_TO")
+	environment.BindIntArg("batch", 500, "metrics read and written at once")
+	environment.BindBooleanArg("dry-run", false, "read source and report what would be copied without writing target")
+	environment.BindInt64Arg("metric-ttl", 0, "skip metrics not updated for seconds as expired, 0 copies every metric")
+	environment.BindInt64Env("METRIC_TTL")
+	return environment.Parse(config)
+}
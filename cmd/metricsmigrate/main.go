@@ -0,0 +1,152 @@
+// metricsmigrate copy metrics of every tenant from one repository to another, usage:
+//
+//	metricsmigrate -from SOURCE -to TARGET [-batch N] [-dry-run] [-metric-ttl SECONDS]
+//
+// Repository is postgres DSN (postgres:// or postgresql://) or path of dump file, optionally prefixed with file:.
+// Postgres schema is migrated from ./migrations, so tool has to be run from repository root like keeper.
+// Copy is verified by comparing count and checksum of metrics of each tenant.
+// Nothing is copied when some id is longer than postgres target stores.
+// Metrics not updated within metric ttl are skipped, pass the keeper's -metric-ttl so expired metrics
+// don't come back to life in target, which stamps every copied metric with time of the copy.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/DimKa163/go-metrics/internal/files"
+	"github.com/DimKa163/go-metrics/internal/migration"
+	"github.com/DimKa163/go-metrics/internal/persistence/mem"
+	"github.com/DimKa163/go-metrics/internal/persistence/pg"
+)
+
+var attempts = []int{1, 3, 5}
+
+// backend repository opened by spec, save persist it when backend is not durable by itself,
+// maxID is the longest id it stores or 0 when unlimited
+type backend struct {
+	migration.Store
+	save  func(ctx context.Context) error
+	close func()
+	maxID int
+}
+
+func main() {
+	var config Config
+	if err := ParseFlags(&config); err != nil {
+		log.Fatal(err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := run(ctx, &config); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(ctx context.Context, config *Config) error {
+	if config.From == "" || config.To == "" {
+		return errors.New("both -from and -to are required")
+	}
+	if config.From == config.To {
+		return errors.New("source and target are the same")
+	}
+	source, err := open(ctx, config.From, true)
+	if err != nil {
+		return fmt.Errorf("open source: %w", err)
+	}
+	defer source.close()
+	target, err := open(ctx, config.To, false)
+	if err != nil {
+		return fmt.Errorf("open target: %w", err)
+	}
+	defer target.close()
+
+	report, err := migration.Migrate(ctx, source, target, migration.Options{
+		BatchSize:   config.BatchSize,
+		DryRun:      config.DryRun,
+		TTL:         time.Duration(config.MetricTTL) * time.Second,
+		MaxIDLength: target.maxID,
+		Progress: func(progress migration.Progress) {
+			verb := "copied"
+			if config.DryRun {
+				verb = "read"
+			}
+			fmt.Fprintf(os.Stderr, "tenant %q: %d metrics %s, %d total\n", progress.Tenant, progress.Copied, verb, progress.Total)
+		},
+	})
+	for _, check := range report.Checks {
+		if config.DryRun {
+			fmt.Fprintf(os.Stderr, "tenant %q: %d metrics, checksum %s\n", check.Tenant, check.Source.Count, check.Source.Checksum)
+			continue
+		}
+		status := "ok"
+		if !check.Matches() {
+			status = "MISMATCH"
+		}
+		fmt.Fprintf(os.Stderr, "tenant %q: source %d metrics %s, target %d metrics %s: %s\n", check.Tenant,
+			check.Source.Count, check.Source.Checksum, check.Target.Count, check.Target.Checksum, status)
+	}
+	if err != nil {
+		return err
+	}
+	if config.DryRun {
+		fmt.Fprintf(os.Stderr, "Dry run: %d metrics of %d tenants would be copied\n", report.Copied, len(report.Checks))
+		return nil
+	}
+	if err = target.save(ctx); err != nil {
+		return fmt.Errorf("save target: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Copied and verified %d metrics of %d tenants\n", report.Copied, len(report.Checks))
+	return nil
+}
+
+// open repository by spec, dump file of source has to exist and missing dump file of target is created on save only
+func open(ctx context.Context, spec string, source bool) (*backend, error) {
+	if strings.HasPrefix(spec, "postgres://") || strings.HasPrefix(spec, "postgresql://") {
+		pool, err := pgxpool.New(ctx, spec)
+		if err != nil {
+			return nil, err
+		}
+		store, err := pg.NewStore(pool, attempts)
+		if err != nil {
+			pool.Close()
+			return nil, err
+		}
+		return &backend{
+			Store: store,
+			save:  func(context.Context) error { return nil },
+			close: pool.Close,
+			maxID: pg.MaxIDLength,
+		}, nil
+	}
+	path := strings.TrimPrefix(spec, "file:")
+	_, err := os.Stat(path)
+	if err != nil && (source || !errors.Is(err, os.ErrNotExist)) {
+		return nil, err
+	}
+	filer := files.NewFiler(path, attempts)
+	store, err := mem.NewStore(filer, mem.StoreOption{Restore: err == nil})
+	if err != nil {
+		return nil, err
+	}
+	return &backend{
+		Store: store,
+		save: func(ctx context.Context) error {
+			tenants, err := store.Snapshot(ctx)
+			if err != nil {
+				return err
+			}
+			return filer.DumpTenants(tenants)
+		},
+		close: func() {},
+	}, nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"github.com/DimKa163/go-metrics/internal/environment"
+)
+
+type Config struct {
+	Addr              string `arg:"a" envArg:"ADDRESS"`
+	File              string `arg:"f"`
+	Format            string `arg:"format"`
+	Mode              string `arg:"mode"`
+	Key               string `arg:"k" envArg:"KEY"`
+	Token             string `arg:"token" envArg:"TOKEN"`
+	PublicKeyFilePath string `arg:"crypto-key" envArg:"CRYPTO_KEY"`
+	CryptoKeyID       string `arg:"crypto-key-id" envArg:"CRYPTO_KEY_ID"`
+	TLSCertFile       string `arg:"tls-cert" envArg:"TLS_CERT"`
+	TLSKeyFile        string `arg:"tls-key" envArg:"TLS_KEY"`
+	TLSCAFile         string `arg:"tls-ca" envArg:"TLS_CA"`
+}
+
+func ParseFlags(config *Config) error {
+	environment.BindStringArg("a", "localhost:8080", "keeper address")
+	environment.BindStringEnv("ADDRESS")
+	environment.BindStringArg("f", "-", "file to export to or import from, - is stdout or stdin")
+	environment.BindStringArg("format", "", "csv, json or ndjson, detected from file extension when empty")
+	environment.BindStringArg("mode", "merge", "import mode: merge adds counters to stored ones, replace overwrites them")
+	environment.BindStringArg("k", "", "key")
+	environment.BindStringEnv("KEY")
+	environment.BindStringArg("token", "", "tenant API token")
+	environment.BindStringEnv("TOKEN")
+	environment.BindStringArg("crypto-key", "", "crypto key")
+	environment.BindStringEnv("CRYPTO_KEY")
+	environment.BindStringArg("crypto-key-id", "", "pinned encryption key id")
+	environment.BindStringEnv("CRYPTO_KEY_ID")
+	environment.BindStringArg("tls-cert", "", "client certificate presented to keeper")
+	environment.BindStringEnv("TLS_CERT")
+	environment.BindStringArg("tls-key", "", "client certificate key")
+	environment.BindStringEnv("TLS_KEY")
+	environment.BindStringArg("tls-ca", "", "CA bundle verifying keeper certificate, system roots when empty")
+	environment.BindStringEnv("TLS_CA")
+	return environment.Parse(config)
+}
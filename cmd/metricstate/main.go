@@ -0,0 +1,130 @@
+// metricstate export metrics of keeper to file or import them back, usage:
+//
+//	metricstate [flags] export|import
+//
+// Exported file can be imported into keeper with any repository backend.
+package main
+
+import (
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/DimKa163/go-metrics/internal/certs"
+	"github.com/DimKa163/go-metrics/internal/client"
+	"github.com/DimKa163/go-metrics/internal/client/tripper"
+	"github.com/DimKa163/go-metrics/internal/crypto"
+	"github.com/DimKa163/go-metrics/internal/transfer"
+)
+
+func main() {
+	var config Config
+	if err := ParseFlags(&config); err != nil {
+		log.Fatal(err)
+	}
+	if err := run(&config, flag.Arg(0)); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(config *Config, command string) error {
+	format := config.Format
+	if format == "" {
+		format = transfer.FormatOf(strings.TrimPrefix(filepath.Ext(config.File), "."))
+	}
+	if format == "" {
+		format = transfer.FormatJSON
+	}
+	tripperFc, err := transports(config)
+	if err != nil {
+		return err
+	}
+	scheme := "http"
+	var tlsConfig *tls.Config
+	if config.TLSCertFile != "" || config.TLSCAFile != "" {
+		reloader, err := certs.NewReloader(config.TLSCertFile, config.TLSKeyFile, config.TLSCAFile)
+		if err != nil {
+			return err
+		}
+		scheme = "https"
+		tlsConfig = reloader.ClientConfig()
+	}
+	transferClient := client.NewTransferClient(fmt.Sprintf("%s://%s", scheme, config.Addr), tlsConfig, tripperFc)
+	switch command {
+	case "export":
+		return export(transferClient, config.File, format)
+	case "import":
+		return load(transferClient, config.File, format, config.Mode)
+	default:
+		return fmt.Errorf("unknown command %q, export or import expected", command)
+	}
+}
+
+func export(transferClient client.TransferClient, path string, format string) error {
+	var writer io.Writer = os.Stdout
+	if path != "" && path != "-" {
+		file, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		writer = file
+	}
+	return transferClient.Export(writer, format)
+}
+
+func load(transferClient client.TransferClient, path string, format string, mode string) error {
+	var reader io.Reader = os.Stdin
+	if path != "" && path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		reader = file
+	}
+	count, err := transferClient.Import(reader, format, mode)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Imported %d metrics\n", count)
+	return nil
+}
+
+func transports(config *Config) ([]func(transport http.RoundTripper) http.RoundTripper, error) {
+	tripperFc := []func(transport http.RoundTripper) http.RoundTripper{
+		func(transport http.RoundTripper) http.RoundTripper {
+			return tripper.NewGzip(transport)
+		},
+	}
+	if config.Token != "" {
+		tripperFc = append(tripperFc, func(transport http.RoundTripper) http.RoundTripper {
+			return tripper.NewAuthTripper(transport, config.Token)
+		})
+	}
+	if config.Key != "" {
+		tripperFc = append(tripperFc, func(transport http.RoundTripper) http.RoundTripper {
+			return tripper.NewHashTripper(transport, config.Key)
+		})
+	}
+	if config.PublicKeyFilePath != "" {
+		encrypter, err := crypto.NewEncrypter(config.PublicKeyFilePath, config.CryptoKeyID)
+		if err != nil {
+			return nil, err
+		}
+		tripperFc = append(tripperFc, func(transport http.RoundTripper) http.RoundTripper {
+			return tripper.NewCryptoTripper(transport, encrypter)
+		})
+	}
+	// retry is outermost, so every attempt is signed and encrypted anew
+	tripperFc = append(tripperFc, func(transport http.RoundTripper) http.RoundTripper {
+		return tripper.NewRetryRoundTripper(transport)
+	})
+	return tripperFc, nil
+}
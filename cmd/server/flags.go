@@ -20,10 +20,74 @@ func ParseFlags(config *keeper.Config) error {
 	environment.BindStringEnv("DATABASE_DSN")
 	environment.BindStringArg("k", "", "keeper key")
 	environment.BindStringEnv("KEY")
+	environment.BindInt64Arg("signature-skew", 300, "allowed clock skew of signed requests in seconds")
+	environment.BindInt64Env("SIGNATURE_SKEW")
+	environment.BindIntArg("nonce-cache", 100000, "max remembered nonces of signed requests")
+	environment.BindIntEnv("NONCE_CACHE_SIZE")
 	environment.BindStringArg("c", "", "config")
 	environment.BindStringEnv("CONFIG")
-	environment.BindStringArg("crypto-key", "", "crypto key")
+	environment.BindStringArg("crypto-key", "", "crypto key file or directory of keys, reloaded on SIGHUP")
 	environment.BindStringEnv("CRYPTO_KEY")
+	environment.BindInt64Arg("push-ttl", 0, "expire pushed groups after seconds, 0 keeps them forever")
+	environment.BindInt64Env("PUSH_GROUP_TTL")
+	environment.BindStringArg("tls-cert", "", "serve TLS with certificate, reloaded when file changes or on SIGHUP")
+	environment.BindStringEnv("TLS_CERT")
+	environment.BindStringArg("tls-key", "", "TLS certificate key")
+	environment.BindStringEnv("TLS_KEY")
+	environment.BindStringArg("tls-client-ca", "", "CA bundle verifying agent certificates, client certificates are not required when empty")
+	environment.BindStringEnv("TLS_CLIENT_CA")
+	environment.BindStringArg("t", "", "comma separated CIDR list allowed to write metrics, any address when empty")
+	environment.BindStringEnv("TRUSTED_SUBNET")
+	environment.BindStringArg("trusted-read-subnet", "", "comma separated CIDR list allowed to read metrics, any address when empty")
+	environment.BindStringEnv("TRUSTED_READ_SUBNET")
+	environment.BindStringArg("trusted-proxies", "", "comma separated CIDR list of proxies whose X-Real-IP and X-Forwarded-For are trusted")
+	environment.BindStringEnv("TRUSTED_PROXIES")
+	environment.BindIntArg("request-rate", 0, "requests per second allowed to every client, 0 is unlimited")
+	environment.BindIntEnv("REQUEST_RATE")
+	environment.BindIntArg("request-burst", 0, "request burst of every client, request rate when 0")
+	environment.BindIntEnv("REQUEST_BURST")
+	environment.BindIntArg("metric-rate", 0, "metrics per second allowed to every client, 0 is unlimited")
+	environment.BindIntEnv("METRIC_RATE")
+	environment.BindIntArg("metric-burst", 0, "metric burst of every client, metric rate when 0")
+	environment.BindIntEnv("METRIC_BURST")
+	environment.BindStringArg("metric-name-pattern", "", "regular expression every metric name must match")
+	environment.BindStringEnv("METRIC_NAME_PATTERN")
+	environment.BindIntArg("metric-name-max", 0, "max metric name length, 0 is unlimited, never above 255 with database")
+	environment.BindIntEnv("METRIC_NAME_MAX")
+	environment.BindStringArg("metric-allow", "", "comma separated glob patterns, only matching metric names are accepted when set")
+	environment.BindStringEnv("METRIC_ALLOW")
+	environment.BindStringArg("metric-block", "", "comma separated glob patterns of rejected metric names")
+	environment.BindStringEnv("METRIC_BLOCK")
+	environment.BindIntArg("max-tenant-metrics", 0, "max distinct metrics of every tenant, 0 is unlimited")
+	environment.BindIntEnv("MAX_TENANT_METRICS")
+	environment.BindIntArg("max-agent-metrics", 0, "max distinct metrics written by every agent, 0 is unlimited")
+	environment.BindIntEnv("MAX_AGENT_METRICS")
+	environment.BindStringArg("audit-file", "", "append audit log of accepted updates to file as JSON lines")
+	environment.BindStringEnv("AUDIT_FILE")
+	environment.BindInt64Arg("audit-file-size", 100, "rotate audit file after megabytes, 0 never rotates")
+	environment.BindInt64Env("AUDIT_FILE_SIZE")
+	environment.BindIntArg("audit-file-backups", 5, "rotated audit files to keep")
+	environment.BindIntEnv("AUDIT_FILE_BACKUPS")
+	environment.BindStringArg("audit-url", "", "post audit events of accepted updates to url")
+	environment.BindStringEnv("AUDIT_URL")
+	environment.BindIntArg("audit-queue", 1024, "audit events waiting for sinks, events over it are dropped and counted on /healthz")
+	environment.BindIntEnv("AUDIT_QUEUE")
+	environment.BindIntArg("history-size", 360, "samples kept per metric for range queries, 0 disables history")
+	environment.BindIntEnv("HISTORY_SIZE")
+	environment.BindInt64Arg("metric-ttl", 0, "hide metrics not updated for seconds, 0 keeps them forever")
+	environment.BindInt64Env("METRIC_TTL")
+	environment.BindInt64Arg("metric-gc", 0, "remove metrics not updated for seconds, never below metric-ttl, 0 is 10 times metric-ttl")
+	environment.BindInt64Env("METRIC_GC")
+	environment.BindIntArg("stream-buffer", 1024, "pending metrics kept per stream subscriber, updates of other metrics are dropped when it's full")
+	environment.BindIntEnv("STREAM_BUFFER")
+	environment.BindInt64Arg("idempotency-ttl", 600, "seconds Idempotency-Key of applied request is remembered, 0 disables deduplication")
+	environment.BindInt64Env("IDEMPOTENCY_TTL")
+	environment.BindIntArg("idempotency-cache", 100000, "max remembered idempotency keys when database is not used")
+	environment.BindIntEnv("IDEMPOTENCY_CACHE_SIZE")
+	environment.BindStringArg("admin-token", "", "admin token of default tenant, enables bearer token authentication")
+	environment.BindStringEnv("ADMIN_TOKEN")
+	environment.BindStringArg("tokens-file", "tokens.json", "file to store issued tokens when database is not used")
+	environment.BindStringEnv("TOKENS_FILE")
 	environment.Parse(config)
 	return nil
 }
@@ -20,6 +20,1035 @@ const docTemplate = `{
                 "produces": [
                     "text/html"
                 ],
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Part of metric name",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Metric type, gauge or counter",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Time span of sparklines, 15m by default",
+                        "name": "window",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "dashboard page",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "bad request",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/export": {
+            "get": {
+                "produces": [
+                    "application/json",
+                    "text/csv",
+                    "application/x-ndjson"
+                ],
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "csv, json or ndjson",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "all metrics",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/contracts.Metric"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "bad request",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/import": {
+            "post": {
+                "consumes": [
+                    "application/json",
+                    "text/csv",
+                    "application/x-ndjson"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "csv, json or ndjson",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "merge or replace, merge by default",
+                        "name": "mode",
+                        "in": "query"
+                    },
+                    {
+                        "description": "metrics",
+                        "name": "metrics",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/contracts.Metric"
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "count of imported metrics",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.Affected"
+                        }
+                    },
+                    "400": {
+                        "description": "bad request, nothing is imported",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    },
+                    "422": {
+                        "description": "metric name or count rejected by policy",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/metrics": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Metric type, gauge or counter",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Metric id prefix",
+                        "name": "prefix",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Regular expression metric id must match",
+                        "name": "regex",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sort by id or type, id by default",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "asc or desc, asc by default",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor of next page returned with previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size, 100 by default and at most 1000",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "success request",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.MetricPage"
+                        }
+                    },
+                    "400": {
+                        "description": "bad request",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "produces": [
+                    "application/json"
+                ],
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Metric type, gauge or counter",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Metric id prefix",
+                        "name": "prefix",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Regular expression metric id must match",
+                        "name": "regex",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "count of removed metrics",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.Affected"
+                        }
+                    },
+                    "400": {
+                        "description": "bad request",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/metrics/reset": {
+            "post": {
+                "produces": [
+                    "application/json"
+                ],
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Metric type, gauge or counter",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Metric id prefix",
+                        "name": "prefix",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Regular expression metric id must match",
+                        "name": "regex",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "count of reset metrics",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.Affected"
+                        }
+                    },
+                    "400": {
+                        "description": "bad request",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/metrics/{id}": {
+            "delete": {
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Metric id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "metric removed"
+                    },
+                    "404": {
+                        "description": "metric not found",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/metrics/{id}/reset": {
+            "post": {
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Metric id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "metric reset"
+                    },
+                    "404": {
+                        "description": "metric not found",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/query": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Query, e.g. sum(rate(PollCount[5m]))",
+                        "name": "query",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Evaluation time, unix seconds or RFC3339",
+                        "name": "time",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Range start, unix seconds or RFC3339",
+                        "name": "start",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Range end, unix seconds or RFC3339",
+                        "name": "end",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Range step, duration like 15s or seconds, 1m by default",
+                        "name": "step",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "success request",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.QueryResult"
+                        }
+                    },
+                    "400": {
+                        "description": "bad request",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/tokens": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "responses": {
+                    "200": {
+                        "description": "success request",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/contracts.Token"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "produces": [
+                    "application/json"
+                ],
+                "parameters": [
+                    {
+                        "description": "tenant and scopes",
+                        "name": "token",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/contracts.TokenRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "issued token with secret",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.Token"
+                        }
+                    },
+                    "400": {
+                        "description": "bad request",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    },
+                    "403": {
+                        "description": "tenant can't be managed",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/tokens/{id}": {
+            "delete": {
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Token id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "token revoked"
+                    },
+                    "404": {
+                        "description": "token not found",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v2/metrics": {
+            "get": {
+                "produces": [
+                    "application/json",
+                    "text/plain",
+                    "application/x-protobuf"
+                ],
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Metric type, gauge or counter",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Metric id prefix",
+                        "name": "prefix",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Regular expression metric id must match",
+                        "name": "regex",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sort by id or type, id by default",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "asc or desc, asc by default",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor of next page returned with previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size, 100 by default and at most 1000",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "success request, cursor of next page is sent in X-Next-Cursor header as well",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.MetricPage"
+                        }
+                    },
+                    "400": {
+                        "description": "bad request, invalid_filter or invalid_cursor",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    },
+                    "406": {
+                        "description": "not_acceptable",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "text/plain",
+                    "application/x-protobuf"
+                ],
+                "parameters": [
+                    {
+                        "description": "metric",
+                        "name": "metric",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/contracts.Metric"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "stored metric",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.Metric"
+                        }
+                    },
+                    "400": {
+                        "description": "bad_request, invalid_metric or invalid_metric_name",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    },
+                    "406": {
+                        "description": "not_acceptable",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    },
+                    "422": {
+                        "description": "metric_name_rejected or metric_limit_exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v2/metrics/batch": {
+            "post": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "text/plain",
+                    "application/x-protobuf"
+                ],
+                "parameters": [
+                    {
+                        "description": "metrics",
+                        "name": "metrics",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/contracts.Metric"
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "count of accepted metrics",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.Affected"
+                        }
+                    },
+                    "400": {
+                        "description": "bad_request, invalid_metric or invalid_metric_name",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    },
+                    "406": {
+                        "description": "not_acceptable",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    },
+                    "422": {
+                        "description": "metric_name_rejected or metric_limit_exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v2/metrics/{type}/{id}": {
+            "get": {
+                "produces": [
+                    "application/json",
+                    "text/plain",
+                    "application/x-protobuf"
+                ],
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Metric type",
+                        "name": "type",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Metric id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "success request, text is bare value",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.Metric"
+                        }
+                    },
+                    "404": {
+                        "description": "metric_not_found or type_mismatch",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    },
+                    "406": {
+                        "description": "not_acceptable",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    }
+                }
+            }
+        },
+        "/dashboard/api/metrics": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Time span of samples, 15m by default",
+                        "name": "window",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "success request",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/contracts.DashboardMetric"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "bad request",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    }
+                }
+            }
+        },
+        "/dashboard/api/metrics/{id}": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Metric id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Time span of samples, 15m by default",
+                        "name": "window",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "success request",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.DashboardMetric"
+                        }
+                    },
+                    "400": {
+                        "description": "bad request",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    },
+                    "404": {
+                        "description": "metric not found",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    }
+                }
+            }
+        },
+        "/healthz": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "responses": {
+                    "200": {
+                        "description": "keeper is healthy",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.Health"
+                        }
+                    },
+                    "503": {
+                        "description": "database is unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.Health"
+                        }
+                    }
+                }
+            }
+        },
+        "/metric/{id}": {
+            "get": {
+                "produces": [
+                    "text/html"
+                ],
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Metric id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Time span of chart, 15m by default",
+                        "name": "window",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "metric page",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "bad request",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    },
+                    "404": {
+                        "description": "metric not found",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    }
+                }
+            }
+        },
+        "/metrics/groups": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "responses": {
+                    "200": {
+                        "description": "success request",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/contracts.Group"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    }
+                }
+            }
+        },
+        "/metrics/job/{job}/instance/{instance}": {
+            "put": {
+                "produces": [
+                    "application/json"
+                ],
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job name",
+                        "name": "job",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Instance name",
+                        "name": "instance",
+                        "in": "path"
+                    },
+                    {
+                        "description": "metric array",
+                        "name": "metrics",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/contracts.Metric"
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "success request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "bad request",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    },
+                    "422": {
+                        "description": "metric name or count is refused by policy",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "produces": [
+                    "application/json"
+                ],
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job name",
+                        "name": "job",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Instance name",
+                        "name": "instance",
+                        "in": "path"
+                    },
+                    {
+                        "description": "metric array",
+                        "name": "metrics",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/contracts.Metric"
+                            }
+                        }
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "success request",
@@ -33,6 +1062,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/contracts.ErrorModel"
                         }
                     },
+                    "422": {
+                        "description": "metric name or count is refused by policy",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    },
                     "500": {
                         "description": "internal server error",
                         "schema": {
@@ -40,6 +1075,104 @@ const docTemplate = `{
                         }
                     }
                 }
+            },
+            "delete": {
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job name",
+                        "name": "job",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Instance name",
+                        "name": "instance",
+                        "in": "path"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "success request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "group not found",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    }
+                }
+            }
+        },
+        "/stream": {
+            "get": {
+                "produces": [
+                    "text/event-stream"
+                ],
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Metric type, gauge or counter",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated metric name globs, e.g. cpu.*,PollCount",
+                        "name": "name",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "data of every event",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.StreamMessage"
+                        }
+                    },
+                    "400": {
+                        "description": "bad request",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    }
+                }
+            }
+        },
+        "/stream/ws": {
+            "get": {
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Metric type, gauge or counter",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated metric name globs, e.g. cpu.*,PollCount",
+                        "name": "name",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "101": {
+                        "description": "switching protocols",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "bad request",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    }
+                }
             }
         },
         "/update": {
@@ -65,6 +1198,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/contracts.ErrorModel"
                         }
                     },
+                    "422": {
+                        "description": "metric name or count rejected by policy",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    },
                     "500": {
                         "description": "internal server error",
                         "schema": {
@@ -110,6 +1249,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/contracts.ErrorModel"
                         }
                     },
+                    "422": {
+                        "description": "metric name or count rejected by policy",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    },
                     "500": {
                         "description": "internal server error",
                         "schema": {
@@ -136,11 +1281,17 @@ const docTemplate = `{
                                 "$ref": "#/definitions/contracts.Metric"
                             }
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Key generated per batch, batch with key applied already is acknowledged without being applied",
+                        "name": "Idempotency-Key",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "success request",
+                        "description": "success request, Idempotent-Replayed header is set when batch was applied already",
                         "schema": {
                             "type": "string"
                         }
@@ -151,6 +1302,18 @@ const docTemplate = `{
                             "$ref": "#/definitions/contracts.ErrorModel"
                         }
                     },
+                    "409": {
+                        "description": "batch with the key is being applied, retry after Retry-After seconds",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "metric name or count rejected by policy",
+                        "schema": {
+                            "$ref": "#/definitions/contracts.ErrorModel"
+                        }
+                    },
                     "500": {
                         "description": "internal server error",
                         "schema": {
@@ -225,14 +1388,114 @@ const docTemplate = `{
         }
     },
     "definitions": {
+        "contracts.Affected": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer"
+                }
+            }
+        },
+        "contracts.DashboardMetric": {
+            "type": "object",
+            "properties": {
+                "delta": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "samples": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/contracts.QuerySample"
+                    }
+                },
+                "stale": {
+                    "type": "boolean"
+                },
+                "type": {
+                    "type": "string"
+                },
+                "value": {
+                    "type": "number"
+                }
+            }
+        },
+        "contracts.ErrorCode": {
+            "type": "string",
+            "enum": [
+                "bad_request",
+                "invalid_metric",
+                "invalid_metric_name",
+                "invalid_filter",
+                "invalid_cursor",
+                "metric_not_found",
+                "type_mismatch",
+                "metric_name_rejected",
+                "metric_limit_exceeded",
+                "not_acceptable",
+                "internal"
+            ],
+            "x-enum-varnames": [
+                "CodeBadRequest",
+                "CodeInvalidMetric",
+                "CodeInvalidMetricName",
+                "CodeInvalidFilter",
+                "CodeInvalidCursor",
+                "CodeMetricNotFound",
+                "CodeTypeMismatch",
+                "CodeMetricNameRejected",
+                "CodeMetricLimitExceeded",
+                "CodeNotAcceptable",
+                "CodeInternal"
+            ]
+        },
         "contracts.ErrorModel": {
             "type": "object",
             "properties": {
+                "code": {
+                    "$ref": "#/definitions/contracts.ErrorCode"
+                },
                 "error": {
                     "type": "string"
                 }
             }
         },
+        "contracts.Group": {
+            "type": "object",
+            "properties": {
+                "instance": {
+                    "type": "string"
+                },
+                "job": {
+                    "type": "string"
+                },
+                "metrics": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/contracts.Metric"
+                    }
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "contracts.Health": {
+            "type": "object",
+            "properties": {
+                "audit_dropped": {
+                    "type": "integer"
+                },
+                "database": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
         "contracts.Metric": {
             "type": "object",
             "properties": {
@@ -242,6 +1505,10 @@ const docTemplate = `{
                 "id": {
                     "type": "string"
                 },
+                "stale": {
+                    "description": "Stale gauge was not updated within ttl, its value is omitted",
+                    "type": "boolean"
+                },
                 "type": {
                     "type": "string"
                 },
@@ -249,18 +1516,132 @@ const docTemplate = `{
                     "type": "number"
                 }
             }
+        },
+        "contracts.MetricPage": {
+            "type": "object",
+            "properties": {
+                "metrics": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/contracts.Metric"
+                    }
+                },
+                "next": {
+                    "type": "string"
+                }
+            }
+        },
+        "contracts.QueryResult": {
+            "type": "object",
+            "properties": {
+                "series": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/contracts.QuerySeries"
+                    }
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "contracts.QuerySample": {
+            "type": "object",
+            "properties": {
+                "t": {
+                    "type": "number"
+                },
+                "v": {
+                    "type": "number"
+                }
+            }
+        },
+        "contracts.QuerySeries": {
+            "type": "object",
+            "properties": {
+                "labels": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                },
+                "samples": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/contracts.QuerySample"
+                    }
+                }
+            }
+        },
+        "contracts.StreamMessage": {
+            "type": "object",
+            "properties": {
+                "dropped": {
+                    "type": "integer"
+                },
+                "metrics": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/contracts.Metric"
+                    }
+                }
+            }
+        },
+        "contracts.Token": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "tenant": {
+                    "type": "string"
+                },
+                "token": {
+                    "type": "string"
+                }
+            }
+        },
+        "contracts.TokenRequest": {
+            "type": "object",
+            "required": [
+                "scopes",
+                "tenant"
+            ],
+            "properties": {
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "tenant": {
+                    "type": "string"
+                }
+            }
         }
     }
 }`
 
 // SwaggerInfo holds exported Swagger Info so clients can modify it
 var SwaggerInfo = &swag.Spec{
-	Version:          "",
+	Version:          "2.0",
 	Host:             "",
 	BasePath:         "",
 	Schemes:          []string{},
-	Title:            "",
-	Description:      "",
+	Title:            "MetricStorage API",
+	Description:      "Metric service.",
 	InfoInstanceName: "swagger",
 	SwaggerTemplate:  docTemplate,
 	LeftDelim:        "{{",
@@ -0,0 +1,157 @@
+// Package audit record accepted metric changes and deliver them to sinks in background
+package audit
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/DimKa163/go-metrics/internal/logging"
+)
+
+// maxBatch events handed to sinks at once
+const maxBatch = 256
+
+// retryDelays pauses between attempts to write batch to failing sink
+var retryDelays = []time.Duration{time.Second, 3 * time.Second, 5 * time.Second}
+
+const (
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+	ActionReset  = "reset"
+	ActionExpire = "expire"
+)
+
+// Event accepted change of metrics
+type Event struct {
+	Time    time.Time `json:"ts"`
+	Action  string    `json:"action"`
+	Tenant  string    `json:"tenant"`
+	Agent   string    `json:"agent,omitempty"`
+	Address string    `json:"ip,omitempty"`
+	Metrics []string  `json:"metrics"`
+}
+
+// Sink destination of events, sinks are called from single goroutine
+type Sink interface {
+	Write(ctx context.Context, events []Event) error
+	Close() error
+}
+
+// Auditor queue events and write them to every sink in background.
+// Record never blocks, events are dropped when queue is full. Batch sink keeps failing to write
+// after retries is dropped for that sink as well. Dropped events are lost for good,
+// their count is logged and reported by Dropped, which keeper serves on /healthz
+type Auditor struct {
+	mutex   sync.RWMutex
+	closed  bool
+	events  chan Event
+	done    chan struct{}
+	sinks   []Sink
+	retries []time.Duration
+	dropped atomic.Int64
+}
+
+func NewAuditor(capacity int, sinks ...Sink) *Auditor {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	return &Auditor{
+		events:  make(chan Event, capacity),
+		done:    make(chan struct{}),
+		sinks:   sinks,
+		retries: retryDelays,
+	}
+}
+
+// Record queue event, it is dropped when queue is full or auditor is closed
+func (a *Auditor) Record(event Event) {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	if a.closed {
+		return
+	}
+	select {
+	case a.events <- event:
+	default:
+		if dropped := a.dropped.Add(1); dropped == 1 || dropped%1000 == 0 {
+			logging.Log.Warn("audit queue is full, events dropped", zap.Int64("dropped", dropped))
+		}
+	}
+}
+
+// Dropped number of events lost because queue was full or sink failed to write them, event lost
+// by several sinks is counted for each of them
+func (a *Auditor) Dropped() int64 {
+	return a.dropped.Load()
+}
+
+// Start deliver queued events until auditor is closed
+func (a *Auditor) Start() {
+	go a.run()
+}
+
+// Close stop accepting events, deliver queued ones and close sinks
+func (a *Auditor) Close(ctx context.Context) error {
+	a.mutex.Lock()
+	if !a.closed {
+		a.closed = true
+		close(a.events)
+	}
+	a.mutex.Unlock()
+	select {
+	case <-a.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	var err error
+	for _, sink := range a.sinks {
+		if closeErr := sink.Close(); closeErr != nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+func (a *Auditor) run() {
+	defer close(a.done)
+	batch := make([]Event, 0, maxBatch)
+	for event := range a.events {
+		batch = append(batch[:0], event)
+	fill:
+		for len(batch) < maxBatch {
+			select {
+			case next, ok := <-a.events:
+				if !ok {
+					break fill
+				}
+				batch = append(batch, next)
+			default:
+				break fill
+			}
+		}
+		a.write(batch)
+	}
+}
+
+func (a *Auditor) write(batch []Event) {
+	for _, sink := range a.sinks {
+		err := sink.Write(context.Background(), batch)
+		for _, delay := range a.retries {
+			if err == nil {
+				break
+			}
+			logging.Log.Warn("failed to write audit events, retrying", zap.Error(err), zap.Duration("delay", delay))
+			time.Sleep(delay)
+			err = sink.Write(context.Background(), batch)
+		}
+		if err != nil {
+			dropped := a.dropped.Add(int64(len(batch)))
+			logging.Log.Error("failed to write audit events, events dropped", zap.Error(err),
+				zap.Int("events", len(batch)), zap.Int64("dropped", dropped))
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingSink hold writes until released
+type blockingSink struct {
+	mutex   sync.Mutex
+	release chan struct{}
+	events  []Event
+}
+
+func (s *blockingSink) Write(_ context.Context, events []Event) error {
+	<-s.release
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.events = append(s.events, events...)
+	return nil
+}
+
+func (s *blockingSink) Close() error {
+	return nil
+}
+
+func TestAuditorNeverBlocks(t *testing.T) {
+	sink := &blockingSink{release: make(chan struct{})}
+	auditor := NewAuditor(2, sink)
+	auditor.Start()
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 10; i++ {
+			auditor.Record(Event{Metrics: []string{"A"}})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("record blocked on slow sink")
+	}
+	assert.Positive(t, auditor.Dropped())
+
+	close(sink.release)
+	require.NoError(t, auditor.Close(context.Background()))
+	assert.Equal(t, int64(10), int64(len(sink.events))+auditor.Dropped(), "queued events are flushed on close")
+	auditor.Record(Event{})
+}
+
+// failingSink fail given number of writes before accepting events
+type failingSink struct {
+	failures int
+	writes   int
+	events   []Event
+}
+
+func (s *failingSink) Write(_ context.Context, events []Event) error {
+	s.writes++
+	if s.writes <= s.failures {
+		return errors.New("sink is down")
+	}
+	s.events = append(s.events, events...)
+	return nil
+}
+
+func (s *failingSink) Close() error {
+	return nil
+}
+
+func TestAuditorRetriesFailedWrites(t *testing.T) {
+	recovering := &failingSink{failures: 2}
+	down := &failingSink{failures: 100}
+	auditor := NewAuditor(10, recovering, down)
+	auditor.retries = []time.Duration{time.Millisecond, time.Millisecond}
+	auditor.Start()
+	auditor.Record(Event{Metrics: []string{"A"}})
+	require.NoError(t, auditor.Close(context.Background()))
+
+	assert.Len(t, recovering.events, 1, "write is retried until sink recovers")
+	assert.Equal(t, 3, down.writes)
+	assert.Equal(t, int64(1), auditor.Dropped(), "events sink failed to write after retries are counted as dropped")
+}
+
+func TestFileSinkRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	sink, err := NewFileSink(path, 100, 2)
+	require.NoError(t, err)
+	for i := 0; i < 6; i++ {
+		event := Event{Time: time.Unix(int64(i), 0).UTC(), Tenant: "default", Metrics: []string{"HeapAlloc"}}
+		require.NoError(t, sink.Write(context.Background(), []Event{event}))
+	}
+	require.NoError(t, sink.Close())
+
+	assert.Len(t, readEvents(t, path), 1)
+	assert.Len(t, readEvents(t, path+".1"), 1)
+	assert.Len(t, readEvents(t, path+".2"), 1)
+	assert.NoFileExists(t, path+".3", "only configured backups are kept")
+	assert.Equal(t, time.Unix(5, 0).UTC(), readEvents(t, path)[0].Time)
+}
+
+func TestHTTPSink(t *testing.T) {
+	var received []Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+	}))
+	defer server.Close()
+
+	sink := NewHTTPSink(server.URL, time.Second)
+	events := []Event{{Tenant: "alpha", Agent: "token:a", Address: "10.0.0.1", Metrics: []string{"A", "B"}}}
+	require.NoError(t, sink.Write(context.Background(), events))
+	assert.Equal(t, events[0].Metrics, received[0].Metrics)
+	assert.Equal(t, "10.0.0.1", received[0].Address)
+
+	failing := NewHTTPSink(server.URL+"/missing", time.Second)
+	server.Config.Handler = http.NotFoundHandler()
+	assert.Error(t, failing.Write(context.Background(), events))
+}
+
+func readEvents(t *testing.T, path string) []Event {
+	t.Helper()
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+	var events []Event
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event Event
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		events = append(events, event)
+	}
+	return events
+}
@@ -0,0 +1,94 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// FileSink append events as JSON lines, file is rotated to path.1 ... path.N when it grows over max size
+type FileSink struct {
+	path    string
+	maxSize int64
+	backups int
+	file    *os.File
+	size    int64
+}
+
+// NewFileSink open file for appending, maxSize 0 disables rotation and
+// backups 0 drops rotated file
+func NewFileSink(path string, maxSize int64, backups int) (*FileSink, error) {
+	sink := &FileSink{path: path, maxSize: maxSize, backups: backups}
+	if err := sink.open(); err != nil {
+		return nil, err
+	}
+	return sink, nil
+}
+
+func (s *FileSink) Write(_ context.Context, events []Event) error {
+	for _, event := range events {
+		line, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		line = append(line, '\n')
+		if s.maxSize > 0 && s.size > 0 && s.size+int64(len(line)) > s.maxSize {
+			if err = s.rotate(); err != nil {
+				return err
+			}
+		}
+		n, err := s.file.Write(line)
+		s.size += int64(n)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
+
+func (s *FileSink) open() error {
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	s.file = file
+	s.size = info.Size()
+	return nil
+}
+
+func (s *FileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	for i := s.backups - 1; i > 0; i-- {
+		err := os.Rename(s.backupPath(i), s.backupPath(i+1))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	var err error
+	if s.backups > 0 {
+		err = os.Rename(s.path, s.backupPath(1))
+	} else {
+		err = os.Remove(s.path)
+	}
+	if err != nil {
+		return err
+	}
+	return s.open()
+}
+
+func (s *FileSink) backupPath(index int) string {
+	return fmt.Sprintf("%s.%d", s.path, index)
+}
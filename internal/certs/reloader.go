@@ -0,0 +1,147 @@
+// Package certs TLS configuration with certificates reloaded from disk
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Reloader keep certificate, key and CA bundle loaded from files.
+// Files are checked on every handshake and re-read once modified, so renewed certificates
+// are picked up without restart. Current certificate stays in use when reloading fails.
+type Reloader struct {
+	certFile string
+	keyFile  string
+	caFile   string
+
+	mutex   sync.RWMutex
+	cert    *tls.Certificate
+	pool    *x509.CertPool
+	modTime map[string]time.Time
+}
+
+// NewReloader load files, any of them can be empty. Certificate and key must be set together
+func NewReloader(certFile string, keyFile string, caFile string) (*Reloader, error) {
+	if (certFile == "") != (keyFile == "") {
+		return nil, errors.New("certificate and key must be set together")
+	}
+	r := &Reloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		caFile:   caFile,
+	}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload re-read all files
+func (r *Reloader) Reload() error {
+	modTime := make(map[string]time.Time)
+	for _, file := range []string{r.certFile, r.keyFile, r.caFile} {
+		if file == "" {
+			continue
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		modTime[file] = info.ModTime()
+	}
+	var cert *tls.Certificate
+	if r.certFile != "" {
+		pair, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+		if err != nil {
+			return err
+		}
+		cert = &pair
+	}
+	var pool *x509.CertPool
+	if r.caFile != "" {
+		data, err := os.ReadFile(r.caFile)
+		if err != nil {
+			return err
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return fmt.Errorf("no certificates found in %s", r.caFile)
+		}
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.cert = cert
+	r.pool = pool
+	r.modTime = modTime
+	return nil
+}
+
+// ServerConfig TLS config serving current certificate, client certificates are required
+// and verified against CA bundle when it is set
+func (r *Reloader) ServerConfig() (*tls.Config, error) {
+	if r.certFile == "" {
+		return nil, errors.New("server certificate is required")
+	}
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cert, pool := r.current()
+			config := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*cert},
+			}
+			if pool != nil {
+				config.ClientAuth = tls.RequireAndVerifyClientCert
+				config.ClientCAs = pool
+			}
+			return config, nil
+		},
+	}, nil
+}
+
+// ClientConfig TLS config presenting current client certificate.
+// CA bundle replaces system roots when set, it is read once
+func (r *Reloader) ClientConfig() *tls.Config {
+	_, pool := r.current()
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		RootCAs:    pool,
+	}
+	if r.certFile != "" {
+		config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, _ := r.current()
+			return cert, nil
+		}
+	}
+	return config
+}
+
+func (r *Reloader) current() (*tls.Certificate, *x509.CertPool) {
+	if r.modified() {
+		// failed reload keeps previous certificate, it is retried on next handshake
+		_ = r.Reload()
+	}
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.cert, r.pool
+}
+
+func (r *Reloader) modified() bool {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	for file, modTime := range r.modTime {
+		info, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+		if !info.ModTime().Equal(modTime) {
+			return true
+		}
+	}
+	return false
+}
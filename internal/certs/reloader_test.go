@@ -0,0 +1,184 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DimKa163/go-metrics/internal/client"
+)
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, "ca")
+	caFile := ca.writeCA(t, dir)
+	serverCert, serverKey := ca.issue(t, dir, "keeper", x509.ExtKeyUsageServerAuth)
+	clientCert, clientKey := ca.issue(t, dir, "agent", x509.ExtKeyUsageClientAuth)
+	server := startServer(t, serverCert, serverKey, caFile)
+
+	clientReloader, err := NewReloader(clientCert, clientKey, caFile)
+	require.NoError(t, err)
+	metricClient := client.NewClient(server.URL, clientReloader.ClientConfig(), nil)
+	assert.NoError(t, metricClient.UpdateGauge("Alloc", 1))
+
+	anonymous, err := NewReloader("", "", caFile)
+	require.NoError(t, err)
+	metricClient = client.NewClient(server.URL, anonymous.ClientConfig(), nil)
+	assert.Error(t, metricClient.UpdateGauge("Alloc", 1), "client without certificate must be rejected")
+
+	other := newTestCA(t, "other")
+	otherCert, otherKey := other.issue(t, t.TempDir(), "agent", x509.ExtKeyUsageClientAuth)
+	untrusted, err := NewReloader(otherCert, otherKey, caFile)
+	require.NoError(t, err)
+	metricClient = client.NewClient(server.URL, untrusted.ClientConfig(), nil)
+	assert.Error(t, metricClient.UpdateGauge("Alloc", 1), "certificate of unknown CA must be rejected")
+}
+
+func TestServerCertificateHotReload(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, "ca")
+	caFile := ca.writeCA(t, dir)
+	serverCert, serverKey := ca.issue(t, dir, "keeper-1", x509.ExtKeyUsageServerAuth)
+	server := startServer(t, serverCert, serverKey, "")
+
+	assert.Equal(t, "keeper-1", peerName(t, server.URL, caFile))
+
+	renewedCert, renewedKey := ca.issue(t, t.TempDir(), "keeper-2", x509.ExtKeyUsageServerAuth)
+	replace(t, renewedCert, serverCert)
+	replace(t, renewedKey, serverKey)
+
+	assert.Equal(t, "keeper-2", peerName(t, server.URL, caFile))
+}
+
+func TestReloadKeepsCertificateOnFailure(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, "ca")
+	certFile, keyFile := ca.issue(t, dir, "keeper", x509.ExtKeyUsageServerAuth)
+	reloader, err := NewReloader(certFile, keyFile, "")
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(keyFile, []byte("broken"), 0600))
+	assert.Error(t, reloader.Reload())
+	cert, _ := reloader.current()
+	assert.NotNil(t, cert)
+}
+
+func TestNewReloaderRequiresKeyPair(t *testing.T) {
+	_, err := NewReloader("cert.pem", "", "")
+	assert.Error(t, err)
+
+	reloader, err := NewReloader("", "", "")
+	require.NoError(t, err)
+	_, err = reloader.ServerConfig()
+	assert.Error(t, err)
+}
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCA{cert: cert, key: key, der: der}
+}
+
+func (ca *testCA) writeCA(t *testing.T, dir string) string {
+	t.Helper()
+	path := filepath.Join(dir, "ca.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.der}), 0600))
+	return path
+}
+
+func (ca *testCA) issue(t *testing.T, dir string, name string, usage x509.ExtKeyUsage) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	certFile := filepath.Join(dir, name+".crt")
+	keyFile := filepath.Join(dir, name+".key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600))
+	return certFile, keyFile
+}
+
+func startServer(t *testing.T, certFile string, keyFile string, clientCAFile string) *httptest.Server {
+	t.Helper()
+	reloader, err := NewReloader(certFile, keyFile, clientCAFile)
+	require.NoError(t, err)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS, err = reloader.ServerConfig()
+	require.NoError(t, err)
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+// peerName common name of certificate served on fresh connection
+func peerName(t *testing.T, url string, caFile string) string {
+	t.Helper()
+	reloader, err := NewReloader("", "", caFile)
+	require.NoError(t, err)
+	httpClient := &http.Client{Transport: &http.Transport{TLSClientConfig: reloader.ClientConfig()}}
+	defer httpClient.CloseIdleConnections()
+	res, err := httpClient.Get(url)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	return res.TLS.PeerCertificates[0].Subject.CommonName
+}
+
+// replace move file over target and bump modification time so change is noticed
+// even on filesystems with coarse timestamps
+func replace(t *testing.T, source string, target string) {
+	t.Helper()
+	require.NoError(t, os.Rename(source, target))
+	modTime := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(target, modTime, modTime))
+}
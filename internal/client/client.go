@@ -2,6 +2,9 @@ package client
 
 import (
 	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -10,6 +13,10 @@ import (
 	"github.com/DimKa163/go-metrics/internal/models"
 )
 
+// IdempotencyHeader carry key generated per batch, keeper applies batch with the same key once,
+// so batch retried after its first attempt was committed does not add counters twice
+const IdempotencyHeader = "Idempotency-Key"
+
 type MetricClient interface {
 	UpdateGauge(name string, value float64) error
 	UpdateCounter(name string, value int64) error
@@ -17,6 +24,15 @@ type MetricClient interface {
 	BatchUpdate(metrics []*models.Metric) error
 }
 
+// StatusError keeper responded with unexpected status code
+type StatusError struct {
+	Code int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d", e.Code)
+}
+
 type HTTPExecuter interface {
 	Do(req *http.Request) (*http.Response, error)
 }
@@ -26,19 +42,24 @@ type metricClient struct {
 	addr   string
 }
 
-func NewClient(addr string, transports []func(transport http.RoundTripper) http.RoundTripper) MetricClient {
+// NewClient create keeper client, tlsConfig is used for https addresses and can be nil
+func NewClient(addr string, tlsConfig *tls.Config, transports []func(transport http.RoundTripper) http.RoundTripper) MetricClient {
+	return &metricClient{
+		client: newHTTPClient(tlsConfig, transports),
+		addr:   addr,
+	}
+}
+
+func newHTTPClient(tlsConfig *tls.Config, transports []func(transport http.RoundTripper) http.RoundTripper) *http.Client {
 	var transport http.RoundTripper
-	defaultTransport := &http.Transport{}
+	defaultTransport := &http.Transport{TLSClientConfig: tlsConfig}
 	transport = defaultTransport
 	for _, t := range transports {
 		transport = t(transport)
 	}
-	return &metricClient{
-		client: &http.Client{
-			Transport: transport,
-			Timeout:   30 * time.Second,
-		},
-		addr: addr,
+	return &http.Client{
+		Transport: transport,
+		Timeout:   30 * time.Second,
 	}
 }
 
@@ -66,7 +87,7 @@ func (c *metricClient) BatchUpdate(metrics []*models.Metric) error {
 	}
 	defer res.Body.Close()
 	if res.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status code: %d", res.StatusCode)
+		return &StatusError{Code: res.StatusCode}
 	}
 	return nil
 }
@@ -82,7 +103,7 @@ func (c *metricClient) send(metric *models.Metric) error {
 	}
 	defer res.Body.Close()
 	if res.StatusCode < 200 || res.StatusCode >= 300 {
-		return fmt.Errorf("unexpected status code: %d", res.StatusCode)
+		return &StatusError{Code: res.StatusCode}
 	}
 	return nil
 }
@@ -121,6 +142,18 @@ func (c *metricClient) createBatchRequest(metrics []*models.Metric) (*http.Reque
 	if err != nil {
 		return nil, err
 	}
-
+	key, err := newIdempotencyKey()
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(IdempotencyHeader, key)
 	return req, nil
 }
+
+func newIdempotencyKey() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
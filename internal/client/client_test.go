@@ -10,6 +10,7 @@ import (
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 
+	"github.com/DimKa163/go-metrics/internal/client/tripper"
 	"github.com/DimKa163/go-metrics/internal/mocks"
 	"github.com/DimKa163/go-metrics/internal/models"
 )
@@ -85,6 +86,35 @@ func TestBatchUpdate_Success(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestBatchUpdate_IdempotencyKey(t *testing.T) {
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get(IdempotencyHeader))
+		if len(keys) == 1 {
+			// first attempt is committed but its response is lost
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	c := NewClient(server.URL, nil, []func(transport http.RoundTripper) http.RoundTripper{
+		func(transport http.RoundTripper) http.RoundTripper {
+			return tripper.NewRetryRoundTripper(transport)
+		},
+	})
+
+	metrics := []*models.Metric{models.CreateCounter("Requests", 10)}
+	assert.NoError(t, c.BatchUpdate(metrics))
+	assert.NoError(t, c.BatchUpdate(metrics))
+
+	assert.Len(t, keys, 3)
+	assert.NotEmpty(t, keys[0])
+	assert.Equal(t, keys[0], keys[1], "retry carries key of its batch")
+	assert.NotEqual(t, keys[1], keys[2], "every batch has own key")
+}
+
 func ExampleNewClient() {
 	// поднимаем тестовый сервер, чтобы не ходить наружу
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -93,7 +123,7 @@ func ExampleNewClient() {
 	defer server.Close()
 
 	// создаём клиента
-	c := NewClient(server.URL, nil)
+	c := NewClient(server.URL, nil, nil)
 
 	// обновляем gauge
 	_ = c.UpdateGauge("cpu", 0.95)
@@ -107,3 +137,36 @@ func ExampleNewClient() {
 		models.CreateCounter("hits", 42),
 	})
 }
+
+func TestTransferImport(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDoer := mocks.NewMockHttpExecuter(ctrl)
+	c := &transferClient{client: mockDoer, addr: "http://localhost"}
+
+	mockDoer.EXPECT().
+		Do(gomock.Any()).
+		DoAndReturn(func(req *http.Request) (*http.Response, error) {
+			assert.Equal(t, "http://localhost/api/import?format=csv&mode=replace", req.URL.String())
+			assert.Equal(t, "text/csv", req.Header.Get("Content-Type"))
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewBufferString(`{"count":2}`)),
+			}, nil
+		})
+	mockDoer.EXPECT().
+		Do(gomock.Any()).
+		Return(&http.Response{
+			StatusCode: http.StatusBadRequest,
+			Body:       io.NopCloser(bytes.NewBufferString(`{"error":"invalid import"}`)),
+		}, nil)
+
+	count, err := c.Import(bytes.NewBufferString("id,type,value,delta\n"), "csv", "replace")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	_, err = c.Import(bytes.NewBufferString("[]"), "json", "merge")
+	var statusErr *StatusError
+	assert.ErrorAs(t, err, &statusErr)
+	assert.Contains(t, err.Error(), "invalid import")
+}
@@ -0,0 +1,77 @@
+package client
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/DimKa163/go-metrics/internal/models"
+)
+
+type PushClient interface {
+	Push(key models.GroupKey, metrics []*models.Metric, replace bool) error
+
+	DeleteGroup(key models.GroupKey) error
+}
+
+type pushClient struct {
+	client HTTPExecuter
+	addr   string
+}
+
+func NewPushClient(addr string, tlsConfig *tls.Config, transports []func(transport http.RoundTripper) http.RoundTripper) PushClient {
+	return &pushClient{
+		client: newHTTPClient(tlsConfig, transports),
+		addr:   addr,
+	}
+}
+
+// Push send metrics to group, replace overwrites whole group
+func (c *pushClient) Push(key models.GroupKey, metrics []*models.Metric, replace bool) error {
+	data, err := json.Marshal(metrics)
+	if err != nil {
+		return err
+	}
+	method := http.MethodPost
+	if replace {
+		method = http.MethodPut
+	}
+	req, err := http.NewRequest(method, c.groupURL(key), bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	return c.do(req)
+}
+
+// DeleteGroup remove group with all metrics
+func (c *pushClient) DeleteGroup(key models.GroupKey) error {
+	req, err := http.NewRequest(http.MethodDelete, c.groupURL(key), nil)
+	if err != nil {
+		return err
+	}
+	return c.do(req)
+}
+
+func (c *pushClient) do(req *http.Request) error {
+	res, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return &StatusError{Code: res.StatusCode}
+	}
+	return nil
+}
+
+func (c *pushClient) groupURL(key models.GroupKey) string {
+	fullAddr := fmt.Sprintf("%s/metrics/job/%s", c.addr, url.PathEscape(key.Job))
+	if key.Instance != "" {
+		fullAddr += "/instance/" + url.PathEscape(key.Instance)
+	}
+	return fullAddr
+}
@@ -0,0 +1,77 @@
+package client
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/DimKa163/go-metrics/internal/transfer"
+)
+
+type TransferClient interface {
+	Export(writer io.Writer, format string) error
+
+	Import(reader io.Reader, format string, mode string) (int, error)
+}
+
+type transferClient struct {
+	client HTTPExecuter
+	addr   string
+}
+
+func NewTransferClient(addr string, tlsConfig *tls.Config, transports []func(transport http.RoundTripper) http.RoundTripper) TransferClient {
+	return &transferClient{
+		client: newHTTPClient(tlsConfig, transports),
+		addr:   addr,
+	}
+}
+
+// Export write all metrics of keeper in format to writer
+func (c *transferClient) Export(writer io.Writer, format string) error {
+	req, err := http.NewRequest(http.MethodGet, c.addr+"/api/export?format="+url.QueryEscape(format), nil)
+	if err != nil {
+		return err
+	}
+	res, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return &StatusError{Code: res.StatusCode}
+	}
+	_, err = io.Copy(writer, res.Body)
+	return err
+}
+
+// Import send metrics in format to keeper, mode is merge or replace, count of imported metrics is returned
+func (c *transferClient) Import(reader io.Reader, format string, mode string) (int, error) {
+	query := url.Values{"format": {format}, "mode": {mode}}
+	req, err := http.NewRequest(http.MethodPost, c.addr+"/api/import?"+query.Encode(), reader)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", transfer.ContentType(format))
+	res, err := c.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	var result struct {
+		Count int    `json:"count"`
+		Error string `json:"error"`
+	}
+	if res.StatusCode != http.StatusOK {
+		if json.NewDecoder(res.Body).Decode(&result) == nil && result.Error != "" {
+			return 0, fmt.Errorf("%w: %s", &StatusError{Code: res.StatusCode}, result.Error)
+		}
+		return 0, &StatusError{Code: res.StatusCode}
+	}
+	if err = json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return 0, err
+	}
+	return result.Count, nil
+}
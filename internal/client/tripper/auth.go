@@ -0,0 +1,21 @@
+package tripper
+
+import "net/http"
+
+type AuthTripper struct {
+	rt    http.RoundTripper
+	token string
+}
+
+// NewAuthTripper send token as bearer Authorization header
+func NewAuthTripper(rt http.RoundTripper, token string) http.RoundTripper {
+	return &AuthTripper{
+		rt:    rt,
+		token: token,
+	}
+}
+
+func (rt *AuthTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	req.Header.Set("Authorization", "Bearer "+rt.token)
+	return rt.rt.RoundTrip(req)
+}
@@ -2,9 +2,11 @@ package tripper
 
 import (
 	"bytes"
-	"github.com/DimKa163/go-metrics/internal/crypto"
 	"io"
 	"net/http"
+	"strconv"
+
+	"github.com/DimKa163/go-metrics/internal/crypto"
 )
 
 type CryptoTripper struct {
@@ -17,7 +19,7 @@ func NewCryptoTripper(rt http.RoundTripper, encrypter *crypto.Encrypter) *Crypto
 }
 
 func (t *CryptoTripper) RoundTrip(req *http.Request) (*http.Response, error) {
-	if req.Body != nil {
+	if req.Body != nil && req.Body != http.NoBody {
 		body, err := io.ReadAll(req.Body)
 		if err != nil {
 			return nil, err
@@ -26,6 +28,8 @@ func (t *CryptoTripper) RoundTrip(req *http.Request) (*http.Response, error) {
 		if err != nil {
 			return nil, err
 		}
+		req.ContentLength = int64(len(cipherBody))
+		req.Header.Set("Content-Length", strconv.Itoa(len(cipherBody)))
 		req.Body = io.NopCloser(bytes.NewReader(cipherBody))
 	}
 	return t.rt.RoundTrip(req)
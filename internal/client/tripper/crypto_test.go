@@ -0,0 +1,77 @@
+package tripper
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DimKa163/go-metrics/internal/crypto"
+	"github.com/DimKa163/go-metrics/internal/mhttp/middleware"
+)
+
+func TestCryptoTripperWithMiddleware(t *testing.T) {
+	encrypter, decrypter := newCryptoPair(t)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.GzipMiddleware())
+	router.Use(middleware.CryptoMiddleware(decrypter))
+	router.POST("/updates", func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		require.NoError(t, err)
+		sum := sha256.Sum256(body)
+		c.Data(http.StatusOK, "application/octet-stream", sum[:])
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	client := &http.Client{Transport: NewCryptoTripper(NewGzip(http.DefaultTransport), encrypter)}
+	body := make([]byte, 3<<20)
+	_, err := rand.Read(body)
+	require.NoError(t, err)
+
+	res, err := client.Post(server.URL+"/updates", "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	got, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	expected := sha256.Sum256(body)
+	assert.Equal(t, expected[:], got)
+
+	plain, err := http.Post(server.URL+"/updates", "text/plain", bytes.NewReader([]byte("not encrypted")))
+	require.NoError(t, err)
+	defer plain.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, plain.StatusCode)
+}
+
+func newCryptoPair(t *testing.T) (*crypto.Encrypter, *crypto.Decrypter) {
+	t.Helper()
+	dir := t.TempDir()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	publicDER, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	publicPath := filepath.Join(dir, "public.pem")
+	require.NoError(t, os.WriteFile(publicPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}), 0600))
+	privatePath := filepath.Join(dir, "private.pem")
+	require.NoError(t, os.WriteFile(privatePath, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0600))
+
+	encrypter, err := crypto.NewEncrypter(publicPath, "")
+	require.NoError(t, err)
+	decrypter, err := crypto.NewDecrypter(privatePath)
+	require.NoError(t, err)
+	return encrypter, decrypter
+}
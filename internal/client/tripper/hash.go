@@ -3,10 +3,18 @@ package tripper
 import (
 	"bytes"
 	"crypto/hmac"
-	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"io"
 	"net/http"
+	"time"
+
+	"github.com/DimKa163/go-metrics/internal/signature"
+)
+
+var (
+	ErrMissingSignature = errors.New("response signature is missing")
+	ErrInvalidSignature = errors.New("response signature is invalid")
 )
 
 type HashTripper struct {
@@ -21,6 +29,9 @@ func NewHashTripper(rt http.RoundTripper, key string) http.RoundTripper {
 	}
 }
 
+// RoundTrip sign method, path, body and fresh timestamp and nonce and verify response signature.
+// Successful response must be signed, unsigned error responses come from keeper before signature
+// check and are passed through
 func (rt *HashTripper) RoundTrip(req *http.Request) (*http.Response, error) {
 	var body []byte
 	var err error
@@ -29,16 +40,48 @@ func (rt *HashTripper) RoundTrip(req *http.Request) (*http.Response, error) {
 		if err != nil {
 			return nil, err
 		}
-		hsh := hmac.New(sha256.New, []byte(rt.key))
-		_, err := hsh.Write(body)
-		if err != nil {
-			return nil, err
-		}
-		h := hsh.Sum(nil)
-		str := hex.EncodeToString(h)
-		req.Header.Set("HashSHA256", str)
 		req.Body = io.NopCloser(bytes.NewReader(body))
-		hsh.Reset()
 	}
-	return rt.rt.RoundTrip(req)
+	nonce, err := signature.NewNonce()
+	if err != nil {
+		return nil, err
+	}
+	timestamp := signature.Timestamp(time.Now())
+	sign := signature.Sign(rt.key, req.Method, signature.Path(req.URL), timestamp, nonce, body)
+	req.Header.Set(signature.Header, hex.EncodeToString(sign))
+	req.Header.Set(signature.TimestampHeader, timestamp)
+	req.Header.Set(signature.NonceHeader, nonce)
+	res, err := rt.rt.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if err = rt.verify(res); err != nil {
+		res.Body.Close()
+		return nil, err
+	}
+	return res, nil
+}
+
+func (rt *HashTripper) verify(res *http.Response) error {
+	header := res.Header.Get(signature.Header)
+	if header == "" {
+		if res.StatusCode >= http.StatusOK && res.StatusCode < http.StatusMultipleChoices {
+			return ErrMissingSignature
+		}
+		return nil
+	}
+	sign, err := hex.DecodeString(header)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	if !hmac.Equal(sign, signature.SignResponse(rt.key, body)) {
+		return ErrInvalidSignature
+	}
+	return nil
 }
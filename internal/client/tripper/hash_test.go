@@ -0,0 +1,150 @@
+package tripper
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DimKa163/go-metrics/internal/mhttp/middleware"
+	"github.com/DimKa163/go-metrics/internal/signature"
+)
+
+// recorder keep copy of last request sent through transport
+type recorder struct {
+	rt   http.RoundTripper
+	req  *http.Request
+	body []byte
+}
+
+func (r *recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	r.req = req.Clone(req.Context())
+	r.body = body
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return r.rt.RoundTrip(req)
+}
+
+func TestHashTripperReplayProtection(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.Hash("secret", signature.NewReplayGuard(time.Minute, 100)))
+	applied := 0
+	router.POST("/updates", func(c *gin.Context) {
+		applied++
+		c.Status(http.StatusOK)
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	captured := &recorder{rt: http.DefaultTransport}
+	client := &http.Client{Transport: NewHashTripper(captured, "secret")}
+	for i := 0; i < 2; i++ {
+		res, err := client.Post(server.URL+"/updates", "application/json", strings.NewReader(`[]`))
+		require.NoError(t, err)
+		res.Body.Close()
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+	}
+
+	replay := func(path string, body []byte) int {
+		req, err := http.NewRequest(http.MethodPost, server.URL+path, bytes.NewReader(body))
+		require.NoError(t, err)
+		req.Header = captured.req.Header.Clone()
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		return res.StatusCode
+	}
+	assert.Equal(t, http.StatusUnauthorized, replay("/updates", captured.body), "replayed request")
+	assert.Equal(t, http.StatusBadRequest, replay("/updates?x=1", captured.body), "path is signed")
+	assert.Equal(t, http.StatusBadRequest, replay("/updates", []byte(`[{}]`)), "body is signed")
+	assert.Equal(t, 2, applied)
+}
+
+func TestHashMiddlewareReleasesNonceOnServerError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.Hash("secret", signature.NewReplayGuard(time.Minute, 100)))
+	calls := 0
+	router.POST("/updates", func(c *gin.Context) {
+		calls++
+		if calls == 1 {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	client := &http.Client{Transport: NewHashTripper(NewRetryRoundTripper(http.DefaultTransport), "secret")}
+	res, err := client.Post(server.URL+"/updates", "application/json", strings.NewReader(`[]`))
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, 2, calls)
+}
+
+func TestHashTripperVerifiesResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.Hash("secret", signature.NewReplayGuard(time.Minute, 100)))
+	router.POST("/update/", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"id": "HeapAlloc"})
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	client := &http.Client{Transport: NewHashTripper(NewRetryRoundTripper(http.DefaultTransport), "secret")}
+	res, err := client.Post(server.URL+"/update/", "application/json", strings.NewReader(`{}`))
+	require.NoError(t, err)
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"id":"HeapAlloc"}`, string(body), "verified body is readable")
+}
+
+func TestHashTripperRejectsUnsignedSuccess(t *testing.T) {
+	cases := []struct {
+		name     string
+		status   int
+		header   string
+		expected error
+	}{
+		{"unsigned success", http.StatusOK, "", ErrMissingSignature},
+		{"tampered body", http.StatusOK, hex.EncodeToString(signature.SignResponse("secret", []byte("original"))), ErrInvalidSignature},
+		{"unsigned error", http.StatusForbidden, "", nil},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if c.header != "" {
+					w.Header().Set(signature.Header, c.header)
+				}
+				w.WriteHeader(c.status)
+				_, _ = w.Write([]byte("tampered"))
+			}))
+			defer server.Close()
+			client := &http.Client{Transport: NewHashTripper(http.DefaultTransport, "secret")}
+			res, err := client.Post(server.URL, "application/json", strings.NewReader(`{}`))
+			if c.expected != nil {
+				assert.ErrorIs(t, err, c.expected)
+				return
+			}
+			require.NoError(t, err)
+			res.Body.Close()
+			assert.Equal(t, c.status, res.StatusCode)
+		})
+	}
+}
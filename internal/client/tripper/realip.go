@@ -0,0 +1,36 @@
+package tripper
+
+import (
+	"net"
+	"net/http"
+)
+
+const RealIPHeader = "X-Real-IP"
+
+type RealIPTripper struct {
+	rt http.RoundTripper
+	ip string
+}
+
+// NewRealIPTripper set X-Real-IP header of every request to ip
+func NewRealIPTripper(rt http.RoundTripper, ip net.IP) http.RoundTripper {
+	return &RealIPTripper{
+		rt: rt,
+		ip: ip.String(),
+	}
+}
+
+func (rt *RealIPTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	req.Header.Set(RealIPHeader, rt.ip)
+	return rt.rt.RoundTrip(req)
+}
+
+// OutboundIP address of interface used to reach addr, no packets are sent
+func OutboundIP(addr string) (net.IP, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP, nil
+}
@@ -0,0 +1,40 @@
+package tripper
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DimKa163/go-metrics/internal/mhttp/middleware"
+)
+
+func TestRealIPTripperWithTrustedSubnet(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := httptest.NewServer(nil)
+	defer server.Close()
+	addr := strings.TrimPrefix(server.URL, "http://")
+	ip, err := OutboundIP(addr)
+	require.NoError(t, err)
+	assert.True(t, ip.IsLoopback())
+
+	write, err := middleware.ParseSubnets(ip.String())
+	require.NoError(t, err)
+	router := gin.New()
+	router.Use(middleware.TrustedSubnet(middleware.SubnetPolicy{Write: write, Proxies: write}))
+	router.POST("/updates", func(c *gin.Context) {
+		assert.Equal(t, ip.String(), c.GetHeader(middleware.RealIPHeader))
+		c.Status(http.StatusOK)
+	})
+	server.Config.Handler = router
+
+	client := &http.Client{Transport: NewRealIPTripper(http.DefaultTransport, ip)}
+	res, err := client.Post(server.URL+"/updates", "application/json", nil)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+}
@@ -4,10 +4,18 @@ import (
 	"bytes"
 	"io"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/cenkalti/backoff/v5"
 )
 
+// maxRetryAfter longest Retry-After delay honoured, longer delays are shortened to it
+const maxRetryAfter = 60
+
+// RetryRoundTripper resend request failed with transient status. Every attempt passes inner trippers
+// anew, so it must wrap hash and crypto trippers to get fresh nonce and ciphertext for each attempt,
+// otherwise keeper refuses retry as replay
 type RetryRoundTripper struct {
 	rt http.RoundTripper
 }
@@ -24,27 +32,31 @@ func (rt *RetryRoundTripper) RoundTrip(req *http.Request) (*http.Response, error
 		if err != nil {
 			return nil, err
 		}
-		req.Body = io.NopCloser(bytes.NewBuffer(body))
+		req.Body.Close()
 	}
 	times := [3]int{1, 3, 5}
 	attempt := 0
 	return backoff.Retry(req.Context(), func() (*http.Response, error) {
-
-		response, err := rt.rt.RoundTrip(req)
-		if err != nil {
-			return nil, backoff.Permanent(err)
+		// inner trippers change request they get, so every attempt starts from clean copy
+		attemptReq := req.Clone(req.Context())
+		if req.Body != nil {
+			attemptReq.Body = io.NopCloser(bytes.NewReader(body))
 		}
-		if err = rt.drain(response); err != nil {
+		response, err := rt.rt.RoundTrip(attemptReq)
+		if err != nil {
 			return nil, backoff.Permanent(err)
 		}
-
 		if rt.shouldRetry(response) && attempt < len(times) {
-			if req.Body != nil {
-				req.Body = io.NopCloser(bytes.NewBuffer(body))
+			// only discarded response is drained, returned one keeps its body
+			if err = rt.drain(response); err != nil {
+				return nil, backoff.Permanent(err)
+			}
+			delay := times[attempt]
+			if seconds, ok := retryAfter(response); ok {
+				delay = seconds
 			}
-			at := attempt
 			attempt++
-			return nil, backoff.RetryAfter(times[at])
+			return nil, backoff.RetryAfter(delay)
 		}
 		return response, nil
 	}, backoff.WithBackOff(backoff.NewExponentialBackOff()))
@@ -52,17 +64,36 @@ func (rt *RetryRoundTripper) RoundTrip(req *http.Request) (*http.Response, error
 
 func (rt *RetryRoundTripper) shouldRetry(resp *http.Response) bool {
 	switch resp.StatusCode {
-	case http.StatusRequestTimeout:
-	case http.StatusTooManyRequests:
-	case http.StatusBadGateway:
-	case http.StatusGatewayTimeout:
-	case http.StatusServiceUnavailable:
-	case http.StatusInternalServerError:
+	case http.StatusRequestTimeout,
+		http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusGatewayTimeout,
+		http.StatusServiceUnavailable,
+		http.StatusInternalServerError:
 		return true
+	case http.StatusConflict:
+		// request with the same idempotency key is being applied, keeper tells when to ask again
+		return resp.Header.Get("Retry-After") != ""
 	default:
 		return false
 	}
-	return false
+}
+
+// retryAfter delay in seconds requested by server with Retry-After header
+func retryAfter(response *http.Response) (int, bool) {
+	header := response.Header.Get("Retry-After")
+	if header == "" {
+		return 0, false
+	}
+	seconds, err := strconv.Atoi(header)
+	if err != nil {
+		date, err := http.ParseTime(header)
+		if err != nil {
+			return 0, false
+		}
+		seconds = int(time.Until(date).Round(time.Second).Seconds())
+	}
+	return max(0, min(seconds, maxRetryAfter)), true
 }
 
 func (rt *RetryRoundTripper) drain(response *http.Response) error {
@@ -0,0 +1,109 @@
+package tripper
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DimKa163/go-metrics/internal/mhttp/middleware"
+	"github.com/DimKa163/go-metrics/internal/signature"
+)
+
+func TestRetryRoundTripperHonoursRetryAfter(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: NewRetryRoundTripper(http.DefaultTransport)}
+	start := time.Now()
+	res, err := client.Post(server.URL, "application/json", strings.NewReader(`[]`))
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, 2, calls)
+	assert.Less(t, time.Since(start), time.Second, "fixed 1 second delay must not be used")
+}
+
+func TestRetryRoundTripperWaitsForPendingKey(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: NewRetryRoundTripper(http.DefaultTransport)}
+	res, err := client.Post(server.URL, "application/json", strings.NewReader(`[]`))
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode, "conflict with Retry-After is retried")
+	assert.Equal(t, 2, calls)
+}
+
+func TestRetryRoundTripperSignsEveryAttempt(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.Hash("secret", signature.NewReplayGuard(time.Minute, 100)))
+	calls := 0
+	router.POST("/updates", func(c *gin.Context) {
+		calls++
+		if calls == 1 {
+			// keeper applied batch but proxy in front of it failed
+			c.Header("Retry-After", "0")
+			c.Status(http.StatusBadGateway)
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	client := &http.Client{Transport: NewRetryRoundTripper(NewHashTripper(http.DefaultTransport, "secret"))}
+	res, err := client.Post(server.URL+"/updates", "application/json", strings.NewReader(`[]`))
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode, "retry gets fresh nonce instead of being refused as replay")
+	assert.Equal(t, 2, calls)
+}
+
+func TestRetryAfter(t *testing.T) {
+	cases := []struct {
+		header   string
+		expected int
+		ok       bool
+	}{
+		{"", 0, false},
+		{"3", 3, true},
+		{"3600", maxRetryAfter, true},
+		{"soon", 0, false},
+		{time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), 0, true},
+	}
+	for _, c := range cases {
+		res := &http.Response{Header: http.Header{}}
+		if c.header != "" {
+			res.Header.Set("Retry-After", c.header)
+		}
+		seconds, ok := retryAfter(res)
+		assert.Equal(t, c.ok, ok, c.header)
+		assert.Equal(t, c.expected, seconds, c.header)
+	}
+}
@@ -1,68 +1,211 @@
 package crypto
 
 import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
 	"crypto/rand"
 	"crypto/rsa"
-	"crypto/x509"
-	"encoding/pem"
+	"crypto/sha256"
+	"encoding/binary"
 	"fmt"
+	"sync"
+
 	"github.com/pkg/errors"
-	"os"
+)
+
+// Envelope layout: magic | version | [key id length (uint8) | key id] | wrapped key length (uint16) |
+// RSA-OAEP wrapped AES key | GCM nonce | ciphertext. Key id is present since v2.
+// Everything before the nonce is authenticated as additional data.
+const (
+	EnvelopeV1 byte = 1
+	EnvelopeV2 byte = 2
+
+	keySize = 32
+)
+
+var (
+	envelopeMagic = []byte("GMEV")
+
+	ErrInvalidEnvelope     = errors.New("invalid encrypted envelope")
+	ErrUnsupportedEnvelope = errors.New("unsupported envelope version")
 )
 
 type Encrypter struct {
-	key *rsa.PublicKey
+	key   *rsa.PublicKey
+	keyID string
 }
 
-func NewEncrypter(path string) (*Encrypter, error) {
-	data, err := os.ReadFile(path)
+// NewEncrypter load public key from file or key directory.
+// keyID pins id written to envelope and selects key in directory, key fingerprint is used when empty.
+// Pinned id must be file name of key without extension or its fingerprint, so decrypter holding
+// private key of the same name finds it
+func NewEncrypter(path string, keyID string) (*Encrypter, error) {
+	files, err := keyFiles(path)
 	if err != nil {
 		return nil, err
 	}
-	block, _ := pem.Decode(data)
-	if block == nil || block.Type != "PUBLIC KEY" {
-		return nil, errors.New("x509: failed to parse RSA public key")
+	if keyID == "" && len(files) > 1 {
+		return nil, fmt.Errorf("%s contains several keys, key id must be pinned", path)
 	}
-	cert, err := x509.ParsePKIXPublicKey(data)
-	if err != nil {
-		return nil, err
+	for _, file := range files {
+		key, err := readPublicKey(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		switch keyID {
+		case "":
+			return newEncrypter(key, KeyID(key))
+		case keyName(file), KeyID(key):
+			return newEncrypter(key, keyID)
+		}
 	}
-	pub, ok := cert.(*rsa.PublicKey)
-	if !ok {
-		return nil, fmt.Errorf("not RSA public key")
+	return nil, fmt.Errorf("%w: %s is neither file name nor fingerprint of key in %s", ErrUnknownKey, keyID, path)
+}
+
+func newEncrypter(key *rsa.PublicKey, keyID string) (*Encrypter, error) {
+	if len(keyID) > 255 {
+		return nil, errors.New("key id is too long")
 	}
-	return &Encrypter{key: pub}, nil
+	return &Encrypter{key: key, keyID: keyID}, nil
 }
 
+// KeyID id written to every envelope
+func (e *Encrypter) KeyID() string {
+	return e.keyID
+}
+
+// Encrypt seal plaintext with fresh AES-256-GCM key wrapped by RSA-OAEP
 func (e *Encrypter) Encrypt(plaintext []byte) ([]byte, error) {
-	cipherData, err := rsa.EncryptPKCS1v15(rand.Reader, e.key, plaintext)
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	wrapped, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, e.key, key, envelopeMagic)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
 	if err != nil {
 		return nil, err
 	}
-	return cipherData, nil
+	header := make([]byte, 0, len(envelopeMagic)+4+len(e.keyID)+len(wrapped))
+	header = append(header, envelopeMagic...)
+	header = append(header, EnvelopeV2, byte(len(e.keyID)))
+	header = append(header, e.keyID...)
+	header = binary.BigEndian.AppendUint16(header, uint16(len(wrapped)))
+	header = append(header, wrapped...)
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(header)+len(nonce)+len(plaintext)+gcm.Overhead())
+	out = append(out, header...)
+	out = append(out, nonce...)
+	return gcm.Seal(out, nonce, plaintext, header), nil
 }
 
+// Decrypter hold every active private key, keys can be reloaded without restart
 type Decrypter struct {
-	key *rsa.PrivateKey
+	path  string
+	mutex sync.RWMutex
+	ring  *keyring
 }
 
+// NewDecrypter load private key from file or every key from directory
 func NewDecrypter(path string) (*Decrypter, error) {
-	data, err := os.ReadFile(path)
+	ring, err := loadKeyring(path)
 	if err != nil {
 		return nil, err
 	}
+	return &Decrypter{path: path, ring: ring}, nil
+}
+
+// Reload re-read keys from disk, current keys stay active when loading fails
+func (d *Decrypter) Reload() error {
+	ring, err := loadKeyring(d.path)
+	if err != nil {
+		return err
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.ring = ring
+	return nil
+}
 
-	block, _ := pem.Decode(data)
-	if block == nil || block.Type != "RSA PRIVATE KEY" {
-		return nil, errors.New("invalid PEM block type")
+// Decrypt open envelope produced by Encrypter
+func (d *Decrypter) Decrypt(ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < len(envelopeMagic)+1 || !bytes.Equal(ciphertext[:len(envelopeMagic)], envelopeMagic) {
+		return nil, ErrInvalidEnvelope
 	}
-	cert, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	rest := ciphertext[len(envelopeMagic):]
+	version := rest[0]
+	rest = rest[1:]
+	d.mutex.RLock()
+	ring := d.ring
+	d.mutex.RUnlock()
+
+	candidates := ring.all
+	switch version {
+	case EnvelopeV1:
+	case EnvelopeV2:
+		if len(rest) < 1 || len(rest) < 1+int(rest[0]) {
+			return nil, ErrInvalidEnvelope
+		}
+		keyID := string(rest[1 : 1+int(rest[0])])
+		rest = rest[1+int(rest[0]):]
+		key, ok := ring.keys[keyID]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownKey, keyID)
+		}
+		candidates = []*rsa.PrivateKey{key}
+	default:
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedEnvelope, version)
+	}
+	if len(rest) < 2 {
+		return nil, ErrInvalidEnvelope
+	}
+	wrappedLen := int(binary.BigEndian.Uint16(rest[:2]))
+	rest = rest[2:]
+	if len(rest) < wrappedLen {
+		return nil, ErrInvalidEnvelope
+	}
+	header := ciphertext[:len(ciphertext)-len(rest)+wrappedLen]
+	wrapped := rest[:wrappedLen]
+	rest = rest[wrappedLen:]
+
+	var err error
+	for _, candidate := range candidates {
+		var key []byte
+		key, err = rsa.DecryptOAEP(sha256.New(), nil, candidate, wrapped, envelopeMagic)
+		if err != nil {
+			continue
+		}
+		return open(key, header, rest)
+	}
+	return nil, fmt.Errorf("%w: %v", ErrInvalidEnvelope, err)
+}
+
+func open(key []byte, header []byte, sealed []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
 	if err != nil {
 		return nil, err
 	}
-	return &Decrypter{key: cert}, nil
+	if len(sealed) < gcm.NonceSize()+gcm.Overhead() {
+		return nil, ErrInvalidEnvelope
+	}
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], header)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidEnvelope, err)
+	}
+	return plaintext, nil
 }
 
-func (d *Decrypter) Decrypt(ciphertext []byte) ([]byte, error) {
-	return rsa.DecryptPKCS1v15(rand.Reader, d.key, ciphertext)
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
 }
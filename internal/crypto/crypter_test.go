@@ -0,0 +1,160 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptDecryptLargeBody(t *testing.T) {
+	encrypter, decrypter := newTestPair(t)
+	for _, size := range []int{0, 1, 245, 4 << 20} {
+		plaintext := make([]byte, size)
+		_, err := rand.Read(plaintext)
+		require.NoError(t, err)
+
+		ciphertext, err := encrypter.Encrypt(plaintext)
+		require.NoError(t, err)
+		assert.False(t, size >= 16 && bytes.Contains(ciphertext, plaintext))
+
+		decrypted, err := decrypter.Decrypt(ciphertext)
+		require.NoError(t, err)
+		assert.True(t, bytes.Equal(plaintext, decrypted), "size %d", size)
+	}
+}
+
+func TestDecryptRejectsTamperedEnvelope(t *testing.T) {
+	encrypter, decrypter := newTestPair(t)
+	ciphertext, err := encrypter.Encrypt([]byte(`[{"id":"Alloc","type":"gauge","value":1}]`))
+	require.NoError(t, err)
+
+	tampered := bytes.Clone(ciphertext)
+	tampered[len(tampered)-1] ^= 0xff
+	_, err = decrypter.Decrypt(tampered)
+	assert.ErrorIs(t, err, ErrInvalidEnvelope)
+
+	version := bytes.Clone(ciphertext)
+	version[len(envelopeMagic)] = 99
+	_, err = decrypter.Decrypt(version)
+	assert.ErrorIs(t, err, ErrUnsupportedEnvelope)
+
+	_, err = decrypter.Decrypt([]byte("plain text body"))
+	assert.ErrorIs(t, err, ErrInvalidEnvelope)
+}
+
+func TestDecryptWithOtherKeyFails(t *testing.T) {
+	encrypter, _ := newTestPair(t)
+	_, decrypter := newTestPair(t)
+	ciphertext, err := encrypter.Encrypt([]byte("secret"))
+	require.NoError(t, err)
+
+	_, err = decrypter.Decrypt(ciphertext)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+}
+
+func TestKeyDirectoryRotation(t *testing.T) {
+	publicDir := t.TempDir()
+	privateDir := t.TempDir()
+	writeTestKey(t, publicDir, privateDir, "2024-01")
+	newPublic, newPrivate := writeTestKey(t, publicDir, t.TempDir(), "2024-02")
+
+	decrypter, err := NewDecrypter(privateDir)
+	require.NoError(t, err)
+
+	_, err = NewEncrypter(publicDir, "")
+	assert.Error(t, err, "key must be pinned when directory holds several keys")
+
+	oldEncrypter, err := NewEncrypter(publicDir, "2024-01")
+	require.NoError(t, err)
+	assert.Equal(t, "2024-01", oldEncrypter.KeyID())
+	ciphertext, err := oldEncrypter.Encrypt([]byte("old key"))
+	require.NoError(t, err)
+	plaintext, err := decrypter.Decrypt(ciphertext)
+	require.NoError(t, err)
+	assert.Equal(t, "old key", string(plaintext))
+
+	newEncrypter, err := NewEncrypter(newPublic, "")
+	require.NoError(t, err)
+	newCiphertext, err := newEncrypter.Encrypt([]byte("new key"))
+	require.NoError(t, err)
+	_, err = decrypter.Decrypt(newCiphertext)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+
+	data, err := os.ReadFile(newPrivate)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(privateDir, "2024-02.pem"), data, 0600))
+	require.NoError(t, decrypter.Reload())
+
+	plaintext, err = decrypter.Decrypt(newCiphertext)
+	require.NoError(t, err)
+	assert.Equal(t, "new key", string(plaintext))
+	_, err = decrypter.Decrypt(ciphertext)
+	assert.NoError(t, err, "old key stays active during rotation")
+}
+
+func TestReloadKeepsKeysOnFailure(t *testing.T) {
+	dir := t.TempDir()
+	publicPath, privatePath := writeTestKey(t, dir, dir, "key")
+	encrypter, err := NewEncrypter(publicPath, "")
+	require.NoError(t, err)
+	decrypter, err := NewDecrypter(privatePath)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(privatePath, []byte("broken"), 0600))
+	assert.Error(t, decrypter.Reload())
+
+	ciphertext, err := encrypter.Encrypt([]byte("still works"))
+	require.NoError(t, err)
+	_, err = decrypter.Decrypt(ciphertext)
+	assert.NoError(t, err)
+}
+
+func newTestPair(t *testing.T) (*Encrypter, *Decrypter) {
+	t.Helper()
+	dir := t.TempDir()
+	publicPath, privatePath := writeTestKey(t, dir, dir, "key")
+	encrypter, err := NewEncrypter(publicPath, "")
+	require.NoError(t, err)
+	decrypter, err := NewDecrypter(privatePath)
+	require.NoError(t, err)
+	return encrypter, decrypter
+}
+
+func writeTestKey(t *testing.T, publicDir string, privateDir string, name string) (string, string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	publicDER, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	publicPath := filepath.Join(publicDir, name+".pub")
+	require.NoError(t, os.WriteFile(publicPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}), 0600))
+	privatePath := filepath.Join(privateDir, name+".pem")
+	require.NoError(t, os.WriteFile(privatePath, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0600))
+	return publicPath, privatePath
+}
+
+func TestPinnedKeyIDOfSingleFile(t *testing.T) {
+	dir := t.TempDir()
+	publicPath, privatePath := writeTestKey(t, dir, dir, "2024-01")
+	decrypter, err := NewDecrypter(privatePath)
+	require.NoError(t, err)
+
+	encrypter, err := NewEncrypter(publicPath, "2024-01")
+	require.NoError(t, err)
+	ciphertext, err := encrypter.Encrypt([]byte("pinned"))
+	require.NoError(t, err)
+	plaintext, err := decrypter.Decrypt(ciphertext)
+	require.NoError(t, err, "single key file is found by its name")
+	assert.Equal(t, "pinned", string(plaintext))
+
+	_, err = NewEncrypter(publicPath, "prod")
+	assert.ErrorIs(t, err, ErrUnknownKey, "id decrypter can't resolve is rejected at startup")
+}
@@ -0,0 +1,140 @@
+package crypto
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var ErrUnknownKey = errors.New("unknown key id")
+
+// KeyID fingerprint of public key used when key id is not pinned explicitly
+func KeyID(key *rsa.PublicKey) string {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:8])
+}
+
+// keyFiles return path itself or every PEM file in directory
+func keyFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		switch filepath.Ext(entry.Name()) {
+		case ".pem", ".pub", ".key":
+			files = append(files, filepath.Join(path, entry.Name()))
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no keys found in %s", path)
+	}
+	return files, nil
+}
+
+// keyName file name without extension, used as key id alias
+func keyName(path string) string {
+	name := filepath.Base(path)
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+func readPublicKey(path string) (*rsa.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("x509: failed to parse RSA public key")
+	}
+	switch block.Type {
+	case "PUBLIC KEY":
+		cert, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		pub, ok := cert.(*rsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("not RSA public key")
+		}
+		return pub, nil
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, errors.New("x509: failed to parse RSA public key")
+	}
+}
+
+func readPrivateKey(path string) (*rsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("invalid PEM block type")
+	}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		cert, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		key, ok := cert.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("not RSA private key")
+		}
+		return key, nil
+	default:
+		return nil, errors.New("invalid PEM block type")
+	}
+}
+
+// keyring private keys indexed by fingerprint and by file name
+type keyring struct {
+	keys map[string]*rsa.PrivateKey
+	all  []*rsa.PrivateKey
+}
+
+func loadKeyring(path string) (*keyring, error) {
+	files, err := keyFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	ring := &keyring{keys: make(map[string]*rsa.PrivateKey)}
+	for _, file := range files {
+		key, err := readPrivateKey(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		ring.all = append(ring.all, key)
+		ring.keys[KeyID(&key.PublicKey)] = key
+		ring.keys[keyName(file)] = key
+	}
+	return ring, nil
+}
@@ -0,0 +1,30 @@
+// Package dashboard templates and assets of keeper web dashboard, compiled into binary so
+// dashboard works without files next to it and without any CDN.
+package dashboard
+
+import (
+	"embed"
+	"html/template"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed templates/*.tmpl
+var templates embed.FS
+
+//go:embed static
+var static embed.FS
+
+// Templates parsed pages, index.tmpl lists metrics and metric.tmpl shows one metric
+func Templates() *template.Template {
+	return template.Must(template.New("").ParseFS(templates, "templates/*.tmpl"))
+}
+
+// Static scripts and styles of pages
+func Static() http.FileSystem {
+	files, err := fs.Sub(static, "static")
+	if err != nil {
+		panic(err)
+	}
+	return http.FS(files)
+}
@@ -0,0 +1,194 @@
+(function () {
+    "use strict";
+
+    var REFRESH_INTERVAL = 5000;
+    var SVG = "http://www.w3.org/2000/svg";
+
+    function el(tag, attrs, text) {
+        var node = document.createElement(tag);
+        Object.keys(attrs || {}).forEach(function (name) {
+            node.setAttribute(name, attrs[name]);
+        });
+        if (text !== undefined) {
+            node.textContent = text;
+        }
+        return node;
+    }
+
+    function svg(tag, attrs) {
+        var node = document.createElementNS(SVG, tag);
+        Object.keys(attrs || {}).forEach(function (name) {
+            node.setAttribute(name, attrs[name]);
+        });
+        return node;
+    }
+
+    function current(metric) {
+        if (metric.stale) {
+            return null;
+        }
+        return metric.type === "counter" ? metric.delta : metric.value;
+    }
+
+    function valueNode(metric) {
+        if (metric.stale) {
+            return el("span", {"class": "stale"}, "stale");
+        }
+        return document.createTextNode(String(current(metric)));
+    }
+
+    // polyline points of samples scaled into width x height box
+    function points(samples, width, height, pad) {
+        var minT = samples[0].t, maxT = samples[samples.length - 1].t;
+        var minV = Infinity, maxV = -Infinity;
+        samples.forEach(function (s) {
+            minV = Math.min(minV, s.v);
+            maxV = Math.max(maxV, s.v);
+        });
+        var spanT = maxT - minT || 1, spanV = maxV - minV || 1;
+        return {
+            min: minV,
+            max: maxV,
+            points: samples.map(function (s) {
+                var x = pad + (s.t - minT) / spanT * (width - 2 * pad);
+                var y = height - pad - (s.v - minV) / spanV * (height - 2 * pad);
+                return x.toFixed(1) + "," + y.toFixed(1);
+            }).join(" ")
+        };
+    }
+
+    function sparkline(samples) {
+        var box = svg("svg", {"class": "sparkline", viewBox: "0 0 120 24", preserveAspectRatio: "none"});
+        if (samples && samples.length > 1) {
+            box.appendChild(svg("polyline", {points: points(samples, 120, 24, 2).points}));
+        }
+        return box;
+    }
+
+    function chart(samples) {
+        var width = 800, height = 240, pad = 28;
+        if (!samples || samples.length < 2) {
+            return el("div", {"class": "empty"}, "Not enough history yet");
+        }
+        var scaled = points(samples, width, height, pad);
+        var box = svg("svg", {viewBox: "0 0 " + width + " " + height, preserveAspectRatio: "none"});
+        box.appendChild(svg("polyline", {points: scaled.points}));
+        [[scaled.max, pad], [scaled.min, height - pad]].forEach(function (label) {
+            var text = svg("text", {x: 2, y: label[1]});
+            text.textContent = String(+label[0].toPrecision(6));
+            box.appendChild(text);
+        });
+        return box;
+    }
+
+    function load(url, done) {
+        var request = new XMLHttpRequest();
+        request.open("GET", url);
+        request.setRequestHeader("Accept", "application/json");
+        request.onload = function () {
+            if (request.status === 200) {
+                done(JSON.parse(request.responseText));
+            }
+        };
+        request.send();
+    }
+
+    function every(refresh) {
+        var toggle = document.getElementById("auto-refresh");
+        setInterval(function () {
+            if (toggle.checked && !document.hidden) {
+                refresh();
+            }
+        }, REFRESH_INTERVAL);
+        refresh();
+    }
+
+    function index(table) {
+        var search = document.getElementById("search");
+        var type = document.getElementById("type");
+        var count = document.getElementById("count");
+        var body = table.tBodies[0];
+
+        function filter() {
+            var q = search.value.trim().toLowerCase();
+            var shown = 0;
+            Array.prototype.forEach.call(body.rows, function (row) {
+                if (!row.dataset.id) {
+                    return;
+                }
+                var visible = (!type.value || row.dataset.type === type.value) &&
+                    row.dataset.id.toLowerCase().indexOf(q) !== -1;
+                row.classList.toggle("hidden", !visible);
+                if (visible) {
+                    shown++;
+                }
+            });
+            count.textContent = shown + " metrics";
+            var params = new URLSearchParams();
+            if (q) {
+                params.set("q", search.value.trim());
+            }
+            if (type.value) {
+                params.set("type", type.value);
+            }
+            var query = params.toString();
+            history.replaceState(null, "", query ? "?" + query : location.pathname);
+        }
+
+        function render(metrics) {
+            var rows = document.createDocumentFragment();
+            metrics.forEach(function (metric) {
+                var row = el("tr", {"data-id": metric.id, "data-type": metric.type});
+                var name = el("td");
+                name.appendChild(el("a", {href: "/metric/" + encodeURIComponent(metric.id)}, metric.id));
+                var kind = el("td");
+                kind.appendChild(el("span", {"class": "badge " + metric.type}, metric.type));
+                var value = el("td", {"class": "value"});
+                value.appendChild(valueNode(metric));
+                var spark = el("td", {"class": "spark"});
+                spark.appendChild(sparkline(metric.samples));
+                [name, kind, value, spark].forEach(function (cell) {
+                    row.appendChild(cell);
+                });
+                rows.appendChild(row);
+            });
+            if (!metrics.length) {
+                var empty = el("tr", {"class": "empty"});
+                empty.appendChild(el("td", {colspan: "4"}, "No metrics yet"));
+                rows.appendChild(empty);
+            }
+            body.replaceChildren(rows);
+            filter();
+        }
+
+        search.addEventListener("input", filter);
+        type.addEventListener("change", filter);
+        search.form.addEventListener("submit", function (event) {
+            event.preventDefault();
+        });
+        every(function () {
+            load("/dashboard/api/metrics?window=" + encodeURIComponent(table.dataset.window), render);
+        });
+    }
+
+    function detail(section) {
+        var value = document.getElementById("value");
+        var plot = document.getElementById("chart");
+        every(function () {
+            load("/dashboard/api/metrics/" + encodeURIComponent(section.dataset.id) +
+                "?window=" + encodeURIComponent(section.dataset.window), function (metric) {
+                value.replaceChildren(valueNode(metric));
+                plot.replaceChildren(chart(metric.samples));
+            });
+        });
+    }
+
+    var table = document.getElementById("metrics");
+    if (table) {
+        index(table);
+    }
+    var section = document.getElementById("detail");
+    if (section) {
+        detail(section);
+    }
+})();
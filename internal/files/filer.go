@@ -23,7 +23,23 @@ func NewFiler(path string, attempts []int) *Filer {
 	}
 }
 
+// record dumped metric, tenant is omitted for default tenant so dumps of single tenant keep old format
+type record struct {
+	Tenant string `json:"tenant,omitempty"`
+	models.Metric
+}
+
+// Restore metrics of default tenant
 func (f *Filer) Restore() ([]models.Metric, error) {
+	tenants, err := f.RestoreTenants()
+	if err != nil {
+		return nil, err
+	}
+	return tenants[models.DefaultTenant], nil
+}
+
+// RestoreTenants metrics of every tenant
+func (f *Filer) RestoreTenants() (map[string][]models.Metric, error) {
 	file, err := f.openFile(os.O_CREATE | os.O_RDWR)
 	if err != nil {
 		return nil, err
@@ -32,14 +48,37 @@ func (f *Filer) Restore() ([]models.Metric, error) {
 		_ = file.Close()
 	}(file)
 	buf := bufio.NewReader(file)
-	var metrics []models.Metric
-	if err := json.NewDecoder(buf).Decode(&metrics); err != nil {
+	var records []record
+	if err := json.NewDecoder(buf).Decode(&records); err != nil {
 		return nil, err
 	}
-	return metrics, nil
+	tenants := make(map[string][]models.Metric)
+	for _, it := range records {
+		tenant := it.Tenant
+		if tenant == "" {
+			tenant = models.DefaultTenant
+		}
+		tenants[tenant] = append(tenants[tenant], it.Metric)
+	}
+	return tenants, nil
 }
 
+// Dump metrics of default tenant
 func (f *Filer) Dump(metrics []models.Metric) error {
+	return f.DumpTenants(map[string][]models.Metric{models.DefaultTenant: metrics})
+}
+
+// DumpTenants metrics of every tenant
+func (f *Filer) DumpTenants(tenants map[string][]models.Metric) error {
+	records := make([]record, 0)
+	for tenant, metrics := range tenants {
+		if tenant == models.DefaultTenant {
+			tenant = ""
+		}
+		for _, metric := range metrics {
+			records = append(records, record{Tenant: tenant, Metric: metric})
+		}
+	}
 	file, err := f.openFile(os.O_CREATE | os.O_WRONLY | os.O_TRUNC)
 	if err != nil {
 		return err
@@ -53,7 +92,7 @@ func (f *Filer) Dump(metrics []models.Metric) error {
 	if _, err := file.Seek(0, 0); err != nil {
 		return err
 	}
-	if err := json.NewEncoder(file).Encode(&metrics); err != nil {
+	if err := json.NewEncoder(file).Encode(&records); err != nil {
 		return err
 	}
 	return nil
@@ -0,0 +1,11 @@
+package contracts
+
+// DashboardMetric current value of metric with samples for its chart, stale gauge has no value
+type DashboardMetric struct {
+	ID      string        `json:"id"`
+	Type    string        `json:"type"`
+	Value   *float64      `json:"value,omitempty"`
+	Delta   *int64        `json:"delta,omitempty"`
+	Stale   bool          `json:"stale,omitempty"`
+	Samples []QuerySample `json:"samples"`
+}
@@ -1,5 +1,23 @@
 package contracts
 
+// ErrorCode machine readable reason of failed request, set by /api/v2 only
+type ErrorCode string
+
+const (
+	CodeBadRequest          ErrorCode = "bad_request"
+	CodeInvalidMetric       ErrorCode = "invalid_metric"
+	CodeInvalidMetricName   ErrorCode = "invalid_metric_name"
+	CodeInvalidFilter       ErrorCode = "invalid_filter"
+	CodeInvalidCursor       ErrorCode = "invalid_cursor"
+	CodeMetricNotFound      ErrorCode = "metric_not_found"
+	CodeTypeMismatch        ErrorCode = "type_mismatch"
+	CodeMetricNameRejected  ErrorCode = "metric_name_rejected"
+	CodeMetricLimitExceeded ErrorCode = "metric_limit_exceeded"
+	CodeNotAcceptable       ErrorCode = "not_acceptable"
+	CodeInternal            ErrorCode = "internal"
+)
+
 type ErrorModel struct {
-	Error string `json:"error"`
+	Error string    `json:"error"`
+	Code  ErrorCode `json:"code,omitempty"`
 }
@@ -0,0 +1,11 @@
+package contracts
+
+import "time"
+
+// Group metrics pushed by job
+type Group struct {
+	Job       string    `json:"job"`
+	Instance  string    `json:"instance,omitempty"`
+	Metrics   []Metric  `json:"metrics"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,9 @@
+package contracts
+
+// Health state of keeper, audit dropped counts audit events lost because audit queue was full
+// or sink kept failing to write them
+type Health struct {
+	Status       string `json:"status"`
+	Database     string `json:"database,omitempty"`
+	AuditDropped *int64 `json:"audit_dropped,omitempty"`
+}
@@ -1,15 +1,22 @@
 package contracts
 
 type (
-	MetricView struct {
-		Name  string
-		Value any
-	}
 	// Metric info
 	Metric struct {
 		ID    string   `json:"id"`
 		Type  string   `json:"type"`
 		Value *float64 `json:"value,omitempty"`
 		Delta *int64   `json:"delta,omitempty"`
+		// Stale gauge was not updated within ttl, its value is omitted
+		Stale bool `json:"stale,omitempty"`
+	}
+	// MetricPage page of listed metrics, next is cursor of following page and is empty on last page
+	MetricPage struct {
+		Metrics []Metric `json:"metrics"`
+		Next    string   `json:"next,omitempty"`
+	}
+	// Affected count of metrics removed or reset by bulk operation
+	Affected struct {
+		Count int `json:"count"`
 	}
 )
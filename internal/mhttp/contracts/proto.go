@@ -0,0 +1,59 @@
+package contracts
+
+import (
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// AppendProto append Metric message of metrics.proto
+func (m Metric) AppendProto(b []byte) []byte {
+	b = appendString(b, 1, m.ID)
+	b = appendString(b, 2, m.Type)
+	if m.Value != nil {
+		b = protowire.AppendTag(b, 3, protowire.Fixed64Type)
+		b = protowire.AppendFixed64(b, math.Float64bits(*m.Value))
+	}
+	if m.Delta != nil {
+		b = protowire.AppendTag(b, 4, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(*m.Delta))
+	}
+	if m.Stale {
+		b = protowire.AppendTag(b, 5, protowire.VarintType)
+		b = protowire.AppendVarint(b, protowire.EncodeBool(true))
+	}
+	return b
+}
+
+// AppendProto append MetricPage message of metrics.proto
+func (p MetricPage) AppendProto(b []byte) []byte {
+	for _, metric := range p.Metrics {
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendBytes(b, metric.AppendProto(nil))
+	}
+	return appendString(b, 2, p.Next)
+}
+
+// AppendProto append Affected message of metrics.proto
+func (a Affected) AppendProto(b []byte) []byte {
+	if a.Count != 0 {
+		b = protowire.AppendTag(b, 1, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(a.Count))
+	}
+	return b
+}
+
+// AppendProto append Error message of metrics.proto
+func (e ErrorModel) AppendProto(b []byte) []byte {
+	b = appendString(b, 1, e.Error)
+	return appendString(b, 2, string(e.Code))
+}
+
+// appendString append string field, empty string is default value and is omitted
+func appendString(b []byte, number protowire.Number, value string) []byte {
+	if value == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, number, protowire.BytesType)
+	return protowire.AppendString(b, value)
+}
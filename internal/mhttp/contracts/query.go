@@ -0,0 +1,20 @@
+package contracts
+
+type (
+	// QueryResult evaluated query, vector series carry single sample and matrix series many
+	QueryResult struct {
+		Type   string        `json:"type"`
+		Series []QuerySeries `json:"series"`
+	}
+	// QuerySeries metric name, labels and samples, functions and aggregations drop name
+	QuerySeries struct {
+		Name    string            `json:"name,omitempty"`
+		Labels  map[string]string `json:"labels,omitempty"`
+		Samples []QuerySample     `json:"samples"`
+	}
+	// QuerySample value at unix time in seconds
+	QuerySample struct {
+		Time  float64 `json:"t"`
+		Value float64 `json:"v"`
+	}
+)
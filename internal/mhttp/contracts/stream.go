@@ -0,0 +1,8 @@
+package contracts
+
+// StreamMessage batch of streamed updates with latest value of each metric, dropped counts
+// updates lost since previous message because consumer was too slow
+type StreamMessage struct {
+	Metrics []Metric `json:"metrics"`
+	Dropped int64    `json:"dropped,omitempty"`
+}
@@ -0,0 +1,46 @@
+package contracts
+
+import (
+	"strconv"
+)
+
+// AppendText append value of metric, stale gauge has no value and is written as stale
+func (m Metric) AppendText(b []byte) []byte {
+	switch {
+	case m.Stale:
+		return append(b, "stale"...)
+	case m.Value != nil:
+		return strconv.AppendFloat(b, *m.Value, 'f', -1, 64)
+	case m.Delta != nil:
+		return strconv.AppendInt(b, *m.Delta, 10)
+	default:
+		return b
+	}
+}
+
+// AppendText append line of id, type and value per metric, cursor of following page is not part of text
+func (p MetricPage) AppendText(b []byte) []byte {
+	for _, metric := range p.Metrics {
+		b = append(b, metric.ID...)
+		b = append(b, ' ')
+		b = append(b, metric.Type...)
+		b = append(b, ' ')
+		b = metric.AppendText(b)
+		b = append(b, '\n')
+	}
+	return b
+}
+
+// AppendText append count
+func (a Affected) AppendText(b []byte) []byte {
+	return strconv.AppendInt(b, int64(a.Count), 10)
+}
+
+// AppendText append code and message
+func (e ErrorModel) AppendText(b []byte) []byte {
+	if e.Code != "" {
+		b = append(b, e.Code...)
+		b = append(b, ": "...)
+	}
+	return append(b, e.Error...)
+}
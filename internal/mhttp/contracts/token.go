@@ -0,0 +1,19 @@
+package contracts
+
+import "time"
+
+type (
+	// TokenRequest token to issue
+	TokenRequest struct {
+		Tenant string   `json:"tenant" binding:"required"`
+		Scopes []string `json:"scopes" binding:"required"`
+	}
+	// Token issued token, secret is returned only once on creation
+	Token struct {
+		ID        string    `json:"id"`
+		Tenant    string    `json:"tenant"`
+		Scopes    []string  `json:"scopes"`
+		CreatedAt time.Time `json:"created_at"`
+		Secret    string    `json:"token,omitempty"`
+	}
+)
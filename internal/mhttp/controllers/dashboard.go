@@ -0,0 +1,239 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/DimKa163/go-metrics/internal/dashboard"
+	"github.com/DimKa163/go-metrics/internal/mhttp/contracts"
+	"github.com/DimKa163/go-metrics/internal/mhttp/middleware"
+	"github.com/DimKa163/go-metrics/internal/models"
+	"github.com/DimKa163/go-metrics/internal/usecase"
+)
+
+const (
+	// defaultWindow time span of sparklines and charts without window parameter
+	defaultWindow = "15m"
+	// maxWindow longest time span of charts
+	maxWindow = 24 * time.Hour
+)
+
+// chartWindows time spans offered on metric page
+var chartWindows = []string{"5m", "15m", "1h", "6h"}
+
+type Dashboard interface {
+	Map(engine *gin.Engine)
+
+	Index(context *gin.Context)
+
+	Metric(context *gin.Context)
+
+	Metrics(context *gin.Context)
+
+	MetricJSON(context *gin.Context)
+}
+
+type dashboards struct {
+	service *usecase.MetricService
+}
+
+func NewDashboardController(service *usecase.MetricService) Dashboard {
+	return &dashboards{
+		service: service,
+	}
+}
+
+// metricRow metric as shown on dashboard
+type metricRow struct {
+	ID    string
+	Type  string
+	Value string
+	Stale bool
+}
+
+// Map map all routs, pages are rendered from templates embedded into binary
+func (d *dashboards) Map(engine *gin.Engine) {
+	read := middleware.RequireScope(models.ScopeRead)
+	engine.SetHTMLTemplate(dashboard.Templates())
+	engine.StaticFS("/static", dashboard.Static())
+	engine.GET("/", read, d.Index)
+	engine.GET("/metric/*id", read, d.Metric)
+	engine.GET("/dashboard/api/metrics", read, d.Metrics)
+	engine.GET("/dashboard/api/metrics/*id", read, d.MetricJSON)
+}
+
+// Index dashboard of all metrics, q and type narrow initial list like search box of page does
+// @Produce text/html
+// @Param q query string false "Part of metric name"
+// @Param type query string false "Metric type, gauge or counter"
+// @Param window query string false "Time span of sparklines, 15m by default"
+// @Success 200 {string} string "dashboard page"
+// @Failure 400 {object} contracts.ErrorModel "bad request"
+// @Failure 500 {object} contracts.ErrorModel "internal server error"
+// @Router / [get]
+func (d *dashboards) Index(context *gin.Context) {
+	window, _, err := chartWindow(context)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, contracts.ErrorModel{Error: err.Error()})
+		return
+	}
+	metrics, err := d.service.GetAll(context)
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, contracts.ErrorModel{Error: err.Error()})
+		return
+	}
+	search, kind := context.Query("q"), context.Query("type")
+	rows := make([]metricRow, 0, len(metrics))
+	for _, metric := range metrics {
+		if kind != "" && metric.Type != kind {
+			continue
+		}
+		if !strings.Contains(strings.ToLower(metric.ID), strings.ToLower(search)) {
+			continue
+		}
+		rows = append(rows, d.row(metric))
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i].ID < rows[j].ID
+	})
+	context.HTML(http.StatusOK, "index.tmpl", gin.H{
+		"Title":   "Metrics",
+		"Query":   search,
+		"Type":    kind,
+		"Window":  window,
+		"Metrics": rows,
+	})
+}
+
+// Metric page of one metric with chart of its history
+// @Produce text/html
+// @Param id path string true "Metric id"
+// @Param window query string false "Time span of chart, 15m by default"
+// @Success 200 {string} string "metric page"
+// @Failure 400 {object} contracts.ErrorModel "bad request"
+// @Failure 404 {object} contracts.ErrorModel "metric not found"
+// @Failure 500 {object} contracts.ErrorModel "internal server error"
+// @Router /metric/{id} [get]
+func (d *dashboards) Metric(context *gin.Context) {
+	window, _, err := chartWindow(context)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, contracts.ErrorModel{Error: err.Error()})
+		return
+	}
+	metric, err := d.service.Get(context, strings.TrimPrefix(context.Param("id"), "/"))
+	if err != nil {
+		context.JSON(metricStatus(err), contracts.ErrorModel{Error: err.Error()})
+		return
+	}
+	context.HTML(http.StatusOK, "metric.tmpl", gin.H{
+		"Title":   metric.ID,
+		"Window":  window,
+		"Windows": chartWindows,
+		"Metric":  d.row(metric),
+	})
+}
+
+// Metrics current values and recent samples of all metrics, source of dashboard refresh
+// @Produce application/json
+// @Param window query string false "Time span of samples, 15m by default"
+// @Success 200 {array} contracts.DashboardMetric "success request"
+// @Failure 400 {object} contracts.ErrorModel "bad request"
+// @Failure 500 {object} contracts.ErrorModel "internal server error"
+// @Router /dashboard/api/metrics [get]
+func (d *dashboards) Metrics(context *gin.Context) {
+	_, span, err := chartWindow(context)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, contracts.ErrorModel{Error: err.Error()})
+		return
+	}
+	metrics, err := d.service.GetAll(context)
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, contracts.ErrorModel{Error: err.Error()})
+		return
+	}
+	sort.Slice(metrics, func(i, j int) bool {
+		return metrics[i].ID < metrics[j].ID
+	})
+	now := time.Now()
+	result := make([]contracts.DashboardMetric, len(metrics))
+	for i, metric := range metrics {
+		result[i] = d.metric(context, metric, now.Add(-span), now)
+	}
+	context.JSON(http.StatusOK, result)
+}
+
+// MetricJSON current value and recent samples of metric, source of metric page refresh
+// @Produce application/json
+// @Param id path string true "Metric id"
+// @Param window query string false "Time span of samples, 15m by default"
+// @Success 200 {object} contracts.DashboardMetric "success request"
+// @Failure 400 {object} contracts.ErrorModel "bad request"
+// @Failure 404 {object} contracts.ErrorModel "metric not found"
+// @Failure 500 {object} contracts.ErrorModel "internal server error"
+// @Router /dashboard/api/metrics/{id} [get]
+func (d *dashboards) MetricJSON(context *gin.Context) {
+	_, span, err := chartWindow(context)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, contracts.ErrorModel{Error: err.Error()})
+		return
+	}
+	metric, err := d.service.Get(context, strings.TrimPrefix(context.Param("id"), "/"))
+	if err != nil {
+		context.JSON(metricStatus(err), contracts.ErrorModel{Error: err.Error()})
+		return
+	}
+	now := time.Now()
+	context.JSON(http.StatusOK, d.metric(context, metric, now.Add(-span), now))
+}
+
+func (d *dashboards) row(metric models.Metric) metricRow {
+	row := metricRow{ID: metric.ID, Type: metric.Type, Stale: d.service.Stale(metric)}
+	switch {
+	case metric.Value != nil:
+		row.Value = strconv.FormatFloat(*metric.Value, 'g', -1, 64)
+	case metric.Delta != nil:
+		row.Value = strconv.FormatInt(*metric.Delta, 10)
+	}
+	return row
+}
+
+func (d *dashboards) metric(context *gin.Context, metric models.Metric, from time.Time, to time.Time) contracts.DashboardMetric {
+	result := contracts.DashboardMetric{ID: metric.ID, Type: metric.Type, Value: metric.Value, Delta: metric.Delta}
+	if d.service.Stale(metric) {
+		result.Value, result.Delta, result.Stale = nil, nil, true
+	}
+	samples := d.service.Samples(context, metric.ID, from, to)
+	result.Samples = make([]contracts.QuerySample, len(samples))
+	for i, sample := range samples {
+		result.Samples[i] = contracts.QuerySample{Time: float64(sample.Time.UnixMilli()) / 1000, Value: sample.Value}
+	}
+	return result
+}
+
+// chartWindow window parameter as given and parsed
+func chartWindow(context *gin.Context) (string, time.Duration, error) {
+	window := context.DefaultQuery("window", defaultWindow)
+	span, err := time.ParseDuration(window)
+	if err != nil {
+		return "", 0, err
+	}
+	if span <= 0 || span > maxWindow {
+		return "", 0, fmt.Errorf("window must be positive and at most %s", maxWindow)
+	}
+	return window, span, nil
+}
+
+// metricStatus status of failed metric lookup
+func metricStatus(err error) int {
+	if errors.Is(err, usecase.ErrMetricNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DimKa163/go-metrics/internal/mhttp/contracts"
+	"github.com/DimKa163/go-metrics/internal/models"
+	"github.com/DimKa163/go-metrics/internal/query"
+	"github.com/DimKa163/go-metrics/internal/usecase"
+)
+
+func TestDashboard(t *testing.T) {
+	service := usecase.NewMetricService(configureFileRepository(), usecase.MetricOptions{History: query.NewHistory(10)})
+	labeled := models.ComposeID("disk_free", map[string]string{"mount": "/"})
+	for _, value := range []float64{1, 2} {
+		require.NoError(t, service.BatchUpdate(context.Background(), []models.Metric{
+			*models.CreateGauge("Alloc", value),
+			*models.CreateGauge(labeled, value),
+			*models.CreateCounter("<script>", 1),
+		}))
+	}
+	router := gin.Default()
+	NewDashboardController(service).Map(router)
+	get := func(target string) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, target, nil))
+		return res
+	}
+
+	res := get("/")
+	require.Equal(t, http.StatusOK, res.Code)
+	assert.Contains(t, res.Body.String(), `<a href="/metric/Alloc">Alloc</a>`)
+	assert.Contains(t, res.Body.String(), "&lt;script&gt;")
+	assert.NotContains(t, res.Body.String(), "<script>\n")
+	assert.Contains(t, res.Body.String(), `src="/static/dashboard.js"`)
+	res = get("/?q=alloc&type=gauge")
+	require.Equal(t, http.StatusOK, res.Code)
+	assert.Contains(t, res.Body.String(), "Alloc")
+	assert.NotContains(t, res.Body.String(), "disk_free")
+
+	res = get("/metric/" + url.PathEscape(labeled) + "?window=1h")
+	require.Equal(t, http.StatusOK, res.Code)
+	assert.Contains(t, res.Body.String(), `data-window="1h"`)
+	assert.Equal(t, http.StatusNotFound, get("/metric/missing").Code)
+	assert.Equal(t, http.StatusBadRequest, get("/metric/Alloc?window=forever").Code)
+
+	res = get("/dashboard/api/metrics")
+	require.Equal(t, http.StatusOK, res.Code)
+	var metrics []contracts.DashboardMetric
+	require.NoError(t, json.Unmarshal(res.Body.Bytes(), &metrics))
+	require.NotEmpty(t, metrics)
+	assert.Equal(t, "<script>", metrics[0].ID)
+	assert.Equal(t, int64(2), *metrics[0].Delta)
+	assert.Len(t, metrics[0].Samples, 2)
+
+	res = get("/dashboard/api/metrics/" + url.PathEscape(labeled))
+	require.Equal(t, http.StatusOK, res.Code)
+	var metric contracts.DashboardMetric
+	require.NoError(t, json.Unmarshal(res.Body.Bytes(), &metric))
+	assert.Equal(t, labeled, metric.ID)
+	require.Len(t, metric.Samples, 2)
+	assert.Equal(t, 2.0, metric.Samples[1].Value)
+	assert.Equal(t, http.StatusBadRequest, get("/dashboard/api/metrics?window=-1m").Code)
+
+	for _, asset := range []string{"/static/dashboard.js", "/static/dashboard.css"} {
+		assert.Equal(t, http.StatusOK, get(asset).Code, asset)
+	}
+}
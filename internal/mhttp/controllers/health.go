@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/DimKa163/go-metrics/internal/mhttp/contracts"
+)
+
+// Pinger database checked by health endpoint
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// AuditStats auditor reporting events it lost
+type AuditStats interface {
+	Dropped() int64
+}
+
+type Health interface {
+	Map(engine *gin.Engine)
+
+	Health(context *gin.Context)
+}
+
+type health struct {
+	db    Pinger
+	audit AuditStats
+}
+
+// NewHealthController create health endpoint, db and audit are nil when keeper runs without them
+func NewHealthController(db Pinger, audit AuditStats) Health {
+	return &health{
+		db:    db,
+		audit: audit,
+	}
+}
+
+// Map map all routs
+func (h *health) Map(engine *gin.Engine) {
+	engine.GET("/healthz", h.Health)
+}
+
+// Health state of keeper, audit events dropped because audit queue was full or sink kept failing
+// are counted here since they can't be found anywhere else
+// @Produce application/json
+// @Success 200 {object} contracts.Health "keeper is healthy"
+// @Failure 503 {object} contracts.Health "database is unavailable"
+// @Router /healthz [get]
+func (h *health) Health(context *gin.Context) {
+	status := http.StatusOK
+	result := contracts.Health{Status: "ok"}
+	if h.db != nil {
+		result.Database = "ok"
+		if err := h.db.Ping(context); err != nil {
+			status = http.StatusServiceUnavailable
+			result.Status, result.Database = "unavailable", err.Error()
+		}
+	}
+	if h.audit != nil {
+		dropped := h.audit.Dropped()
+		result.AuditDropped = &dropped
+	}
+	context.JSON(status, result)
+}
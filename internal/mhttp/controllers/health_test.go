@@ -0,0 +1,47 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type pingerFunc func(ctx context.Context) error
+
+func (f pingerFunc) Ping(ctx context.Context) error {
+	return f(ctx)
+}
+
+type droppedStats int64
+
+func (d droppedStats) Dropped() int64 {
+	return int64(d)
+}
+
+func TestHealth(t *testing.T) {
+	var pingErr error
+	router := gin.Default()
+	NewHealthController(pingerFunc(func(context.Context) error { return pingErr }), droppedStats(3)).Map(router)
+
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.JSONEq(t, `{"status":"ok","database":"ok","audit_dropped":3}`, res.Body.String())
+
+	pingErr = errors.New("connection refused")
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, res.Code)
+	assert.JSONEq(t, `{"status":"unavailable","database":"connection refused","audit_dropped":3}`, res.Body.String())
+
+	router = gin.Default()
+	NewHealthController(nil, nil).Map(router)
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.JSONEq(t, `{"status":"ok"}`, res.Body.String())
+}
@@ -4,18 +4,23 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/DimKa163/go-metrics/internal/files"
+	"github.com/DimKa163/go-metrics/internal/mhttp/contracts"
 	"github.com/DimKa163/go-metrics/internal/mhttp/middleware"
 	"github.com/DimKa163/go-metrics/internal/models"
 	"github.com/DimKa163/go-metrics/internal/persistence/mem"
@@ -280,7 +285,7 @@ func TestUpdateGzip(t *testing.T) {
 }
 
 func configureService() *usecase.MetricService {
-	return usecase.NewMetricService(configureFileRepository())
+	return usecase.NewMetricService(configureFileRepository(), usecase.MetricOptions{})
 }
 func configureFileRepository() *mem.MemoryStore {
 	attempts := []int{1, 3, 5}
@@ -326,3 +331,187 @@ func (m *mockGaugeRepository) Upsert(_ context.Context, _ *models.Metric) error
 func (m *mockGaugeRepository) BatchUpsert(_ context.Context, _ []models.Metric) error {
 	return nil
 }
+
+func TestUpdatePolicy(t *testing.T) {
+	cases := []struct {
+		name               string
+		method             string
+		url                string
+		body               string
+		expectedStatusCode int
+	}{
+		{"long name", http.MethodPost, "/update/gauge/VeryLongMetricNameOverLimit/1", "", http.StatusBadRequest},
+		{"invalid name", http.MethodPost, "/update/", `{"id": "heap alloc", "type": "gauge", "value": 1}`, http.StatusBadRequest},
+		{"blocked name", http.MethodPost, "/update/", `{"id": "Secret", "type": "gauge", "value": 1}`, http.StatusUnprocessableEntity},
+		{
+			"too many metrics", http.MethodPost, "/updates",
+			`[{"id": "A", "type": "gauge", "value": 1}, {"id": "B", "type": "gauge", "value": 1}]`,
+			http.StatusUnprocessableEntity,
+		},
+		{"accepted", http.MethodPost, "/update/counter/A/1", "", http.StatusOK},
+	}
+	service := usecase.NewMetricService(configureFileRepository(), usecase.MetricOptions{Policy: usecase.MetricPolicy{
+		NamePattern:      regexp.MustCompile(`^\w+$`),
+		MaxNameLength:    25,
+		Block:            []string{"Secret*"},
+		MaxTenantMetrics: 3,
+	}})
+	router := gin.Default()
+	NewMetricController(service).Map(router)
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			res := httptest.NewRecorder()
+			router.ServeHTTP(res, httptest.NewRequest(c.method, c.url, strings.NewReader(c.body)))
+			assert.Equal(t, c.expectedStatusCode, res.Code)
+		})
+	}
+}
+
+func TestList(t *testing.T) {
+	service := configureService()
+	for _, id := range []string{"Alloc", "Beta", "Gamma"} {
+		_, err := service.Upsert(context.Background(), *models.CreateGauge(id, 1))
+		require.NoError(t, err)
+	}
+	router := gin.Default()
+	NewMetricController(service).Map(router)
+	list := func(query string) (int, contracts.MetricPage) {
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/api/metrics?"+query, nil))
+		var page contracts.MetricPage
+		if res.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(res.Body.Bytes(), &page))
+		}
+		return res.Code, page
+	}
+
+	var walked []string
+	cursor := ""
+	for i := 0; i < 10; i++ {
+		code, page := list("type=gauge&limit=2&order=desc&cursor=" + cursor)
+		require.Equal(t, http.StatusOK, code)
+		for _, metric := range page.Metrics {
+			walked = append(walked, metric.ID)
+		}
+		if page.Next == "" {
+			break
+		}
+		cursor = page.Next
+	}
+	assert.Equal(t, []string{"Gamma", "Beta", "Alloc"}, walked)
+
+	code, page := list("prefix=Founded&regex=Counter")
+	assert.Equal(t, http.StatusOK, code)
+	require.Len(t, page.Metrics, 1)
+	assert.Equal(t, "FoundedCounterMetric", page.Metrics[0].ID)
+	assert.Empty(t, page.Next)
+
+	for _, query := range []string{"type=histogram", "sort=value", "order=up", "regex=(", "limit=0x", "limit=5000", "cursor=!!"} {
+		code, _ = list(query)
+		assert.Equal(t, http.StatusBadRequest, code, query)
+	}
+}
+
+func TestDeleteAndReset(t *testing.T) {
+	repository, err := mem.NewStore(files.NewFiler(filepath.Join(t.TempDir(), "dump"), []int{1}), mem.StoreOption{})
+	require.NoError(t, err)
+	service := usecase.NewMetricService(repository, usecase.MetricOptions{})
+	err = service.BatchUpdate(context.Background(), []models.Metric{
+		*models.CreateGauge("cpu.user", 1),
+		*models.CreateGauge("cpu.system", 2),
+		*models.CreateGauge("mem.free", 3),
+		*models.CreateCounter("requests", 4),
+	})
+	require.NoError(t, err)
+	router := gin.Default()
+	NewMetricController(service).Map(router)
+	serve := func(method, target string) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, httptest.NewRequest(method, target, nil))
+		return res
+	}
+	affected := func(res *httptest.ResponseRecorder) int {
+		require.Equal(t, http.StatusOK, res.Code)
+		var body contracts.Affected
+		require.NoError(t, json.Unmarshal(res.Body.Bytes(), &body))
+		return body.Count
+	}
+
+	assert.Equal(t, http.StatusNoContent, serve(http.MethodDelete, "/api/metrics/mem.free").Code)
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodDelete, "/api/metrics/mem.free").Code)
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodDelete, "/api/metrics").Code)
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/api/metrics/reset").Code)
+
+	assert.Equal(t, http.StatusNoContent, serve(http.MethodPost, "/api/metrics/requests/reset").Code)
+	metric, err := service.Get(context.Background(), "requests")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), *metric.Delta)
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodPost, "/api/metrics/missing/reset").Code)
+
+	assert.Equal(t, 2, affected(serve(http.MethodPost, "/api/metrics/reset?prefix=cpu.")))
+	assert.Equal(t, 2, affected(serve(http.MethodDelete, "/api/metrics?type=gauge&regex=cpu%5C..*")))
+	assert.Equal(t, 0, affected(serve(http.MethodDelete, "/api/metrics?prefix=cpu.")))
+
+	all, err := service.GetAll(context.Background())
+	require.NoError(t, err)
+	require.Len(t, all, 1)
+	assert.Equal(t, "requests", all[0].ID)
+	assert.Equal(t, int64(0), *all[0].Delta)
+}
+
+func TestStaleMetrics(t *testing.T) {
+	repository, err := mem.NewStore(files.NewFiler(filepath.Join(t.TempDir(), "dump"), []int{1}), mem.StoreOption{})
+	require.NoError(t, err)
+	service := usecase.NewMetricService(repository, usecase.MetricOptions{TTL: 200 * time.Millisecond, GC: 400 * time.Millisecond})
+	require.NoError(t, service.BatchUpdate(context.Background(), []models.Metric{
+		*models.CreateGauge("Stale", 1),
+		*models.CreateCounter("Gone", 1),
+	}))
+	time.Sleep(250 * time.Millisecond)
+	_, err = service.Upsert(context.Background(), *models.CreateGauge("Fresh", 2))
+	require.NoError(t, err)
+	router := gin.Default()
+	NewMetricController(service).Map(router)
+	value := func(body string) (int, string) {
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, httptest.NewRequest(http.MethodPost, "/value/", strings.NewReader(body)))
+		return res.Code, res.Body.String()
+	}
+
+	code, body := value(`{"id":"Stale","type":"gauge"}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `{"id":"Stale","type":"","stale":true}`, body)
+	code, _ = value(`{"id":"Gone","type":"counter"}`)
+	assert.Equal(t, http.StatusNotFound, code)
+	code, body = value(`{"id":"Fresh","type":"gauge"}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `{"id":"Fresh","type":"","value":2}`, body)
+
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/value/gauge/Stale", nil))
+	assert.Equal(t, http.StatusNotFound, res.Code)
+	all, err := service.GetAll(context.Background())
+	require.NoError(t, err)
+	require.Len(t, all, 1)
+	assert.Equal(t, "Fresh", all[0].ID)
+
+	removed, err := service.Expire(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, removed, "stale metrics are kept until gc horizon")
+	code, body = value(`{"id":"Stale","type":"gauge"}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `{"id":"Stale","type":"","stale":true}`, body)
+
+	time.Sleep(200 * time.Millisecond)
+	removed, err = service.Expire(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, removed)
+	code, _ = value(`{"id":"Stale","type":"gauge"}`)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	_, err = service.Upsert(context.Background(), *models.CreateCounter("Gone", 5))
+	require.NoError(t, err)
+	metric, err := service.Get(context.Background(), "Gone")
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), *metric.Delta)
+}
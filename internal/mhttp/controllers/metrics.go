@@ -4,22 +4,26 @@ import (
 	"errors"
 	"github.com/DimKa163/go-metrics/internal/mhttp/contracts"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 
 	"github.com/DimKa163/go-metrics/internal/logging"
+	"github.com/DimKa163/go-metrics/internal/mhttp/middleware"
 	"github.com/DimKa163/go-metrics/internal/models"
 	"github.com/DimKa163/go-metrics/internal/usecase"
 )
 
+// docs are regenerated from annotations below and of every handler, run go generate in commit changing them
+//go:generate go run github.com/swaggo/swag/cmd/swag@v1.16.6 init --dir . --generalInfo metrics.go --output ../../../docs --parseDependency
+
 // @Title MetricStorage API
 // @Description Metric service.
-// @Version 1.0
+// @Version 2.0
 
 type Metrics interface {
 	Map(engine *gin.Engine)
-	Home(context *gin.Context)
 
 	UpdateJSON(context *gin.Context)
 
@@ -30,6 +34,16 @@ type Metrics interface {
 	Get(context *gin.Context)
 
 	GetJSON(context *gin.Context)
+
+	List(context *gin.Context)
+
+	Delete(context *gin.Context)
+
+	DeleteMatching(context *gin.Context)
+
+	Reset(context *gin.Context)
+
+	ResetMatching(context *gin.Context)
 }
 
 type metrics struct {
@@ -44,15 +58,22 @@ func NewMetricController(service *usecase.MetricService) Metrics {
 
 // Map map all routs
 func (m *metrics) Map(engine *gin.Engine) {
-	engine.GET("/", m.Home)
-	engine.GET("/value/:type/:name", m.Get)
-	engine.POST("/value/", m.GetJSON)
-	engine.POST("/update/:type/:name/:value", m.Update)
-	engine.POST("/update/", m.UpdateJSON)
-	engine.POST("/updates", m.UpdatesJSON)
+	read := middleware.RequireScope(models.ScopeRead)
+	write := middleware.RequireScope(models.ScopeWrite)
+	admin := middleware.RequireScope(models.ScopeAdmin)
+	engine.GET("/value/:type/:name", read, m.Get)
+	engine.POST("/value/", read, m.GetJSON)
+	engine.GET("/api/metrics", read, m.List)
+	engine.POST("/update/:type/:name/:value", write, m.Update)
+	engine.POST("/update/", write, m.UpdateJSON)
+	engine.POST("/updates", write, m.UpdatesJSON)
+	engine.DELETE("/api/metrics", admin, m.DeleteMatching)
+	engine.DELETE("/api/metrics/:id", admin, m.Delete)
+	engine.POST("/api/metrics/reset", admin, m.ResetMatching)
+	engine.POST("/api/metrics/:id/reset", admin, m.Reset)
 }
 
-// GetJSON get metric
+// GetJSON get metric, gauge not updated within ttl is reported stale without value
 // @Produce application/json
 // @Param metric body contracts.Metric true "metric"
 // @Success 200 {object} contracts.Metric "success request"
@@ -78,6 +99,10 @@ func (m *metrics) GetJSON(context *gin.Context) {
 	context.Header("Content-Type", "application/json")
 	switch metric.Type {
 	case models.GaugeType:
+		if m.service.Stale(metric) {
+			context.JSON(http.StatusOK, contracts.Metric{ID: metric.ID, Stale: true})
+			return
+		}
 		context.JSON(http.StatusOK, contracts.Metric{ID: metric.ID, Value: metric.Value})
 	case models.CounterType:
 		context.JSON(http.StatusOK, contracts.Metric{ID: metric.ID, Delta: metric.Delta})
@@ -86,44 +111,113 @@ func (m *metrics) GetJSON(context *gin.Context) {
 	}
 }
 
-// Home all metrics
-// @Produce text/html
-// @Success 200 {string} []contracts.MetricView "success request"
+// List page of metrics filtered by type, id prefix and regular expression
+// @Produce application/json
+// @Param type query string false "Metric type, gauge or counter"
+// @Param prefix query string false "Metric id prefix"
+// @Param regex query string false "Regular expression metric id must match"
+// @Param sort query string false "Sort by id or type, id by default"
+// @Param order query string false "asc or desc, asc by default"
+// @Param cursor query string false "Cursor of next page returned with previous page"
+// @Param limit query int false "Page size, 100 by default and at most 1000"
+// @Success 200 {object} contracts.MetricPage "success request"
 // @Failure 400 {object} contracts.ErrorModel "bad request"
 // @Failure 500 {object} contracts.ErrorModel "internal server error"
-// @Router / [get]
-func (m *metrics) Home(context *gin.Context) {
-	met, err := m.service.GetAll(context)
+// @Router /api/metrics [get]
+func (m *metrics) List(context *gin.Context) {
+	req, err := listRequest(context)
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, contracts.ErrorModel{Error: err.Error()})
+		context.JSON(http.StatusBadRequest, contracts.ErrorModel{Error: err.Error()})
 		return
 	}
-	viewData := make([]contracts.MetricView, len(met))
-	for i, metric := range met {
-		switch metric.Type {
-		case models.GaugeType:
-			viewData[i] = contracts.MetricView{
-				Name:  metric.ID,
-				Value: metric.Value,
-			}
-		case models.CounterType:
-			viewData[i] = contracts.MetricView{
-				Name:  metric.ID,
-				Value: metric.Delta,
-			}
+	page, err := m.service.List(context, req)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, usecase.ErrInvalidFilter) || errors.Is(err, usecase.ErrInvalidCursor) {
+			status = http.StatusBadRequest
 		}
+		context.JSON(status, contracts.ErrorModel{Error: err.Error()})
+		return
+	}
+	result := contracts.MetricPage{Metrics: make([]contracts.Metric, len(page.Metrics)), Next: page.Next}
+	for i, metric := range page.Metrics {
+		result.Metrics[i] = contracts.Metric{ID: metric.ID, Type: metric.Type, Value: metric.Value, Delta: metric.Delta}
+	}
+	context.JSON(http.StatusOK, result)
+}
+
+// Delete remove metric
+// @Param id path string true "Metric id"
+// @Success 204 "metric removed"
+// @Failure 404 {object} contracts.ErrorModel "metric not found"
+// @Failure 500 {object} contracts.ErrorModel "internal server error"
+// @Router /api/metrics/{id} [delete]
+func (m *metrics) Delete(context *gin.Context) {
+	if err := m.service.Delete(context, context.Param("id")); err != nil {
+		context.JSON(removeStatus(err), contracts.ErrorModel{Error: err.Error()})
+		return
+	}
+	context.Status(http.StatusNoContent)
+}
+
+// DeleteMatching remove all metrics selected by filter, at least one filter is required
+// @Produce application/json
+// @Param type query string false "Metric type, gauge or counter"
+// @Param prefix query string false "Metric id prefix"
+// @Param regex query string false "Regular expression metric id must match"
+// @Success 200 {object} contracts.Affected "count of removed metrics"
+// @Failure 400 {object} contracts.ErrorModel "bad request"
+// @Failure 500 {object} contracts.ErrorModel "internal server error"
+// @Router /api/metrics [delete]
+func (m *metrics) DeleteMatching(context *gin.Context) {
+	count, err := m.service.DeleteMatching(context, matchRequest(context))
+	if err != nil && !errors.Is(err, usecase.ErrMetricNotFound) {
+		context.JSON(removeStatus(err), contracts.ErrorModel{Error: err.Error()})
+		return
+	}
+	context.JSON(http.StatusOK, contracts.Affected{Count: count})
+}
+
+// Reset set metric value to zero
+// @Param id path string true "Metric id"
+// @Success 204 "metric reset"
+// @Failure 404 {object} contracts.ErrorModel "metric not found"
+// @Failure 500 {object} contracts.ErrorModel "internal server error"
+// @Router /api/metrics/{id}/reset [post]
+func (m *metrics) Reset(context *gin.Context) {
+	if err := m.service.Reset(context, context.Param("id")); err != nil {
+		context.JSON(removeStatus(err), contracts.ErrorModel{Error: err.Error()})
+		return
+	}
+	context.Status(http.StatusNoContent)
+}
+
+// ResetMatching set value of all metrics selected by filter to zero, at least one filter is required
+// @Produce application/json
+// @Param type query string false "Metric type, gauge or counter"
+// @Param prefix query string false "Metric id prefix"
+// @Param regex query string false "Regular expression metric id must match"
+// @Success 200 {object} contracts.Affected "count of reset metrics"
+// @Failure 400 {object} contracts.ErrorModel "bad request"
+// @Failure 500 {object} contracts.ErrorModel "internal server error"
+// @Router /api/metrics/reset [post]
+func (m *metrics) ResetMatching(context *gin.Context) {
+	count, err := m.service.ResetMatching(context, matchRequest(context))
+	if err != nil && !errors.Is(err, usecase.ErrMetricNotFound) {
+		context.JSON(removeStatus(err), contracts.ErrorModel{Error: err.Error()})
+		return
 	}
-	context.Writer.Header().Set("Content-Type", "text/html")
-	context.HTML(http.StatusOK, "home.tmpl", gin.H{
-		"metrics": viewData,
-	})
+	context.JSON(http.StatusOK, contracts.Affected{Count: count})
 }
 
 // UpdatesJSON update many metric
 // @Produce application/json
 // @Param metrics body []contracts.Metric true "metric array"
-// @Success 200 {string} string "success request"
+// @Param Idempotency-Key header string false "Key generated per batch, batch with key applied already is acknowledged without being applied"
+// @Success 200 {string} string "success request, Idempotent-Replayed header is set when batch was applied already"
 // @Failure 400 {object} contracts.ErrorModel "bad request"
+// @Failure 409 {string} string "batch with the key is being applied, retry after Retry-After seconds"
+// @Failure 422 {object} contracts.ErrorModel "metric name or count rejected by policy"
 // @Failure 500 {object} contracts.ErrorModel "internal server error"
 // @Router /updates [post]
 func (m *metrics) UpdatesJSON(context *gin.Context) {
@@ -147,7 +241,7 @@ func (m *metrics) UpdatesJSON(context *gin.Context) {
 		data[i] = metricIt
 	}
 	if err := m.service.BatchUpdate(context, data); err != nil {
-		context.JSON(http.StatusInternalServerError, contracts.ErrorModel{Error: err.Error()})
+		context.JSON(updateStatus(err), contracts.ErrorModel{Error: err.Error()})
 		return
 	}
 	context.Writer.Header().Set("Content-Type", "application/json")
@@ -158,6 +252,7 @@ func (m *metrics) UpdatesJSON(context *gin.Context) {
 // @Produce application/json
 // @Param metric body contracts.Metric true "metric"
 // @Failure 400 {object} contracts.ErrorModel "bad request"
+// @Failure 422 {object} contracts.ErrorModel "metric name or count rejected by policy"
 // @Failure 500 {object} contracts.ErrorModel "internal server error"
 // @Router /update [post]
 func (m *metrics) UpdateJSON(context *gin.Context) {
@@ -178,18 +273,19 @@ func (m *metrics) UpdateJSON(context *gin.Context) {
 	}
 	result, err := m.service.Upsert(context, metric)
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, contracts.ErrorModel{Error: err.Error()})
+		context.JSON(updateStatus(err), contracts.ErrorModel{Error: err.Error()})
 		return
 	}
 
 	context.Writer.Header().Set("Content-Type", "application/json")
-	context.JSON(http.StatusOK, result)
+	context.JSON(http.StatusOK, contracts.Metric{ID: result.ID, Type: result.Type, Value: result.Value, Delta: result.Delta})
 }
 
 // Update update metric
 // @Produce plain/text
 // @Produce json
 // @Failure 400 {object} contracts.ErrorModel "bad request"
+// @Failure 422 {object} contracts.ErrorModel "metric name or count rejected by policy"
 // @Failure 500 {object} contracts.ErrorModel "internal server error"
 // @Param type path string true "Metric type"
 // @Param name path string true "Metric name"
@@ -206,7 +302,7 @@ func (m *metrics) Update(context *gin.Context) {
 	}
 	_, err = m.service.Upsert(context, metric)
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, contracts.ErrorModel{Error: err.Error()})
+		context.JSON(updateStatus(err), contracts.ErrorModel{Error: err.Error()})
 		return
 	}
 	context.Writer.Header().Set("Content-Type", "text/plain")
@@ -223,6 +319,9 @@ func (m *metrics) Get(context *gin.Context) {
 	t := context.Param("type")
 	name := context.Param("name")
 	metric, err := m.service.Get(context, name)
+	if err == nil && m.service.Stale(metric) {
+		err = usecase.ErrMetricNotFound
+	}
 	if err != nil {
 		if errors.Is(err, usecase.ErrMetricNotFound) {
 			context.JSON(http.StatusNotFound, "")
@@ -239,3 +338,61 @@ func (m *metrics) Get(context *gin.Context) {
 		context.JSON(http.StatusOK, metric.Delta)
 	}
 }
+
+// listRequest listing of query parameters
+func listRequest(context *gin.Context) (usecase.ListRequest, error) {
+	req := usecase.ListRequest{
+		Type:    context.Query("type"),
+		Prefix:  context.Query("prefix"),
+		Pattern: context.Query("regex"),
+		Sort:    context.Query("sort"),
+		Cursor:  context.Query("cursor"),
+	}
+	switch context.Query("order") {
+	case "", "asc":
+	case "desc":
+		req.Desc = true
+	default:
+		return usecase.ListRequest{}, errors.New("order must be asc or desc")
+	}
+	if limit := context.Query("limit"); limit != "" {
+		var err error
+		if req.Limit, err = strconv.Atoi(limit); err != nil {
+			return usecase.ListRequest{}, err
+		}
+	}
+	return req, nil
+}
+
+func matchRequest(context *gin.Context) usecase.ListRequest {
+	return usecase.ListRequest{
+		Type:    context.Query("type"),
+		Prefix:  context.Query("prefix"),
+		Pattern: context.Query("regex"),
+	}
+}
+
+// removeStatus status of failed delete or reset
+func removeStatus(err error) int {
+	switch {
+	case errors.Is(err, usecase.ErrMetricNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, usecase.ErrInvalidFilter):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// updateStatus status of failed update, malformed names are bad requests and names or counts
+// refused by policy are unprocessable
+func updateStatus(err error) int {
+	switch {
+	case errors.Is(err, usecase.ErrInvalidMetricName), errors.Is(err, usecase.ErrMetricNameTooLong):
+		return http.StatusBadRequest
+	case errors.Is(err, usecase.ErrMetricNameRejected), errors.Is(err, usecase.ErrMetricLimitExceeded):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
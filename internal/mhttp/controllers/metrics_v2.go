@@ -0,0 +1,269 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/DimKa163/go-metrics/internal/logging"
+	"github.com/DimKa163/go-metrics/internal/mhttp/contracts"
+	"github.com/DimKa163/go-metrics/internal/mhttp/middleware"
+	"github.com/DimKa163/go-metrics/internal/models"
+	"github.com/DimKa163/go-metrics/internal/usecase"
+)
+
+// media types /api/v2 responds with, first one is used when Accept is missing
+const (
+	mediaJSON     = "application/json"
+	mediaText     = "text/plain"
+	mediaProto    = "application/x-protobuf"
+	mediaProtoAlt = "application/protobuf"
+)
+
+const formatKey = "v2-format"
+
+// v2Body response of /api/v2 renderable in every negotiated format
+type v2Body interface {
+	AppendProto(b []byte) []byte
+	AppendText(b []byte) []byte
+}
+
+// MetricsV2 versioned metric API, every error carries code and response format follows Accept header
+type MetricsV2 interface {
+	Map(engine *gin.Engine)
+
+	List(context *gin.Context)
+
+	Get(context *gin.Context)
+
+	Update(context *gin.Context)
+
+	Updates(context *gin.Context)
+}
+
+type metricsV2 struct {
+	service *usecase.MetricService
+}
+
+func NewMetricV2Controller(service *usecase.MetricService) MetricsV2 {
+	return &metricsV2{
+		service: service,
+	}
+}
+
+// Map map all routs
+func (m *metricsV2) Map(engine *gin.Engine) {
+	read := middleware.RequireScope(models.ScopeRead)
+	write := middleware.RequireScope(models.ScopeWrite)
+	api := engine.Group("/api/v2", negotiate)
+	api.GET("/metrics", read, m.List)
+	api.POST("/metrics", write, m.Update)
+	api.POST("/metrics/batch", write, m.Updates)
+	api.GET("/metrics/:type/:id", read, m.Get)
+}
+
+// List page of metrics filtered by type, id prefix and regular expression, text lists metric per line
+// @Produce application/json
+// @Produce text/plain
+// @Produce application/x-protobuf
+// @Param type query string false "Metric type, gauge or counter"
+// @Param prefix query string false "Metric id prefix"
+// @Param regex query string false "Regular expression metric id must match"
+// @Param sort query string false "Sort by id or type, id by default"
+// @Param order query string false "asc or desc, asc by default"
+// @Param cursor query string false "Cursor of next page returned with previous page"
+// @Param limit query int false "Page size, 100 by default and at most 1000"
+// @Success 200 {object} contracts.MetricPage "success request, cursor of next page is sent in X-Next-Cursor header as well"
+// @Failure 400 {object} contracts.ErrorModel "bad request, invalid_filter or invalid_cursor"
+// @Failure 406 {object} contracts.ErrorModel "not_acceptable"
+// @Failure 500 {object} contracts.ErrorModel "internal server error"
+// @Router /api/v2/metrics [get]
+func (m *metricsV2) List(context *gin.Context) {
+	req, err := listRequest(context)
+	if err != nil {
+		fail(context, http.StatusBadRequest, contracts.CodeInvalidFilter, err)
+		return
+	}
+	page, err := m.service.List(context, req)
+	if err != nil {
+		failWith(context, err)
+		return
+	}
+	result := contracts.MetricPage{Metrics: make([]contracts.Metric, len(page.Metrics)), Next: page.Next}
+	for i, metric := range page.Metrics {
+		result.Metrics[i] = contracts.Metric{ID: metric.ID, Type: metric.Type, Value: metric.Value, Delta: metric.Delta}
+	}
+	if page.Next != "" {
+		context.Header("X-Next-Cursor", page.Next)
+	}
+	render(context, http.StatusOK, result)
+}
+
+// Get metric of type, metric stored with other type is not found. Gauge not updated within ttl is
+// reported stale without value
+// @Produce application/json
+// @Produce text/plain
+// @Produce application/x-protobuf
+// @Param type path string true "Metric type"
+// @Param id path string true "Metric id"
+// @Success 200 {object} contracts.Metric "success request, text is bare value"
+// @Failure 404 {object} contracts.ErrorModel "metric_not_found or type_mismatch"
+// @Failure 406 {object} contracts.ErrorModel "not_acceptable"
+// @Failure 500 {object} contracts.ErrorModel "internal server error"
+// @Router /api/v2/metrics/{type}/{id} [get]
+func (m *metricsV2) Get(context *gin.Context) {
+	t := context.Param("type")
+	metric, err := m.service.Get(context, context.Param("id"))
+	if err != nil {
+		failWith(context, err)
+		return
+	}
+	if metric.Type != t {
+		fail(context, http.StatusNotFound, contracts.CodeTypeMismatch,
+			fmt.Errorf("metric %s is %s, not %s", metric.ID, metric.Type, t))
+		return
+	}
+	result := contracts.Metric{ID: metric.ID, Type: metric.Type, Value: metric.Value, Delta: metric.Delta}
+	if m.service.Stale(metric) {
+		result = contracts.Metric{ID: metric.ID, Type: metric.Type, Stale: true}
+	}
+	render(context, http.StatusOK, result)
+}
+
+// Update update metric, counter delta is added to stored one
+// @Accept application/json
+// @Produce application/json
+// @Produce text/plain
+// @Produce application/x-protobuf
+// @Param metric body contracts.Metric true "metric"
+// @Success 200 {object} contracts.Metric "stored metric"
+// @Failure 400 {object} contracts.ErrorModel "bad_request, invalid_metric or invalid_metric_name"
+// @Failure 406 {object} contracts.ErrorModel "not_acceptable"
+// @Failure 422 {object} contracts.ErrorModel "metric_name_rejected or metric_limit_exceeded"
+// @Failure 500 {object} contracts.ErrorModel "internal server error"
+// @Router /api/v2/metrics [post]
+func (m *metricsV2) Update(context *gin.Context) {
+	var contract contracts.Metric
+	if err := context.ShouldBindJSON(&contract); err != nil {
+		fail(context, http.StatusBadRequest, contracts.CodeBadRequest, err)
+		return
+	}
+	metric, err := fromContract(contract)
+	if err != nil {
+		fail(context, http.StatusBadRequest, contracts.CodeInvalidMetric, err)
+		return
+	}
+	result, err := m.service.Upsert(context, metric)
+	if err != nil {
+		failWith(context, err)
+		return
+	}
+	render(context, http.StatusOK, contracts.Metric{ID: result.ID, Type: result.Type, Value: result.Value, Delta: result.Delta})
+}
+
+// Updates update batch of metrics, whole batch is rejected when any metric is invalid
+// @Accept application/json
+// @Produce application/json
+// @Produce text/plain
+// @Produce application/x-protobuf
+// @Param metrics body []contracts.Metric true "metrics"
+// @Success 200 {object} contracts.Affected "count of accepted metrics"
+// @Failure 400 {object} contracts.ErrorModel "bad_request, invalid_metric or invalid_metric_name"
+// @Failure 406 {object} contracts.ErrorModel "not_acceptable"
+// @Failure 422 {object} contracts.ErrorModel "metric_name_rejected or metric_limit_exceeded"
+// @Failure 500 {object} contracts.ErrorModel "internal server error"
+// @Router /api/v2/metrics/batch [post]
+func (m *metricsV2) Updates(context *gin.Context) {
+	var list []contracts.Metric
+	if err := context.ShouldBindJSON(&list); err != nil {
+		fail(context, http.StatusBadRequest, contracts.CodeBadRequest, err)
+		return
+	}
+	data := make([]models.Metric, len(list))
+	for i, contract := range list {
+		metric, err := fromContract(contract)
+		if err != nil {
+			fail(context, http.StatusBadRequest, contracts.CodeInvalidMetric, fmt.Errorf("metric %d: %w", i, err))
+			return
+		}
+		data[i] = metric
+	}
+	if err := m.service.BatchUpdate(context, data); err != nil {
+		failWith(context, err)
+		return
+	}
+	render(context, http.StatusOK, contracts.Affected{Count: len(data)})
+}
+
+// fromContract metric of contract, value matching type is required
+func fromContract(contract contracts.Metric) (models.Metric, error) {
+	metric := models.Metric{ID: contract.ID, Type: contract.Type}
+	switch contract.Type {
+	case models.GaugeType:
+		metric.Value = contract.Value
+	case models.CounterType:
+		metric.Delta = contract.Delta
+	default:
+		return models.Metric{}, fmt.Errorf("%w %q", models.ErrUnknownMetricType, contract.Type)
+	}
+	if metric.Value == nil && metric.Delta == nil {
+		return models.Metric{}, fmt.Errorf("%s %s: %w", metric.Type, metric.ID, usecase.ErrMissingValue)
+	}
+	return metric, nil
+}
+
+// negotiate pick response format of Accept header, request nothing can be rendered for is not acceptable
+func negotiate(context *gin.Context) {
+	format := context.NegotiateFormat(mediaJSON, mediaText, mediaProto, mediaProtoAlt)
+	if format == "" {
+		context.AbortWithStatusJSON(http.StatusNotAcceptable, contracts.ErrorModel{
+			Error: "response can be application/json, text/plain or application/x-protobuf",
+			Code:  contracts.CodeNotAcceptable,
+		})
+		return
+	}
+	context.Set(formatKey, format)
+	context.Next()
+}
+
+// render write body in negotiated format
+func render(context *gin.Context, status int, body v2Body) {
+	switch format := context.GetString(formatKey); format {
+	case mediaText:
+		context.Data(status, mediaText+"; charset=utf-8", body.AppendText(nil))
+	case mediaProto, mediaProtoAlt:
+		context.Data(status, format, body.AppendProto(nil))
+	default:
+		context.JSON(status, body)
+	}
+}
+
+// fail write error with code in negotiated format
+func fail(context *gin.Context, status int, code contracts.ErrorCode, err error) {
+	render(context, status, contracts.ErrorModel{Error: err.Error(), Code: code})
+}
+
+// failWith write error of service with status and code matching it, unexpected errors are logged
+func failWith(context *gin.Context, err error) {
+	status, code := http.StatusInternalServerError, contracts.CodeInternal
+	switch {
+	case errors.Is(err, usecase.ErrMetricNotFound):
+		status, code = http.StatusNotFound, contracts.CodeMetricNotFound
+	case errors.Is(err, usecase.ErrInvalidMetricName), errors.Is(err, usecase.ErrMetricNameTooLong):
+		status, code = http.StatusBadRequest, contracts.CodeInvalidMetricName
+	case errors.Is(err, usecase.ErrInvalidFilter):
+		status, code = http.StatusBadRequest, contracts.CodeInvalidFilter
+	case errors.Is(err, usecase.ErrInvalidCursor):
+		status, code = http.StatusBadRequest, contracts.CodeInvalidCursor
+	case errors.Is(err, usecase.ErrMetricNameRejected):
+		status, code = http.StatusUnprocessableEntity, contracts.CodeMetricNameRejected
+	case errors.Is(err, usecase.ErrMetricLimitExceeded):
+		status, code = http.StatusUnprocessableEntity, contracts.CodeMetricLimitExceeded
+	default:
+		logging.Log.Error("request failed", zap.String("path", context.Request.URL.Path), zap.Error(err))
+	}
+	fail(context, status, code, err)
+}
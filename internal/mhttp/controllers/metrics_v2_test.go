@@ -0,0 +1,181 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/DimKa163/go-metrics/internal/files"
+	"github.com/DimKa163/go-metrics/internal/mhttp/contracts"
+	"github.com/DimKa163/go-metrics/internal/models"
+	"github.com/DimKa163/go-metrics/internal/persistence/mem"
+	"github.com/DimKa163/go-metrics/internal/usecase"
+)
+
+func configureV2(t *testing.T) *gin.Engine {
+	repository, err := mem.NewStore(files.NewFiler(filepath.Join(t.TempDir(), "dump"), []int{1}), mem.StoreOption{})
+	require.NoError(t, err)
+	service := usecase.NewMetricService(repository, usecase.MetricOptions{
+		Policy: usecase.MetricPolicy{Block: []string{"debug.*"}},
+	})
+	require.NoError(t, service.BatchUpdate(context.Background(), []models.Metric{
+		*models.CreateGauge("Alloc", 1.5),
+		*models.CreateCounter("PollCount", 7),
+	}))
+	router := gin.Default()
+	NewMetricV2Controller(service).Map(router)
+	return router
+}
+
+func serveV2(router *gin.Engine, method, target, accept, body string) *httptest.ResponseRecorder {
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	router.ServeHTTP(res, req)
+	return res
+}
+
+func decodeError(t *testing.T, res *httptest.ResponseRecorder) contracts.ErrorModel {
+	var model contracts.ErrorModel
+	require.NoError(t, json.Unmarshal(res.Body.Bytes(), &model), res.Body.String())
+	return model
+}
+
+func TestV2Get(t *testing.T) {
+	router := configureV2(t)
+
+	res := serveV2(router, http.MethodGet, "/api/v2/metrics/gauge/Alloc", "", "")
+	require.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "application/json; charset=utf-8", res.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"id":"Alloc","type":"gauge","value":1.5}`, res.Body.String())
+
+	res = serveV2(router, http.MethodGet, "/api/v2/metrics/counter/PollCount", "text/plain", "")
+	require.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "text/plain; charset=utf-8", res.Header().Get("Content-Type"))
+	assert.Equal(t, "7", res.Body.String())
+
+	res = serveV2(router, http.MethodGet, "/api/v2/metrics/counter/Alloc", "", "")
+	assert.Equal(t, http.StatusNotFound, res.Code)
+	assert.Equal(t, contracts.CodeTypeMismatch, decodeError(t, res).Code)
+
+	res = serveV2(router, http.MethodGet, "/api/v2/metrics/gauge/Missing", "", "")
+	assert.Equal(t, http.StatusNotFound, res.Code)
+	assert.Equal(t, contracts.CodeMetricNotFound, decodeError(t, res).Code)
+
+	res = serveV2(router, http.MethodGet, "/api/v2/metrics/gauge/Missing", "text/plain", "")
+	assert.Equal(t, http.StatusNotFound, res.Code)
+	assert.Equal(t, "metric_not_found: metric not found", res.Body.String())
+
+	res = serveV2(router, http.MethodGet, "/api/v2/metrics/gauge/Alloc", "image/png", "")
+	assert.Equal(t, http.StatusNotAcceptable, res.Code)
+	assert.Equal(t, contracts.CodeNotAcceptable, decodeError(t, res).Code)
+}
+
+func TestV2Protobuf(t *testing.T) {
+	router := configureV2(t)
+
+	res := serveV2(router, http.MethodGet, "/api/v2/metrics/gauge/Alloc", "application/x-protobuf", "")
+	require.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "application/x-protobuf", res.Header().Get("Content-Type"))
+	fields := consumeFields(t, res.Body.Bytes())
+	assert.Equal(t, "Alloc", string(fields[1].([]byte)))
+	assert.Equal(t, "gauge", string(fields[2].([]byte)))
+	assert.Equal(t, 1.5, math.Float64frombits(fields[3].(uint64)))
+
+	res = serveV2(router, http.MethodGet, "/api/v2/metrics?limit=1", "application/protobuf", "")
+	require.Equal(t, http.StatusOK, res.Code)
+	page := consumeFields(t, res.Body.Bytes())
+	metric := consumeFields(t, page[1].([]byte))
+	assert.Equal(t, "Alloc", string(metric[1].([]byte)))
+	assert.NotEmpty(t, page[2])
+	assert.Equal(t, string(page[2].([]byte)), res.Header().Get("X-Next-Cursor"))
+
+	res = serveV2(router, http.MethodGet, "/api/v2/metrics/gauge/PollCount", "application/x-protobuf", "")
+	require.Equal(t, http.StatusNotFound, res.Code)
+	model := consumeFields(t, res.Body.Bytes())
+	assert.Equal(t, string(contracts.CodeTypeMismatch), string(model[2].([]byte)))
+}
+
+func TestV2Update(t *testing.T) {
+	router := configureV2(t)
+
+	res := serveV2(router, http.MethodPost, "/api/v2/metrics", "", `{"id":"PollCount","type":"counter","delta":3}`)
+	require.Equal(t, http.StatusOK, res.Code)
+	assert.JSONEq(t, `{"id":"PollCount","type":"counter","delta":10}`, res.Body.String())
+
+	res = serveV2(router, http.MethodPost, "/api/v2/metrics/batch", "text/plain",
+		`[{"id":"a","type":"gauge","value":1},{"id":"b","type":"counter","delta":2}]`)
+	require.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "2", res.Body.String())
+
+	res = serveV2(router, http.MethodGet, "/api/v2/metrics?prefix=b", "text/plain", "")
+	require.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "b counter 2\n", res.Body.String())
+
+	cases := []struct {
+		target string
+		body   string
+		status int
+		code   contracts.ErrorCode
+	}{
+		{"/api/v2/metrics", `{"id":`, http.StatusBadRequest, contracts.CodeBadRequest},
+		{"/api/v2/metrics", `{"id":"x","type":"histogram","value":1}`, http.StatusBadRequest, contracts.CodeInvalidMetric},
+		{"/api/v2/metrics", `{"id":"x","type":"counter","value":1}`, http.StatusBadRequest, contracts.CodeInvalidMetric},
+		{"/api/v2/metrics", `{"id":"debug.x","type":"gauge","value":1}`, http.StatusUnprocessableEntity, contracts.CodeMetricNameRejected},
+		{"/api/v2/metrics/batch", `[{"id":"x","type":"gauge"}]`, http.StatusBadRequest, contracts.CodeInvalidMetric},
+	}
+	for _, c := range cases {
+		res = serveV2(router, http.MethodPost, c.target, "", c.body)
+		assert.Equal(t, c.status, res.Code, c.body)
+		assert.Equal(t, c.code, decodeError(t, res).Code, c.body)
+	}
+
+	res = serveV2(router, http.MethodGet, "/api/v2/metrics?order=up", "", "")
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+	assert.Equal(t, contracts.CodeInvalidFilter, decodeError(t, res).Code)
+	res = serveV2(router, http.MethodGet, "/api/v2/metrics?cursor=!!", "", "")
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+	assert.Equal(t, contracts.CodeInvalidCursor, decodeError(t, res).Code)
+}
+
+// consumeFields last value of every field of message, bytes for length delimited fields and uint64 otherwise
+func consumeFields(t *testing.T, b []byte) map[protowire.Number]any {
+	fields := make(map[protowire.Number]any)
+	for len(b) > 0 {
+		number, typ, n := protowire.ConsumeTag(b)
+		require.GreaterOrEqual(t, n, 0)
+		b = b[n:]
+		switch typ {
+		case protowire.BytesType:
+			value, n := protowire.ConsumeBytes(b)
+			require.GreaterOrEqual(t, n, 0)
+			fields[number], b = value, b[n:]
+		case protowire.Fixed64Type:
+			value, n := protowire.ConsumeFixed64(b)
+			require.GreaterOrEqual(t, n, 0)
+			fields[number], b = value, b[n:]
+		case protowire.VarintType:
+			value, n := protowire.ConsumeVarint(b)
+			require.GreaterOrEqual(t, n, 0)
+			fields[number], b = value, b[n:]
+		default:
+			t.Fatalf("unexpected wire type %d", typ)
+		}
+	}
+	return fields
+}
@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/DimKa163/go-metrics/internal/mhttp/contracts"
+	"github.com/DimKa163/go-metrics/internal/mhttp/middleware"
+	"github.com/DimKa163/go-metrics/internal/models"
+	"github.com/DimKa163/go-metrics/internal/usecase"
+)
+
+type Push interface {
+	Map(engine *gin.Engine)
+
+	Replace(context *gin.Context)
+
+	Merge(context *gin.Context)
+
+	Delete(context *gin.Context)
+
+	Groups(context *gin.Context)
+}
+
+type push struct {
+	service *usecase.PushService
+}
+
+func NewPushController(service *usecase.PushService) Push {
+	return &push{
+		service: service,
+	}
+}
+
+// Map map all routs
+func (p *push) Map(engine *gin.Engine) {
+	write := middleware.RequireScope(models.ScopeWrite)
+	for _, path := range []string{"/metrics/job/:job", "/metrics/job/:job/instance/:instance"} {
+		engine.PUT(path, write, p.Replace)
+		engine.POST(path, write, p.Merge)
+		engine.DELETE(path, write, p.Delete)
+	}
+	engine.GET("/metrics/groups", middleware.RequireScope(models.ScopeRead), p.Groups)
+}
+
+// Replace replace all metrics of group, metrics are stored with job and instance labels added to their ids
+// @Produce application/json
+// @Param job path string true "Job name"
+// @Param instance path string false "Instance name"
+// @Param metrics body []contracts.Metric true "metric array"
+// @Success 200 {string} string "success request"
+// @Failure 400 {object} contracts.ErrorModel "bad request"
+// @Failure 422 {object} contracts.ErrorModel "metric name or count is refused by policy"
+// @Failure 500 {object} contracts.ErrorModel "internal server error"
+// @Router /metrics/job/{job}/instance/{instance} [put]
+func (p *push) Replace(context *gin.Context) {
+	p.push(context, true)
+}
+
+// Merge add metrics to group, counters are added up
+// @Produce application/json
+// @Param job path string true "Job name"
+// @Param instance path string false "Instance name"
+// @Param metrics body []contracts.Metric true "metric array"
+// @Success 200 {string} string "success request"
+// @Failure 400 {object} contracts.ErrorModel "bad request"
+// @Failure 422 {object} contracts.ErrorModel "metric name or count is refused by policy"
+// @Failure 500 {object} contracts.ErrorModel "internal server error"
+// @Router /metrics/job/{job}/instance/{instance} [post]
+func (p *push) Merge(context *gin.Context) {
+	p.push(context, false)
+}
+
+// Delete remove group
+// @Param job path string true "Job name"
+// @Param instance path string false "Instance name"
+// @Success 200 {string} string "success request"
+// @Failure 404 {object} contracts.ErrorModel "group not found"
+// @Router /metrics/job/{job}/instance/{instance} [delete]
+func (p *push) Delete(context *gin.Context) {
+	err := p.service.Delete(context, groupKey(context))
+	if err != nil {
+		if errors.Is(err, usecase.ErrGroupNotFound) {
+			context.JSON(http.StatusNotFound, contracts.ErrorModel{Error: err.Error()})
+			return
+		}
+		context.JSON(http.StatusInternalServerError, contracts.ErrorModel{Error: err.Error()})
+		return
+	}
+	context.Status(http.StatusOK)
+}
+
+// Groups all pushed groups
+// @Produce application/json
+// @Success 200 {array} contracts.Group "success request"
+// @Failure 500 {object} contracts.ErrorModel "internal server error"
+// @Router /metrics/groups [get]
+func (p *push) Groups(context *gin.Context) {
+	groups, err := p.service.Groups(context)
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, contracts.ErrorModel{Error: err.Error()})
+		return
+	}
+	result := make([]contracts.Group, len(groups))
+	for i, group := range groups {
+		metrics := make([]contracts.Metric, len(group.Metrics))
+		for j, metric := range group.Metrics {
+			metrics[j] = contracts.Metric{
+				ID:    metric.ID,
+				Type:  metric.Type,
+				Value: metric.Value,
+				Delta: metric.Delta,
+			}
+		}
+		result[i] = contracts.Group{
+			Job:       group.Job,
+			Instance:  group.Instance,
+			Metrics:   metrics,
+			UpdatedAt: group.UpdatedAt,
+		}
+	}
+	context.JSON(http.StatusOK, result)
+}
+
+func (p *push) push(context *gin.Context, replace bool) {
+	var metricList []contracts.Metric
+	if err := context.ShouldBindJSON(&metricList); err != nil {
+		context.JSON(http.StatusBadRequest, contracts.ErrorModel{Error: err.Error()})
+		return
+	}
+	data := make([]models.Metric, len(metricList))
+	for i, metric := range metricList {
+		data[i] = models.Metric{
+			ID:    metric.ID,
+			Type:  metric.Type,
+			Value: metric.Value,
+			Delta: metric.Delta,
+		}
+	}
+	if err := p.service.Push(context, groupKey(context), data, replace); err != nil {
+		context.JSON(pushStatus(err), contracts.ErrorModel{Error: err.Error()})
+		return
+	}
+	context.Status(http.StatusOK)
+}
+
+// pushStatus status of failed push, metrics without type or value are bad requests like other
+// malformed updates
+func pushStatus(err error) int {
+	if errors.Is(err, models.ErrUnknownMetricType) || errors.Is(err, usecase.ErrMissingValue) {
+		return http.StatusBadRequest
+	}
+	return updateStatus(err)
+}
+
+func groupKey(context *gin.Context) models.GroupKey {
+	return models.GroupKey{
+		Job:      context.Param("job"),
+		Instance: context.Param("instance"),
+	}
+}
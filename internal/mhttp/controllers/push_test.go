@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DimKa163/go-metrics/internal/files"
+	"github.com/DimKa163/go-metrics/internal/mhttp/contracts"
+	"github.com/DimKa163/go-metrics/internal/persistence/mem"
+	"github.com/DimKa163/go-metrics/internal/usecase"
+)
+
+func TestPush(t *testing.T) {
+	repository, err := mem.NewStore(files.NewFiler(filepath.Join(t.TempDir(), "dump"), []int{1}), mem.StoreOption{})
+	require.NoError(t, err)
+	metrics := usecase.NewMetricService(repository, usecase.MetricOptions{
+		Policy: usecase.MetricPolicy{Block: []string{"Debug*"}},
+	})
+	router := gin.Default()
+	sut := NewPushController(usecase.NewPushService(metrics, repository))
+	sut.Map(router)
+	cases := []struct {
+		name               string
+		method             string
+		url                string
+		body               string
+		expectedStatusCode int
+	}{
+		{
+			name:               "replace group",
+			method:             http.MethodPut,
+			url:                "/metrics/job/backup/instance/db1",
+			body:               `[{"id": "Duration", "type": "gauge", "value": 12.5}]`,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "merge job group",
+			method:             http.MethodPost,
+			url:                "/metrics/job/backup",
+			body:               `[{"id": "Rows", "type": "counter", "delta": 10}]`,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "bad metric type",
+			method:             http.MethodPost,
+			url:                "/metrics/job/backup",
+			body:               `[{"id": "Rows", "type": "histogram", "delta": 10}]`,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "blocked metric",
+			method:             http.MethodPost,
+			url:                "/metrics/job/backup",
+			body:               `[{"id": "DebugRows", "type": "counter", "delta": 10}]`,
+			expectedStatusCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:               "delete group",
+			method:             http.MethodDelete,
+			url:                "/metrics/job/backup/instance/db1",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "delete missing group",
+			method:             http.MethodDelete,
+			url:                "/metrics/job/backup/instance/db1",
+			expectedStatusCode: http.StatusNotFound,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			res := httptest.NewRecorder()
+			router.ServeHTTP(res, httptest.NewRequest(c.method, c.url, strings.NewReader(c.body)))
+			assert.Equal(t, c.expectedStatusCode, res.Code)
+		})
+	}
+
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/metrics/groups", nil))
+	assert.Equal(t, http.StatusOK, res.Code)
+	var groups []contracts.Group
+	require.NoError(t, json.Unmarshal(res.Body.Bytes(), &groups))
+	require.Len(t, groups, 1)
+	assert.Equal(t, "backup", groups[0].Job)
+	assert.Empty(t, groups[0].Instance)
+	require.Len(t, groups[0].Metrics, 1)
+	assert.Equal(t, "Rows", groups[0].Metrics[0].ID)
+	assert.Equal(t, int64(10), *groups[0].Metrics[0].Delta)
+
+	stored, err := metrics.Get(context.Background(), `Rows{job="backup"}`)
+	require.NoError(t, err, "pushed metric is readable like any other")
+	assert.Equal(t, int64(10), *stored.Delta)
+}
@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/DimKa163/go-metrics/internal/mhttp/contracts"
+	"github.com/DimKa163/go-metrics/internal/mhttp/middleware"
+	"github.com/DimKa163/go-metrics/internal/models"
+	"github.com/DimKa163/go-metrics/internal/query"
+	"github.com/DimKa163/go-metrics/internal/usecase"
+)
+
+// defaultStep step of range query without step parameter
+const defaultStep = time.Minute
+
+type Query interface {
+	Map(engine *gin.Engine)
+
+	Query(context *gin.Context)
+}
+
+type queries struct {
+	service *usecase.MetricService
+}
+
+func NewQueryController(service *usecase.MetricService) Query {
+	return &queries{
+		service: service,
+	}
+}
+
+// Map map all routs
+func (q *queries) Map(engine *gin.Engine) {
+	engine.GET("/api/query", middleware.RequireScope(models.ScopeRead), q.Query)
+}
+
+// Query evaluate query, range is evaluated when start and end are given, otherwise instant at time.
+// Without time instant query uses current values
+// @Produce application/json
+// @Param query query string true "Query, e.g. sum(rate(PollCount[5m]))"
+// @Param time query string false "Evaluation time, unix seconds or RFC3339"
+// @Param start query string false "Range start, unix seconds or RFC3339"
+// @Param end query string false "Range end, unix seconds or RFC3339"
+// @Param step query string false "Range step, duration like 15s or seconds, 1m by default"
+// @Success 200 {object} contracts.QueryResult "success request"
+// @Failure 400 {object} contracts.ErrorModel "bad request"
+// @Failure 500 {object} contracts.ErrorModel "internal server error"
+// @Router /api/query [get]
+func (q *queries) Query(context *gin.Context) {
+	expr := context.Query("query")
+	if expr == "" {
+		context.JSON(http.StatusBadRequest, contracts.ErrorModel{Error: "query is required"})
+		return
+	}
+	var result query.Result
+	var err error
+	if context.Query("start") != "" || context.Query("end") != "" {
+		result, err = q.queryRange(context, expr)
+	} else {
+		var at time.Time
+		if at, err = parseTime(context.Query("time")); err == nil {
+			result, err = q.service.Query(context, expr, at)
+		}
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, query.ErrInvalidQuery) || errors.Is(err, errBadParameter) {
+			status = http.StatusBadRequest
+		}
+		context.JSON(status, contracts.ErrorModel{Error: err.Error()})
+		return
+	}
+	context.JSON(http.StatusOK, toQueryResult(result))
+}
+
+func (q *queries) queryRange(context *gin.Context, expr string) (query.Result, error) {
+	start, err := parseTime(context.Query("start"))
+	if err != nil {
+		return query.Result{}, err
+	}
+	end, err := parseTime(context.Query("end"))
+	if err != nil {
+		return query.Result{}, err
+	}
+	if start.IsZero() || end.IsZero() {
+		return query.Result{}, fmt.Errorf("%w: start and end are required", errBadParameter)
+	}
+	step := defaultStep
+	if value := context.Query("step"); value != "" {
+		if step, err = parseStep(value); err != nil {
+			return query.Result{}, err
+		}
+	}
+	return q.service.QueryRange(context, expr, start, end, step)
+}
+
+var errBadParameter = errors.New("bad parameter")
+
+// parseTime unix seconds or RFC3339 time, empty value is zero time
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.UnixMilli(int64(seconds * 1000)), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: time %q is neither unix seconds nor RFC3339", errBadParameter, value)
+	}
+	return t, nil
+}
+
+func parseStep(value string) (time.Duration, error) {
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+	step, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%w: step %q is neither duration nor seconds", errBadParameter, value)
+	}
+	return step, nil
+}
+
+func toQueryResult(result query.Result) contracts.QueryResult {
+	contract := contracts.QueryResult{Type: string(result.Type), Series: make([]contracts.QuerySeries, len(result.Series))}
+	for i, series := range result.Series {
+		samples := make([]contracts.QuerySample, len(series.Samples))
+		for j, sample := range series.Samples {
+			samples[j] = contracts.QuerySample{Time: float64(sample.Time.UnixMilli()) / 1000, Value: sample.Value}
+		}
+		contract.Series[i] = contracts.QuerySeries{Name: series.Name, Labels: series.Labels, Samples: samples}
+	}
+	return contract
+}
@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DimKa163/go-metrics/internal/mhttp/contracts"
+	"github.com/DimKa163/go-metrics/internal/models"
+	"github.com/DimKa163/go-metrics/internal/query"
+	"github.com/DimKa163/go-metrics/internal/usecase"
+)
+
+func TestQuery(t *testing.T) {
+	service := usecase.NewMetricService(configureFileRepository(), usecase.MetricOptions{History: query.NewHistory(10)})
+	for _, delta := range []int64{10, 20} {
+		_, err := service.Upsert(context.Background(), *models.CreateCounter("PollCount", delta))
+		require.NoError(t, err)
+	}
+	router := gin.Default()
+	NewQueryController(service).Map(router)
+	now := strconv.FormatInt(time.Now().Add(time.Second).Unix(), 10)
+
+	cases := []struct {
+		name               string
+		params             url.Values
+		expectedStatusCode int
+		expectedType       string
+		expectedValue      float64
+	}{
+		{"current value", url.Values{"query": {"PollCount"}}, http.StatusOK, "vector", 30},
+		{"sum of gauges", url.Values{"query": {"sum(Founded*)"}}, http.StatusOK, "vector", 58.9},
+		{"increase at time", url.Values{"query": {"increase(PollCount[5m])"}, "time": {now}}, http.StatusOK, "vector", 20},
+		{"range", url.Values{"query": {"max(PollCount)"}, "start": {now}, "end": {now}, "step": {"15s"}}, http.StatusOK, "matrix", 30},
+		{"missing query", url.Values{}, http.StatusBadRequest, "", 0},
+		{"invalid query", url.Values{"query": {"rate(PollCount)"}}, http.StatusBadRequest, "", 0},
+		{"invalid time", url.Values{"query": {"PollCount"}, "time": {"yesterday"}}, http.StatusBadRequest, "", 0},
+		{"range without end", url.Values{"query": {"PollCount"}, "start": {now}}, http.StatusBadRequest, "", 0},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			res := httptest.NewRecorder()
+			router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/api/query?"+c.params.Encode(), nil))
+			require.Equal(t, c.expectedStatusCode, res.Code, res.Body.String())
+			if c.expectedStatusCode != http.StatusOK {
+				return
+			}
+			var result contracts.QueryResult
+			require.NoError(t, json.Unmarshal(res.Body.Bytes(), &result))
+			assert.Equal(t, c.expectedType, result.Type)
+			require.Len(t, result.Series, 1)
+			assert.InDelta(t, c.expectedValue, result.Series[0].Samples[0].Value, 1e-9)
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"golang.org/x/net/websocket"
+
+	"github.com/DimKa163/go-metrics/internal/logging"
+	"github.com/DimKa163/go-metrics/internal/mhttp/contracts"
+	"github.com/DimKa163/go-metrics/internal/mhttp/middleware"
+	"github.com/DimKa163/go-metrics/internal/models"
+	"github.com/DimKa163/go-metrics/internal/stream"
+)
+
+const (
+	// heartbeatInterval idle time after which event stream sends comment, so proxies keep connection open
+	heartbeatInterval = 15 * time.Second
+	// sendTimeout longest time websocket message may take to be written
+	sendTimeout = 10 * time.Second
+)
+
+type Stream interface {
+	Map(engine *gin.Engine)
+
+	Events(context *gin.Context)
+
+	Socket(context *gin.Context)
+}
+
+type streams struct {
+	hub *stream.Hub
+}
+
+func NewStreamController(hub *stream.Hub) Stream {
+	return &streams{
+		hub: hub,
+	}
+}
+
+// Map map all routs
+func (s *streams) Map(engine *gin.Engine) {
+	read := middleware.RequireScope(models.ScopeRead)
+	engine.GET("/stream", read, s.Events)
+	engine.GET("/stream/ws", read, s.Socket)
+}
+
+// Events stream accepted updates as server-sent events named metrics
+// @Produce text/event-stream
+// @Param type query string false "Metric type, gauge or counter"
+// @Param name query string false "Comma separated metric name globs, e.g. cpu.*,PollCount"
+// @Success 200 {object} contracts.StreamMessage "data of every event"
+// @Failure 400 {object} contracts.ErrorModel "bad request"
+// @Router /stream [get]
+func (s *streams) Events(context *gin.Context) {
+	filter, ok := streamFilter(context)
+	if !ok {
+		return
+	}
+	subscription := s.hub.Subscribe(models.TenantFromContext(context), filter)
+	defer subscription.Close()
+
+	header := context.Writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no")
+	context.Status(http.StatusOK)
+	context.Writer.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-context.Request.Context().Done():
+			return
+		case <-subscription.Done():
+			return
+		case <-heartbeat.C:
+			if _, err := context.Writer.WriteString(": ping\n\n"); err != nil {
+				return
+			}
+			context.Writer.Flush()
+		case <-subscription.Ready():
+			metrics, dropped := subscription.Take()
+			if len(metrics) == 0 && dropped == 0 {
+				continue
+			}
+			context.SSEvent("metrics", streamMessage(metrics, dropped))
+			context.Writer.Flush()
+			heartbeat.Reset(heartbeatInterval)
+		}
+	}
+}
+
+// Socket stream accepted updates over websocket, every message is JSON encoded contracts.StreamMessage
+// @Param type query string false "Metric type, gauge or counter"
+// @Param name query string false "Comma separated metric name globs, e.g. cpu.*,PollCount"
+// @Success 101 {string} string "switching protocols"
+// @Failure 400 {object} contracts.ErrorModel "bad request"
+// @Router /stream/ws [get]
+func (s *streams) Socket(context *gin.Context) {
+	filter, ok := streamFilter(context)
+	if !ok {
+		return
+	}
+	tenant := models.TenantFromContext(context)
+	server := websocket.Server{Handler: func(conn *websocket.Conn) {
+		subscription := s.hub.Subscribe(tenant, filter)
+		defer subscription.Close()
+		// messages from client are not expected, reading only notices closed connection
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			var discard []byte
+			for {
+				if err := websocket.Message.Receive(conn, &discard); err != nil {
+					return
+				}
+			}
+		}()
+		for {
+			select {
+			case <-closed:
+				return
+			case <-subscription.Done():
+				return
+			case <-subscription.Ready():
+				metrics, dropped := subscription.Take()
+				if len(metrics) == 0 && dropped == 0 {
+					continue
+				}
+				_ = conn.SetWriteDeadline(time.Now().Add(sendTimeout))
+				if err := websocket.JSON.Send(conn, streamMessage(metrics, dropped)); err != nil {
+					logging.Log.Info("websocket subscriber gone", zap.Error(err))
+					return
+				}
+			}
+		}
+	}}
+	server.ServeHTTP(context.Writer, context.Request)
+}
+
+// streamFilter filter from query, bad request is answered when it's malformed
+func streamFilter(context *gin.Context) (stream.Filter, bool) {
+	filter := stream.Filter{Type: context.Query("type")}
+	if filter.Type != "" && filter.Type != models.GaugeType && filter.Type != models.CounterType {
+		context.JSON(http.StatusBadRequest, contracts.ErrorModel{Error: models.ErrUnknownMetricType.Error()})
+		return filter, false
+	}
+	for _, names := range context.QueryArray("name") {
+		for _, name := range strings.Split(names, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				filter.Names = append(filter.Names, name)
+			}
+		}
+	}
+	if err := filter.Validate(); err != nil {
+		context.JSON(http.StatusBadRequest, contracts.ErrorModel{Error: err.Error()})
+		return filter, false
+	}
+	return filter, true
+}
+
+func streamMessage(metrics []models.Metric, dropped int64) contracts.StreamMessage {
+	message := contracts.StreamMessage{Metrics: make([]contracts.Metric, len(metrics)), Dropped: dropped}
+	for i, metric := range metrics {
+		message.Metrics[i] = contracts.Metric{ID: metric.ID, Type: metric.Type, Value: metric.Value, Delta: metric.Delta}
+	}
+	return message
+}
@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+
+	"github.com/DimKa163/go-metrics/internal/mhttp/contracts"
+	"github.com/DimKa163/go-metrics/internal/models"
+	"github.com/DimKa163/go-metrics/internal/stream"
+	"github.com/DimKa163/go-metrics/internal/usecase"
+)
+
+func TestStreamEvents(t *testing.T) {
+	hub := stream.NewHub(0)
+	service := usecase.NewMetricService(configureFileRepository(), usecase.MetricOptions{Publisher: hub})
+	router := gin.Default()
+	NewStreamController(hub).Map(router)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	res, err := http.Get(server.URL + "/stream?name=stream.*")
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+	waitSubscribers(t, hub, 1)
+
+	_, err = service.Upsert(context.Background(), *models.CreateGauge("other", 1))
+	require.NoError(t, err)
+	_, err = service.Upsert(context.Background(), *models.CreateGauge("stream.alloc", 2))
+	require.NoError(t, err)
+
+	reader := bufio.NewReader(res.Body)
+	line, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "event:metrics\n", line)
+	line, err = reader.ReadString('\n')
+	require.NoError(t, err)
+	var message contracts.StreamMessage
+	require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data:")), &message))
+	require.Len(t, message.Metrics, 1)
+	assert.Equal(t, "stream.alloc", message.Metrics[0].ID)
+	assert.Equal(t, 2.0, *message.Metrics[0].Value)
+
+	hub.Close()
+	waitSubscribers(t, hub, 0)
+
+	res, err = http.Get(server.URL + "/stream?type=histogram")
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+}
+
+func TestStreamSocket(t *testing.T) {
+	hub := stream.NewHub(0)
+	service := usecase.NewMetricService(configureFileRepository(), usecase.MetricOptions{Publisher: hub})
+	router := gin.Default()
+	NewStreamController(hub).Map(router)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	conn, err := websocket.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/stream/ws?type=counter", "", server.URL)
+	require.NoError(t, err)
+	defer conn.Close()
+	waitSubscribers(t, hub, 1)
+
+	require.NoError(t, service.BatchUpdate(context.Background(), []models.Metric{
+		*models.CreateGauge("ws.gauge", 1),
+		*models.CreateCounter("ws.counter", 3),
+	}))
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var message contracts.StreamMessage
+	require.NoError(t, websocket.JSON.Receive(conn, &message))
+	require.Len(t, message.Metrics, 1)
+	assert.Equal(t, "ws.counter", message.Metrics[0].ID)
+	assert.Equal(t, int64(3), *message.Metrics[0].Delta)
+
+	require.NoError(t, conn.Close())
+	waitSubscribers(t, hub, 0)
+}
+
+func waitSubscribers(t *testing.T, hub *stream.Hub, count int) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		return hub.Subscribers() == count
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/DimKa163/go-metrics/internal/mhttp/contracts"
+	"github.com/DimKa163/go-metrics/internal/mhttp/middleware"
+	"github.com/DimKa163/go-metrics/internal/models"
+	"github.com/DimKa163/go-metrics/internal/usecase"
+)
+
+type Tokens interface {
+	Map(engine *gin.Engine)
+
+	Create(context *gin.Context)
+
+	List(context *gin.Context)
+
+	Revoke(context *gin.Context)
+}
+
+type tokens struct {
+	service *usecase.TokenService
+}
+
+func NewTokenController(service *usecase.TokenService) Tokens {
+	return &tokens{
+		service: service,
+	}
+}
+
+// Map map all routs
+func (t *tokens) Map(engine *gin.Engine) {
+	admin := middleware.RequireScope(models.ScopeAdmin)
+	engine.POST("/api/tokens", admin, t.Create)
+	engine.GET("/api/tokens", admin, t.List)
+	engine.DELETE("/api/tokens/:id", admin, t.Revoke)
+}
+
+// Create issue token
+// @Produce application/json
+// @Param token body contracts.TokenRequest true "tenant and scopes"
+// @Success 201 {object} contracts.Token "issued token with secret"
+// @Failure 400 {object} contracts.ErrorModel "bad request"
+// @Failure 403 {object} contracts.ErrorModel "tenant can't be managed"
+// @Failure 500 {object} contracts.ErrorModel "internal server error"
+// @Router /api/tokens [post]
+func (t *tokens) Create(context *gin.Context) {
+	var request contracts.TokenRequest
+	if err := context.ShouldBindJSON(&request); err != nil {
+		context.JSON(http.StatusBadRequest, contracts.ErrorModel{Error: err.Error()})
+		return
+	}
+	scopes := make([]models.Scope, len(request.Scopes))
+	for i, value := range request.Scopes {
+		scope, err := models.ParseScope(value)
+		if err != nil {
+			context.JSON(http.StatusBadRequest, contracts.ErrorModel{Error: err.Error()})
+			return
+		}
+		scopes[i] = scope
+	}
+	token, secret, err := t.service.Create(context, request.Tenant, scopes)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrForeignTenant):
+			context.JSON(http.StatusForbidden, contracts.ErrorModel{Error: err.Error()})
+		case errors.Is(err, models.ErrInvalidTenant), errors.Is(err, usecase.ErrScopesRequired):
+			context.JSON(http.StatusBadRequest, contracts.ErrorModel{Error: err.Error()})
+		default:
+			context.JSON(http.StatusInternalServerError, contracts.ErrorModel{Error: err.Error()})
+		}
+		return
+	}
+	result := toTokenContract(token)
+	result.Secret = secret
+	context.JSON(http.StatusCreated, result)
+}
+
+// List tokens of tenant
+// @Produce application/json
+// @Success 200 {array} contracts.Token "success request"
+// @Failure 500 {object} contracts.ErrorModel "internal server error"
+// @Router /api/tokens [get]
+func (t *tokens) List(context *gin.Context) {
+	list, err := t.service.List(context)
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, contracts.ErrorModel{Error: err.Error()})
+		return
+	}
+	result := make([]contracts.Token, len(list))
+	for i, token := range list {
+		result[i] = toTokenContract(token)
+	}
+	context.JSON(http.StatusOK, result)
+}
+
+// Revoke delete token
+// @Param id path string true "Token id"
+// @Success 204 "token revoked"
+// @Failure 404 {object} contracts.ErrorModel "token not found"
+// @Failure 500 {object} contracts.ErrorModel "internal server error"
+// @Router /api/tokens/{id} [delete]
+func (t *tokens) Revoke(context *gin.Context) {
+	if err := t.service.Revoke(context, context.Param("id")); err != nil {
+		if errors.Is(err, usecase.ErrTokenNotFound) {
+			context.JSON(http.StatusNotFound, contracts.ErrorModel{Error: err.Error()})
+			return
+		}
+		context.JSON(http.StatusInternalServerError, contracts.ErrorModel{Error: err.Error()})
+		return
+	}
+	context.Status(http.StatusNoContent)
+}
+
+func toTokenContract(token models.Token) contracts.Token {
+	scopes := make([]string, len(token.Scopes))
+	for i, scope := range token.Scopes {
+		scopes[i] = string(scope)
+	}
+	return contracts.Token{
+		ID:        token.ID,
+		Tenant:    token.Tenant,
+		Scopes:    scopes,
+		CreatedAt: token.CreatedAt,
+	}
+}
@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DimKa163/go-metrics/internal/mhttp/contracts"
+	"github.com/DimKa163/go-metrics/internal/mhttp/middleware"
+	"github.com/DimKa163/go-metrics/internal/persistence/mem"
+	"github.com/DimKa163/go-metrics/internal/usecase"
+)
+
+const rootToken = "root-secret"
+
+func TestTenantIsolation(t *testing.T) {
+	router := configureAuthRouter(t)
+	alpha := issueToken(t, router, rootToken, `{"tenant": "alpha", "scopes": ["read", "write"]}`, http.StatusCreated)
+	beta := issueToken(t, router, rootToken, `{"tenant": "beta", "scopes": ["read", "write"]}`, http.StatusCreated)
+
+	assert.Equal(t, http.StatusOK, serve(router, http.MethodPost, "/update/gauge/HeapAlloc/1", alpha, "").Code)
+	assert.Equal(t, http.StatusOK, serve(router, http.MethodPost, "/update/gauge/HeapAlloc/2", beta, "").Code)
+
+	res := serve(router, http.MethodGet, "/value/gauge/HeapAlloc", alpha, "")
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "1", res.Body.String())
+	res = serve(router, http.MethodGet, "/value/gauge/HeapAlloc", beta, "")
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "2", res.Body.String())
+	assert.Equal(t, http.StatusNotFound, serve(router, http.MethodGet, "/value/gauge/HeapAlloc", rootToken, "").Code)
+}
+
+func TestTokenScopes(t *testing.T) {
+	router := configureAuthRouter(t)
+	reader := issueToken(t, router, rootToken, `{"tenant": "alpha", "scopes": ["read"]}`, http.StatusCreated)
+	admin := issueToken(t, router, rootToken, `{"tenant": "alpha", "scopes": ["admin"]}`, http.StatusCreated)
+
+	cases := []struct {
+		name               string
+		method             string
+		url                string
+		token              string
+		body               string
+		expectedStatusCode int
+	}{
+		{"no token", http.MethodGet, "/value/gauge/HeapAlloc", "", "", http.StatusUnauthorized},
+		{"unknown token", http.MethodGet, "/value/gauge/HeapAlloc", "unknown", "", http.StatusUnauthorized},
+		{"ping is open", http.MethodGet, "/ping", "", "", http.StatusOK},
+		{"read with read scope", http.MethodGet, "/value/gauge/HeapAlloc", reader, "", http.StatusNotFound},
+		{"write with read scope", http.MethodPost, "/update/gauge/HeapAlloc/1", reader, "", http.StatusForbidden},
+		{"manage tokens with read scope", http.MethodGet, "/api/tokens", reader, "", http.StatusForbidden},
+		{"admin writes", http.MethodPost, "/update/gauge/HeapAlloc/1", admin, "", http.StatusOK},
+		{
+			"tenant admin issues own token", http.MethodPost, "/api/tokens", admin,
+			`{"tenant": "alpha", "scopes": ["write"]}`, http.StatusCreated,
+		},
+		{
+			"tenant admin issues foreign token", http.MethodPost, "/api/tokens", admin,
+			`{"tenant": "beta", "scopes": ["write"]}`, http.StatusForbidden,
+		},
+		{"unknown scope", http.MethodPost, "/api/tokens", rootToken, `{"tenant": "beta", "scopes": ["root"]}`, http.StatusBadRequest},
+		{"invalid tenant", http.MethodPost, "/api/tokens", rootToken, `{"tenant": "a b", "scopes": ["read"]}`, http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.expectedStatusCode, serve(router, c.method, c.url, c.token, c.body).Code)
+		})
+	}
+}
+
+func TestRevokeToken(t *testing.T) {
+	router := configureAuthRouter(t)
+	writer := issueToken(t, router, rootToken, `{"tenant": "alpha", "scopes": ["write"]}`, http.StatusCreated)
+	assert.Equal(t, http.StatusOK, serve(router, http.MethodPost, "/update/counter/PollCount/1", writer, "").Code)
+
+	res := serve(router, http.MethodGet, "/api/tokens", rootToken, "")
+	require.Equal(t, http.StatusOK, res.Code)
+	var list []contracts.Token
+	require.NoError(t, json.Unmarshal(res.Body.Bytes(), &list))
+	require.Len(t, list, 1)
+	assert.Empty(t, list[0].Secret, "secret is never listed")
+
+	assert.Equal(t, http.StatusNoContent, serve(router, http.MethodDelete, "/api/tokens/"+list[0].ID, rootToken, "").Code)
+	assert.Equal(t, http.StatusUnauthorized, serve(router, http.MethodPost, "/update/counter/PollCount/1", writer, "").Code)
+	assert.Equal(t, http.StatusNotFound, serve(router, http.MethodDelete, "/api/tokens/"+list[0].ID, rootToken, "").Code)
+}
+
+func configureAuthRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	tokens, err := mem.NewTokenStore("")
+	require.NoError(t, err)
+	tokenService := usecase.NewTokenService(tokens, rootToken)
+	router := gin.New()
+	router.ContextWithFallback = true
+	router.Use(middleware.Authenticate(tokenService, "/ping"))
+	router.GET("/ping", func(c *gin.Context) { c.String(http.StatusOK, "pong") })
+	NewMetricController(usecase.NewMetricService(configureFileRepository(), usecase.MetricOptions{})).Map(router)
+	NewTokenController(tokenService).Map(router)
+	return router
+}
+
+func issueToken(t *testing.T, router *gin.Engine, token string, body string, expectedStatusCode int) string {
+	t.Helper()
+	res := serve(router, http.MethodPost, "/api/tokens", token, body)
+	require.Equal(t, expectedStatusCode, res.Code)
+	var issued contracts.Token
+	require.NoError(t, json.Unmarshal(res.Body.Bytes(), &issued))
+	require.NotEmpty(t, issued.Secret)
+	return issued.Secret
+}
+
+func serve(router *gin.Engine, method string, url string, token string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, url, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	return res
+}
@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/DimKa163/go-metrics/internal/logging"
+	"github.com/DimKa163/go-metrics/internal/mhttp/contracts"
+	"github.com/DimKa163/go-metrics/internal/mhttp/middleware"
+	"github.com/DimKa163/go-metrics/internal/models"
+	"github.com/DimKa163/go-metrics/internal/transfer"
+	"github.com/DimKa163/go-metrics/internal/usecase"
+)
+
+type Transfer interface {
+	Map(engine *gin.Engine)
+
+	Export(context *gin.Context)
+
+	Import(context *gin.Context)
+}
+
+type transfers struct {
+	service *usecase.MetricService
+}
+
+func NewTransferController(service *usecase.MetricService) Transfer {
+	return &transfers{
+		service: service,
+	}
+}
+
+// Map map all routs
+func (t *transfers) Map(engine *gin.Engine) {
+	engine.GET("/api/export", middleware.RequireScope(models.ScopeRead), t.Export)
+	engine.POST("/api/import", middleware.RequireScope(models.ScopeAdmin), t.Import)
+}
+
+// Export stream all metrics, format is taken from format parameter or Accept header, json by default
+// @Produce application/json
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Param format query string false "csv, json or ndjson"
+// @Success 200 {array} contracts.Metric "all metrics"
+// @Failure 400 {object} contracts.ErrorModel "bad request"
+// @Router /api/export [get]
+func (t *transfers) Export(context *gin.Context) {
+	format := transfer.FormatJSON
+	if value := context.Query("format"); value != "" {
+		format = transfer.FormatOf(value)
+	} else if accept := context.GetHeader("Accept"); accept != "" {
+		for _, value := range strings.Split(accept, ",") {
+			if found := transfer.FormatOf(strings.TrimSpace(value)); found != "" {
+				format = found
+				break
+			}
+		}
+	}
+	encoder, err := transfer.NewEncoder(context.Writer, format)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, contracts.ErrorModel{Error: err.Error()})
+		return
+	}
+	context.Header("Content-Type", transfer.ContentType(format))
+	context.Header("Content-Disposition", `attachment; filename="metrics.`+format+`"`)
+	context.Status(http.StatusOK)
+	err = t.service.Export(context, encoder.Encode)
+	if err == nil {
+		err = encoder.Close()
+	}
+	if err != nil {
+		// status is sent already, broken output is all client can notice
+		logging.Log.Error("export failed", zap.Error(err))
+		_ = context.Error(err)
+	}
+}
+
+// Import store metrics of body, format is taken from format parameter or Content-Type.
+// Counters are added to stored ones in merge mode and overwrite them in replace mode.
+// Import is applied at once, nothing is stored when it fails
+// @Accept application/json
+// @Accept text/csv
+// @Accept application/x-ndjson
+// @Produce application/json
+// @Param format query string false "csv, json or ndjson"
+// @Param mode query string false "merge or replace, merge by default"
+// @Param metrics body []contracts.Metric true "metrics"
+// @Success 200 {object} contracts.Affected "count of imported metrics"
+// @Failure 400 {object} contracts.ErrorModel "bad request, nothing is imported"
+// @Failure 422 {object} contracts.ErrorModel "metric name or count rejected by policy"
+// @Failure 500 {object} contracts.ErrorModel "internal server error"
+// @Router /api/import [post]
+func (t *transfers) Import(context *gin.Context) {
+	format := context.Query("format")
+	if format == "" {
+		format = context.ContentType()
+	}
+	if format == "" {
+		format = transfer.FormatJSON
+	}
+	decoder, err := transfer.NewDecoder(context.Request.Body, transfer.FormatOf(format))
+	if err != nil {
+		context.JSON(http.StatusBadRequest, contracts.ErrorModel{Error: err.Error()})
+		return
+	}
+	mode := usecase.ImportMode(context.DefaultQuery("mode", string(usecase.ImportMerge)))
+	count, err := t.service.Import(context, decoder.Decode, mode)
+	if err != nil {
+		status := updateStatus(err)
+		if errors.Is(err, usecase.ErrInvalidImportMode) || errors.Is(err, usecase.ErrInvalidImport) {
+			status = http.StatusBadRequest
+		}
+		context.JSON(status, contracts.ErrorModel{Error: err.Error()})
+		return
+	}
+	context.JSON(http.StatusOK, contracts.Affected{Count: count})
+}
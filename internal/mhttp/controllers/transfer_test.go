@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DimKa163/go-metrics/internal/files"
+	"github.com/DimKa163/go-metrics/internal/mhttp/contracts"
+	"github.com/DimKa163/go-metrics/internal/models"
+	"github.com/DimKa163/go-metrics/internal/persistence/mem"
+	"github.com/DimKa163/go-metrics/internal/usecase"
+)
+
+func TestExportImport(t *testing.T) {
+	source := transferService(t)
+	metrics := make([]models.Metric, 0, 1002)
+	for i := 0; i < 1001; i++ {
+		metrics = append(metrics, *models.CreateGauge(fmt.Sprintf("g%04d", i), float64(i)))
+	}
+	metrics = append(metrics, *models.CreateCounter("PollCount", 5))
+	require.NoError(t, source.BatchUpdate(context.Background(), metrics))
+	router := gin.Default()
+	NewTransferController(source).Map(router)
+
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/export", nil)
+	req.Header.Set("Accept", "text/csv")
+	router.ServeHTTP(res, req)
+	require.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "text/csv", res.Header().Get("Content-Type"))
+	lines := strings.Split(strings.TrimSpace(res.Body.String()), "\n")
+	require.Len(t, lines, 1003)
+	assert.Equal(t, "id,type,value,delta", lines[0])
+	assert.Equal(t, "PollCount,counter,,5", lines[1])
+	assert.Equal(t, "g1000,gauge,1000,", lines[1002])
+	export := res.Body.String()
+
+	target := transferService(t)
+	require.NoError(t, target.BatchUpdate(context.Background(), []models.Metric{*models.CreateCounter("PollCount", 2)}))
+	router = gin.Default()
+	NewTransferController(target).Map(router)
+	load := func(query string, contentType string, body string) (int, string) {
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/import"+query, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		router.ServeHTTP(res, req)
+		return res.Code, res.Body.String()
+	}
+
+	code, body := load("", "text/csv", export)
+	require.Equal(t, http.StatusOK, code, body)
+	var affected contracts.Affected
+	require.NoError(t, json.Unmarshal([]byte(body), &affected))
+	assert.Equal(t, 1002, affected.Count)
+	metric, err := target.Get(context.Background(), "PollCount")
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), *metric.Delta)
+	metric, err = target.Get(context.Background(), "g0999")
+	require.NoError(t, err)
+	assert.Equal(t, 999.0, *metric.Value)
+
+	code, body = load("?mode=replace&format=ndjson", "", `{"id":"PollCount","type":"counter","delta":1}`+"\n"+`{"id":"PollCount","type":"counter","delta":4}`)
+	require.Equal(t, http.StatusOK, code, body)
+	metric, err = target.Get(context.Background(), "PollCount")
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), *metric.Delta)
+
+	code, _ = load("?mode=sum", "application/json", `[]`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = load("", "text/html", `<p>`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, body = load("", "application/json", `[{"id":"Alloc","type":"gauge","value":1},{"id":"Bad","type":"gauge"}]`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Contains(t, body, "invalid import")
+	_, err = target.Get(context.Background(), "Alloc")
+	assert.ErrorIs(t, err, usecase.ErrMetricNotFound)
+}
+
+func transferService(t *testing.T) *usecase.MetricService {
+	repository, err := mem.NewStore(files.NewFiler(filepath.Join(t.TempDir(), "dump"), []int{1}), mem.StoreOption{})
+	require.NoError(t, err)
+	return usecase.NewMetricService(repository, usecase.MetricOptions{})
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"net"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/DimKa163/go-metrics/internal/models"
+)
+
+// Identify attach identity and address of client to request context, so services can tell agents apart.
+// Must run after Authenticate, authenticated client is known by token
+func Identify(proxies []*net.IPNet) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := models.WithAgent(c.Request.Context(), clientKey(c, proxies))
+		c.Request = c.Request.WithContext(models.WithAddress(ctx, clientAddress(c, proxies)))
+		c.Next()
+	}
+}
+
+// clientKey token id of authenticated client, otherwise client address
+func clientKey(c *gin.Context, proxies []*net.IPNet) string {
+	if value, ok := c.Get(principalKey); ok {
+		return "token:" + value.(*models.Token).ID
+	}
+	return "ip:" + clientAddress(c, proxies)
+}
+
+func clientAddress(c *gin.Context, proxies []*net.IPNet) string {
+	if ip := ClientIP(c.Request, proxies); ip != nil {
+		return ip.String()
+	}
+	return c.Request.RemoteAddr
+}
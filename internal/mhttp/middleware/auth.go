@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/DimKa163/go-metrics/internal/logging"
+	"github.com/DimKa163/go-metrics/internal/models"
+)
+
+const (
+	AuthorizationHeader = "Authorization"
+
+	principalKey = "principal"
+)
+
+type Authenticator interface {
+	Authenticate(ctx context.Context, secret string) (*models.Token, error)
+}
+
+// Authenticate require bearer token and bind request to tenant of the token, skipPaths are left open.
+// Tenant is stored in request context, so router must have ContextWithFallback enabled
+// for services to see it through gin.Context
+func Authenticate(authenticator Authenticator, skipPaths ...string) gin.HandlerFunc {
+	skip := make(map[string]struct{}, len(skipPaths))
+	for _, path := range skipPaths {
+		skip[path] = struct{}{}
+	}
+	return func(c *gin.Context) {
+		if _, ok := skip[c.Request.URL.Path]; ok {
+			c.Next()
+			return
+		}
+		header := c.GetHeader(AuthorizationHeader)
+		secret, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok {
+			c.Header("WWW-Authenticate", "Bearer")
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		token, err := authenticator.Authenticate(c.Request.Context(), strings.TrimSpace(secret))
+		if err != nil {
+			logging.Log.Info("authentication failed", zap.Error(err), zap.String("address", c.ClientIP()))
+			c.Header("WWW-Authenticate", "Bearer")
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Set(principalKey, token)
+		c.Request = c.Request.WithContext(models.WithTenant(c.Request.Context(), token.Tenant))
+		c.Next()
+	}
+}
+
+// RequireScope reject requests whose token lacks scope.
+// Requests without token pass, they only reach handlers when authentication is disabled
+func RequireScope(scope models.Scope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, ok := c.Get(principalKey)
+		if !ok {
+			c.Next()
+			return
+		}
+		if token := value.(*models.Token); !token.HasScope(scope) {
+			logging.Log.Info("token scope rejected",
+				zap.String("token", token.ID),
+				zap.String("scope", string(scope)),
+				zap.String("path", c.Request.URL.Path),
+			)
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		c.Next()
+	}
+}
@@ -2,22 +2,47 @@ package middleware
 
 import (
 	"bytes"
-	"github.com/DimKa163/go-metrics/internal/crypto"
-	"github.com/gin-gonic/gin"
+	"errors"
 	"io"
 	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/DimKa163/go-metrics/internal/crypto"
+	"github.com/DimKa163/go-metrics/internal/logging"
 )
 
+// CryptoMiddleware decrypt request body sealed by agent
 func CryptoMiddleware(decrypter *crypto.Decrypter) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if c.Request.Body == nil {
+			c.Next()
+			return
+		}
 		body, err := io.ReadAll(c.Request.Body)
 		if err != nil {
 			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		if len(body) == 0 {
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+			c.Next()
+			return
 		}
 		decrypted, err := decrypter.Decrypt(body)
 		if err != nil {
+			logging.Log.Info("failed to decrypt request", zap.Error(err))
+			if errors.Is(err, crypto.ErrInvalidEnvelope) || errors.Is(err, crypto.ErrUnsupportedEnvelope) ||
+				errors.Is(err, crypto.ErrUnknownKey) {
+				c.AbortWithStatus(http.StatusBadRequest)
+				return
+			}
 			c.AbortWithStatus(http.StatusInternalServerError)
+			return
 		}
-		c.Request.Body = io.NopCloser(bytes.NewBuffer(decrypted))
+		c.Request.Body = io.NopCloser(bytes.NewReader(decrypted))
+		c.Request.ContentLength = int64(len(decrypted))
+		c.Next()
 	}
 }
@@ -4,21 +4,26 @@ import (
 	"bufio"
 	"bytes"
 	"crypto/hmac"
-	"crypto/sha256"
 	"encoding/hex"
 	"errors"
-	"hash"
 	"io"
 	"net"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/DimKa163/go-metrics/internal/logging"
+	"github.com/DimKa163/go-metrics/internal/signature"
 )
 
-const HashHeader = "HashSHA256"
+const HashHeader = signature.Header
 
-// Hash data verification
-func Hash(key string) gin.HandlerFunc {
+// Hash verify request signature over method, path, timestamp, nonce and body.
+// Timestamp must be within guard skew window and nonce must not repeat, nonce of request
+// failed with server error or rate limit is released so transport retry is accepted.
+// Requests without signature are passed through, every response is signed over its complete body
+func Hash(key string, guard *signature.ReplayGuard) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		header := c.GetHeader(HashHeader)
 		if header != "" && c.Request.Body != nil {
@@ -28,53 +33,86 @@ func Hash(key string) gin.HandlerFunc {
 				return
 			}
 			c.Request.Body = io.NopCloser(bytes.NewBuffer(body))
-			expectedMAC := hmac.New(sha256.New, []byte(key))
-			expectedMAC.Write(body)
-			expectedSignature := expectedMAC.Sum(nil)
+			timestamp := c.GetHeader(signature.TimestampHeader)
+			nonce := c.GetHeader(signature.NonceHeader)
+			if timestamp == "" || nonce == "" {
+				c.AbortWithStatus(http.StatusBadRequest)
+				return
+			}
 			sign, err := hex.DecodeString(header)
 			if err != nil {
-				c.AbortWithStatus(http.StatusInternalServerError)
+				c.AbortWithStatus(http.StatusBadRequest)
 				return
 			}
+			expectedSignature := signature.Sign(key, c.Request.Method, signature.Path(c.Request.URL), timestamp, nonce, body)
 			if !hmac.Equal(sign, expectedSignature) {
 				c.AbortWithStatus(http.StatusBadRequest)
 				return
 			}
+			if err = guard.Check(timestamp, nonce); err != nil {
+				logging.Log.Info("signed request rejected", zap.Error(err), zap.String("address", c.ClientIP()))
+				c.AbortWithStatus(http.StatusUnauthorized)
+				return
+			}
+			defer func() {
+				if status := c.Writer.Status(); status >= http.StatusInternalServerError || status == http.StatusTooManyRequests {
+					guard.Release(nonce)
+				}
+			}()
 		}
-		c.Writer = NewHashWriter(c.Writer, key)
+		writer := NewHashWriter(c.Writer, key)
+		c.Writer = writer
 		c.Next()
+		writer.Commit()
 	}
 }
 
+// HashWriter buffer response body and sign it with HashSHA256 header once handlers are done.
+// Flushed or hijacked responses are streamed and left unsigned
 type HashWriter struct {
 	gin.ResponseWriter
-	hash.Hash
+	key       string
+	body      bytes.Buffer
+	streaming bool
 }
 
 func NewHashWriter(writer gin.ResponseWriter, key string) *HashWriter {
-	return &HashWriter{ResponseWriter: writer, Hash: hmac.New(sha256.New, []byte(key))}
-}
-
-func (h *HashWriter) Size() int {
-	return h.ResponseWriter.Size()
+	return &HashWriter{ResponseWriter: writer, key: key}
 }
 
 func (h *HashWriter) Write(b []byte) (int, error) {
-	_, err := h.Hash.Write(b)
-	if err != nil {
-		return 0, err
+	if h.streaming {
+		return h.ResponseWriter.Write(b)
 	}
-	return h.ResponseWriter.Write(b)
+	return h.body.Write(b)
+}
+
+func (h *HashWriter) WriteString(s string) (int, error) {
+	return h.Write([]byte(s))
 }
 
-func (h *HashWriter) WriteHeader(statusCode int) {
-	sum := h.Sum(nil)
-	hsh := hex.EncodeToString(sum)
-	h.Header().Set("HashSHA256", hsh)
-	h.ResponseWriter.WriteHeader(statusCode)
+// Commit sign buffered body and send it
+func (h *HashWriter) Commit() {
+	if h.streaming {
+		return
+	}
+	h.streaming = true
+	h.Header().Set(HashHeader, hex.EncodeToString(signature.SignResponse(h.key, h.body.Bytes())))
+	if h.body.Len() > 0 {
+		if _, err := h.ResponseWriter.Write(h.body.Bytes()); err != nil {
+			logging.Log.Info("failed to write response", zap.Error(err))
+		}
+	}
 }
 
+// Flush send buffered body unsigned and stream the rest of response
 func (h *HashWriter) Flush() {
+	if !h.streaming {
+		h.streaming = true
+		if h.body.Len() > 0 {
+			_, _ = h.ResponseWriter.Write(h.body.Bytes())
+		}
+	}
 	h.ResponseWriter.Flush()
 }
 
@@ -85,5 +123,6 @@ func (h *HashWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	if !ok {
 		return nil, nil, errors.New("the ResponseWriter doesn't support the Hijacker interface")
 	}
+	h.streaming = true
 	return hijacker.Hijack()
 }
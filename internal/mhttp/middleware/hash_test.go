@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DimKa163/go-metrics/internal/signature"
+)
+
+func TestHashSignsCompleteResponse(t *testing.T) {
+	router := gin.New()
+	router.Use(Hash("secret", signature.NewReplayGuard(time.Minute, 10)))
+	router.GET("/value", func(c *gin.Context) {
+		c.Status(http.StatusAccepted)
+		_, _ = c.Writer.WriteString(`{"id":`)
+		_, _ = c.Writer.Write([]byte(`"HeapAlloc"}`))
+	})
+	router.GET("/empty", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/value", nil))
+	assert.Equal(t, http.StatusAccepted, res.Code)
+	assert.Equal(t, `{"id":"HeapAlloc"}`, res.Body.String())
+	expected := signature.SignResponse("secret", []byte(`{"id":"HeapAlloc"}`))
+	assert.Equal(t, hex.EncodeToString(expected), res.Header().Get(HashHeader))
+
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/empty", nil))
+	assert.Equal(t, http.StatusNoContent, res.Code)
+	assert.Equal(t, hex.EncodeToString(signature.SignResponse("secret", nil)), res.Header().Get(HashHeader))
+}
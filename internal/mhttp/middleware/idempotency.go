@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/DimKa163/go-metrics/internal/logging"
+	"github.com/DimKa163/go-metrics/internal/usecase"
+)
+
+const (
+	IdempotencyHeader = "Idempotency-Key"
+	// ReplayedHeader set on acknowledgement of request whose key was applied already
+	ReplayedHeader = "Idempotent-Replayed"
+)
+
+// pendingRetryAfter seconds client should wait before retrying request whose key is in flight
+const pendingRetryAfter = 1
+
+type IdempotencyKeeper interface {
+	Claim(ctx context.Context, key string) (bool, error)
+	Bind(ctx context.Context, key string) context.Context
+	Complete(ctx context.Context, key string) error
+	Release(ctx context.Context, key string) error
+}
+
+// Idempotency apply write request carrying Idempotency-Key once. Key is pending while request is applied,
+// duplicate arriving meanwhile is refused with 409 and Retry-After, and done after request succeeded,
+// duplicate arriving then is acknowledged with empty 200 response. Key of request failed with any other
+// status is released so its retry is applied. Keys are kept per tenant, so middleware must run after authentication
+func Idempotency(keeper IdempotencyKeeper) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyHeader)
+		if key == "" || isRead(c) {
+			c.Next()
+			return
+		}
+		claimed, err := keeper.Claim(c.Request.Context(), key)
+		if err != nil {
+			switch {
+			case errors.Is(err, usecase.ErrInvalidIdempotencyKey):
+				c.AbortWithStatus(http.StatusBadRequest)
+			case errors.Is(err, usecase.ErrIdempotencyKeyPending):
+				logging.Log.Info("duplicate of request in flight refused", zap.String("key", key), zap.String("path", c.Request.URL.Path))
+				c.Header("Retry-After", strconv.Itoa(pendingRetryAfter))
+				c.AbortWithStatus(http.StatusConflict)
+			default:
+				logging.Log.Error("idempotency key claim failed", zap.Error(err))
+				c.AbortWithStatus(http.StatusInternalServerError)
+			}
+			return
+		}
+		if !claimed {
+			logging.Log.Info("duplicate request acknowledged", zap.String("key", key), zap.String("path", c.Request.URL.Path))
+			c.Header(ReplayedHeader, "true")
+			c.AbortWithStatus(http.StatusOK)
+			return
+		}
+		c.Request = c.Request.WithContext(keeper.Bind(c.Request.Context(), key))
+		c.Next()
+		// request context may be canceled already, completion or release must not be lost with it
+		ctx := context.WithoutCancel(c.Request.Context())
+		if status := c.Writer.Status(); status >= http.StatusOK && status < http.StatusMultipleChoices {
+			if err = keeper.Complete(ctx, key); err != nil {
+				logging.Log.Error("idempotency key completion failed", zap.String("key", key), zap.Error(err))
+			}
+			return
+		}
+		if err = keeper.Release(ctx, key); err != nil {
+			logging.Log.Error("idempotency key release failed", zap.String("key", key), zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DimKa163/go-metrics/internal/models"
+	"github.com/DimKa163/go-metrics/internal/persistence/mem"
+	"github.com/DimKa163/go-metrics/internal/usecase"
+)
+
+func TestIdempotency(t *testing.T) {
+	router := gin.New()
+	router.ContextWithFallback = true
+	router.Use(func(c *gin.Context) {
+		if tenant := c.GetHeader("X-Tenant"); tenant != "" {
+			c.Request = c.Request.WithContext(models.WithTenant(c.Request.Context(), tenant))
+		}
+	})
+	router.Use(Idempotency(usecase.NewIdempotencyService(mem.NewIdempotencyStore(10), time.Minute)))
+	applied := 0
+	status := http.StatusOK
+	router.POST("/updates", func(c *gin.Context) {
+		applied++
+		c.Status(status)
+	})
+	router.POST("/value/", func(c *gin.Context) {
+		applied++
+		c.Status(http.StatusOK)
+	})
+
+	send := func(key string, tenant string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/updates", strings.NewReader("[]"))
+		if key != "" {
+			req.Header.Set(IdempotencyHeader, key)
+		}
+		if tenant != "" {
+			req.Header.Set("X-Tenant", tenant)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, send("batch-1", "").Code)
+	w := send("batch-1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "true", w.Header().Get(ReplayedHeader))
+	assert.Equal(t, 1, applied, "duplicate is acknowledged without being applied")
+
+	assert.Equal(t, http.StatusOK, send("batch-1", "team").Code)
+	assert.Equal(t, 2, applied, "keys are kept per tenant")
+
+	send("", "")
+	send("", "")
+	assert.Equal(t, 4, applied, "requests without key are always applied")
+
+	status = http.StatusInternalServerError
+	assert.Equal(t, http.StatusInternalServerError, send("batch-2", "").Code)
+	status = http.StatusOK
+	assert.Equal(t, http.StatusOK, send("batch-2", "").Code)
+	assert.Empty(t, send("batch-3", "").Header().Get(ReplayedHeader))
+	assert.Equal(t, 7, applied, "key of failed request is released")
+
+	assert.Equal(t, http.StatusBadRequest, send("bad key", "").Code)
+	assert.Equal(t, 7, applied)
+
+	read := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/value/", strings.NewReader(`{"id":"a","type":"gauge"}`))
+		req.Header.Set(IdempotencyHeader, "read-1")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	read()
+	assert.Empty(t, read().Header().Get(ReplayedHeader))
+	assert.Equal(t, 9, applied, "key of read route is not claimed")
+}
+
+func TestIdempotencyConcurrentDuplicate(t *testing.T) {
+	router := gin.New()
+	router.Use(Idempotency(usecase.NewIdempotencyService(mem.NewIdempotencyStore(10), time.Minute)))
+	started := make(chan struct{})
+	finish := make(chan int)
+	var applied atomic.Int32
+	router.POST("/updates", func(c *gin.Context) {
+		applied.Add(1)
+		started <- struct{}{}
+		c.Status(<-finish)
+	})
+
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/updates", strings.NewReader("[]"))
+		req.Header.Set(IdempotencyHeader, "batch-1")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	// first returns status of first attempt which finishes with status once duplicate was sent
+	first := func(status int) int {
+		result := make(chan int)
+		go func() { result <- send().Code }()
+		<-started
+		w := send()
+		assert.Equal(t, http.StatusConflict, w.Code, "duplicate of request in flight is not acknowledged")
+		assert.Equal(t, "1", w.Header().Get("Retry-After"))
+		finish <- status
+		return <-result
+	}
+
+	assert.Equal(t, http.StatusInternalServerError, first(http.StatusInternalServerError))
+	assert.Equal(t, http.StatusOK, first(http.StatusOK), "key of failed attempt is released for retry")
+
+	w := send()
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "true", w.Header().Get(ReplayedHeader), "key is done once request succeeded")
+	assert.Equal(t, int32(2), applied.Load())
+}
+
+func TestIdempotencyBindsKey(t *testing.T) {
+	router := gin.New()
+	router.ContextWithFallback = true
+	router.Use(Idempotency(usecase.NewIdempotencyService(mem.NewIdempotencyStore(10), time.Minute)))
+	var bound models.IdempotencyKey
+	router.POST("/updates", func(c *gin.Context) {
+		bound, _ = models.IdempotencyKeyFromContext(c)
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/updates", strings.NewReader("[]"))
+	req.Header.Set(IdempotencyHeader, "batch-1")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, "batch-1", bound.Key, "repository finds key to complete in write transaction")
+	assert.True(t, bound.Expires.After(time.Now()))
+}
@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/DimKa163/go-metrics/internal/logging"
+	"github.com/DimKa163/go-metrics/internal/ratelimit"
+	"github.com/DimKa163/go-metrics/internal/transfer"
+)
+
+// RequestLimit limit requests per second of every client.
+// Client is token when request is authenticated, otherwise client address
+func RequestLimit(limiter *ratelimit.Limiter, proxies []*net.IPNet) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := clientKey(c, proxies)
+		if ok, wait := limiter.Take(key, 1); !ok {
+			reject(c, key, "requests", wait)
+			return
+		}
+		c.Next()
+	}
+}
+
+// MetricLimit limit metrics per second of every client, metrics are counted in decoded request body,
+// so middleware must run after gzip and crypto. JSON array counts its items, any other body
+// its non-blank lines, so CSV and NDJSON imports are counted by rows and single JSON metric as one
+func MetricLimit(limiter *ratelimit.Limiter, proxies []*net.IPNet) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Body == nil || isRead(c) {
+			c.Next()
+			return
+		}
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewBuffer(body))
+		key := clientKey(c, proxies)
+		if ok, wait := limiter.Take(key, countMetrics(body, bodyFormat(c))); !ok {
+			reject(c, key, "metrics", wait)
+			return
+		}
+		c.Next()
+	}
+}
+
+func countMetrics(body []byte, format string) int {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return 1
+	}
+	if body[0] == '[' {
+		var items []json.RawMessage
+		if err := json.Unmarshal(body, &items); err != nil || len(items) == 0 {
+			return 1
+		}
+		return len(items)
+	}
+	lines := 0
+	for len(body) > 0 {
+		line := body
+		if end := bytes.IndexByte(body, '\n'); end >= 0 {
+			line, body = body[:end], body[end+1:]
+		} else {
+			body = nil
+		}
+		if len(bytes.TrimSpace(line)) > 0 {
+			lines++
+		}
+	}
+	// header of CSV is not a metric
+	if format == transfer.FormatCSV && lines > 1 {
+		lines--
+	}
+	return lines
+}
+
+// bodyFormat format of import body taken like import does, empty for other requests
+func bodyFormat(c *gin.Context) string {
+	format := c.Query("format")
+	if format == "" {
+		format = c.ContentType()
+	}
+	return transfer.FormatOf(format)
+}
+
+func reject(c *gin.Context, key string, budget string, wait time.Duration) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	logging.Log.Info("rate limit exceeded",
+		zap.String("client", key),
+		zap.String("budget", budget),
+		zap.Int("retry_after", seconds),
+	)
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.AbortWithStatus(http.StatusTooManyRequests)
+}
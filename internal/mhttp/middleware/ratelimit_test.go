@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DimKa163/go-metrics/internal/ratelimit"
+	"github.com/DimKa163/go-metrics/internal/transfer"
+)
+
+func TestRequestLimit(t *testing.T) {
+	router := gin.New()
+	router.Use(RequestLimit(ratelimit.NewLimiter(1, 2), nil))
+	router.GET("/value", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	send := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/value", nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	assert.Equal(t, http.StatusOK, send("10.0.0.1:1000").Code)
+	assert.Equal(t, http.StatusOK, send("10.0.0.1:1001").Code)
+	w := send("10.0.0.1:1002")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.Equal(t, http.StatusOK, send("10.0.0.2:1000").Code, "other client has own budget")
+}
+
+func TestMetricLimit(t *testing.T) {
+	router := gin.New()
+	router.Use(MetricLimit(ratelimit.NewLimiter(5, 5), nil))
+	router.POST("/updates", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	send := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/updates", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	assert.Equal(t, http.StatusOK, send(`[{"id":"a"},{"id":"b"},{"id":"c"}]`).Code)
+	assert.Equal(t, http.StatusOK, send(`{"id":"d"}`).Code)
+	w := send(`[{"id":"e"},{"id":"f"}]`)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+}
+
+func TestCountMetrics(t *testing.T) {
+	cases := []struct {
+		body     string
+		format   string
+		expected int
+	}{
+		{`[{"id":"a"},{"id":"b"}]`, "", 2},
+		{`{"id":"a"}`, "", 1},
+		{"{\"id\":\"a\"}\n\n{\"id\":\"b\"}\n{\"id\":\"c\"}\n", transfer.FormatNDJSON, 3},
+		{"id,type,value,delta\nA,gauge,1,\nB,counter,,2\n", transfer.FormatCSV, 2},
+		{"", "", 1},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.expected, countMetrics([]byte(c.body), c.format), c.body)
+	}
+}
@@ -0,0 +1,145 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/DimKa163/go-metrics/internal/logging"
+)
+
+const (
+	RealIPHeader       = "X-Real-IP"
+	ForwardedForHeader = "X-Forwarded-For"
+)
+
+// SubnetPolicy allowed client networks, empty list does not restrict requests
+type SubnetPolicy struct {
+	// Write networks allowed to change metrics
+	Write []*net.IPNet
+	// Read networks allowed to read metrics
+	Read []*net.IPNet
+	// Proxies peers whose X-Real-IP and X-Forwarded-For headers are trusted
+	Proxies []*net.IPNet
+}
+
+// ParseSubnets parse comma separated CIDR list, single address is treated as host network
+func ParseSubnets(value string) ([]*net.IPNet, error) {
+	var subnets []*net.IPNet
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid address %q", item)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+			subnets = append(subnets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, subnet, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, err
+		}
+		subnets = append(subnets, subnet)
+	}
+	return subnets, nil
+}
+
+// TrustedSubnet reject requests from addresses outside of policy networks, read routes are checked
+// against read networks and other routes against write networks
+func TrustedSubnet(policy SubnetPolicy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		allowed := policy.Write
+		if isRead(c) {
+			allowed = policy.Read
+		}
+		if len(allowed) == 0 {
+			c.Next()
+			return
+		}
+		ip := ClientIP(c.Request, policy.Proxies)
+		if ip == nil || !contains(allowed, ip) {
+			logging.Log.Warn("request from untrusted address rejected",
+				zap.String("address", addressString(ip, c.Request.RemoteAddr)),
+				zap.String("method", c.Request.Method),
+				zap.String("path", c.Request.URL.Path),
+			)
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		c.Next()
+	}
+}
+
+// ClientIP address of client. Headers are honoured only when request came from trusted proxy,
+// X-Forwarded-For is walked from the right skipping trusted proxies
+func ClientIP(req *http.Request, proxies []*net.IPNet) net.IP {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	peer := net.ParseIP(host)
+	if peer == nil || !contains(proxies, peer) {
+		return peer
+	}
+	if header := strings.TrimSpace(req.Header.Get(RealIPHeader)); header != "" {
+		if ip := net.ParseIP(header); ip != nil {
+			return ip
+		}
+	}
+	forwarded := strings.Split(req.Header.Get(ForwardedForHeader), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(forwarded[i]))
+		if ip == nil {
+			break
+		}
+		if !contains(proxies, ip) {
+			return ip
+		}
+		peer = ip
+	}
+	return peer
+}
+
+// readRoutes routes sending request body which only read metrics, keyed by method and route path
+var readRoutes = map[string]struct{}{
+	http.MethodPost + " /value/": {},
+}
+
+// isRead report whether matched route only reads metrics, GET, HEAD and OPTIONS routes always do
+func isRead(c *gin.Context) bool {
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	_, ok := readRoutes[c.Request.Method+" "+c.FullPath()]
+	return ok
+}
+
+func contains(subnets []*net.IPNet, ip net.IP) bool {
+	for _, subnet := range subnets {
+		if subnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func addressString(ip net.IP, remoteAddr string) string {
+	if ip == nil {
+		return remoteAddr
+	}
+	return ip.String()
+}
@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrustedSubnet(t *testing.T) {
+	write, err := ParseSubnets("192.168.1.0/24, 10.0.0.5")
+	require.NoError(t, err)
+	read, err := ParseSubnets("192.168.0.0/16")
+	require.NoError(t, err)
+	proxies, err := ParseSubnets("172.16.0.1")
+	require.NoError(t, err)
+	router := gin.New()
+	router.Use(TrustedSubnet(SubnetPolicy{Write: write, Read: read, Proxies: proxies}))
+	router.POST("/updates", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/value", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.POST("/value/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		remoteAddr string
+		headers    map[string]string
+		expected   int
+	}{
+		{"write from subnet", http.MethodPost, "/updates", "192.168.1.10:5000", nil, http.StatusOK},
+		{"write from single address", http.MethodPost, "/updates", "10.0.0.5:5000", nil, http.StatusOK},
+		{"write outside subnet", http.MethodPost, "/updates", "192.168.2.10:5000", nil, http.StatusForbidden},
+		{"read from read subnet", http.MethodGet, "/value", "192.168.2.10:5000", nil, http.StatusOK},
+		{"read outside read subnet", http.MethodGet, "/value", "10.0.0.5:5000", nil, http.StatusForbidden},
+		{"json read from read subnet", http.MethodPost, "/value/", "192.168.2.10:5000", nil, http.StatusOK},
+		{"json read outside read subnet", http.MethodPost, "/value/", "10.0.0.5:5000", nil, http.StatusForbidden},
+		{
+			"header ignored from untrusted peer", http.MethodPost, "/updates", "8.8.8.8:5000",
+			map[string]string{RealIPHeader: "192.168.1.10"}, http.StatusForbidden,
+		},
+		{
+			"real ip from trusted proxy", http.MethodPost, "/updates", "172.16.0.1:5000",
+			map[string]string{RealIPHeader: "192.168.1.10"}, http.StatusOK,
+		},
+		{
+			"forwarded for from trusted proxy", http.MethodPost, "/updates", "172.16.0.1:5000",
+			map[string]string{ForwardedForHeader: "8.8.8.8, 192.168.1.10, 172.16.0.1"}, http.StatusOK,
+		},
+		{
+			"spoofed forwarded for", http.MethodPost, "/updates", "172.16.0.1:5000",
+			map[string]string{ForwardedForHeader: "192.168.1.10, 8.8.8.8"}, http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.RemoteAddr = tt.remoteAddr
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.expected, w.Code)
+		})
+	}
+}
+
+func TestTrustedSubnetEmptyPolicy(t *testing.T) {
+	router := gin.New()
+	router.Use(TrustedSubnet(SubnetPolicy{}))
+	router.POST("/updates", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	req := httptest.NewRequest(http.MethodPost, "/updates", nil)
+	req.RemoteAddr = "8.8.8.8:5000"
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestParseSubnetsInvalid(t *testing.T) {
+	_, err := ParseSubnets("192.168.1.0/33")
+	assert.Error(t, err)
+	_, err = ParseSubnets("localhost")
+	assert.Error(t, err)
+	subnets, err := ParseSubnets("")
+	assert.NoError(t, err)
+	assert.Empty(t, subnets)
+}
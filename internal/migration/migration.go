@@ -0,0 +1,241 @@
+// Package migration copy metrics of every tenant from one repository to another and verify
+// the copy by comparing count and checksum of each tenant on both sides.
+//
+// Target repository stamps copied metrics with time of the copy, so update time is not carried over.
+// Metrics expired by metric ttl are skipped instead of being copied, otherwise they would come back
+// to life in target while export of source already hides them.
+// Ids are checked against limit of target before anything is written, so migration never stops halfway.
+package migration
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/DimKa163/go-metrics/internal/models"
+	"github.com/DimKa163/go-metrics/internal/persistence"
+)
+
+// DefaultBatchSize metrics read and written at once
+const DefaultBatchSize = 500
+
+var (
+	ErrVerificationFailed = errors.New("verification failed")
+	ErrIDTooLong          = errors.New("metric id is too long for target")
+)
+
+// Store repository which can name its tenants
+type Store interface {
+	persistence.Repository
+	persistence.TenantLister
+}
+
+// Options of migration, zero value copies in batches of DefaultBatchSize
+type Options struct {
+	BatchSize int
+	// DryRun read source and report what would be copied without writing target
+	DryRun bool
+	// Progress called after every batch
+	Progress func(progress Progress)
+	// TTL metrics not updated within ttl are expired and skipped, 0 copies every metric
+	TTL time.Duration
+	// MaxIDLength longest id target stores, 0 is unlimited
+	MaxIDLength int
+}
+
+// Progress of migration
+type Progress struct {
+	Tenant string
+	// Copied metrics of tenant copied so far
+	Copied int
+	// Total metrics of every tenant copied so far
+	Total int
+}
+
+// Summary count and checksum of metrics of tenant
+type Summary struct {
+	Count    int
+	Checksum string
+}
+
+// Check summaries of tenant in source and target
+type Check struct {
+	Tenant string
+	Source Summary
+	Target Summary
+}
+
+// Matches report whether target holds the same metrics as source
+func (c Check) Matches() bool {
+	return c.Source == c.Target
+}
+
+// Report result of migration, target summaries are empty in dry run
+type Report struct {
+	Copied int
+	Checks []Check
+}
+
+// Migrate copy metrics of every source tenant to target and verify them afterwards.
+// Stored metrics are overwritten, so counters get source values instead of being added up.
+// Expired metrics are neither copied nor verified
+func Migrate(ctx context.Context, source Store, target Store, options Options) (Report, error) {
+	if options.BatchSize <= 0 {
+		options.BatchSize = DefaultBatchSize
+	}
+	tenants, err := source.Tenants(ctx)
+	if err != nil {
+		return Report{}, fmt.Errorf("list source tenants: %w", err)
+	}
+	if err = checkIDs(ctx, source, tenants, options); err != nil {
+		return Report{}, err
+	}
+	var report Report
+	for _, tenant := range tenants {
+		tenantCtx := models.WithTenant(ctx, tenant)
+		copied := 0
+		err = walk(tenantCtx, source, options.BatchSize, options.TTL, func(batch []models.Metric) error {
+			if !options.DryRun {
+				if err := target.BatchUpsert(tenantCtx, batch); err != nil {
+					return fmt.Errorf("write tenant %s: %w", tenant, err)
+				}
+			}
+			copied += len(batch)
+			report.Copied += len(batch)
+			if options.Progress != nil {
+				options.Progress(Progress{Tenant: tenant, Copied: copied, Total: report.Copied})
+			}
+			return nil
+		})
+		if err != nil {
+			return report, err
+		}
+	}
+	for _, tenant := range tenants {
+		check := Check{Tenant: tenant}
+		tenantCtx := models.WithTenant(ctx, tenant)
+		if check.Source, err = Summarize(tenantCtx, source, options.BatchSize, options.TTL); err != nil {
+			return report, err
+		}
+		if !options.DryRun {
+			if check.Target, err = Summarize(tenantCtx, target, options.BatchSize, options.TTL); err != nil {
+				return report, err
+			}
+		}
+		report.Checks = append(report.Checks, check)
+	}
+	if options.DryRun {
+		return report, nil
+	}
+	for _, check := range report.Checks {
+		if !check.Matches() {
+			return report, fmt.Errorf("%w: tenant %s has %d metrics %s in source and %d metrics %s in target",
+				ErrVerificationFailed, check.Tenant,
+				check.Source.Count, check.Source.Checksum, check.Target.Count, check.Target.Checksum)
+		}
+	}
+	return report, nil
+}
+
+// checkIDs fail when metric which would be copied has id longer than target stores
+func checkIDs(ctx context.Context, source Store, tenants []string, options Options) error {
+	if options.MaxIDLength <= 0 {
+		return nil
+	}
+	for _, tenant := range tenants {
+		tenantCtx := models.WithTenant(ctx, tenant)
+		count, first := 0, ""
+		err := walk(tenantCtx, source, options.BatchSize, options.TTL, func(batch []models.Metric) error {
+			for _, metric := range batch {
+				if len(metric.ID) > options.MaxIDLength {
+					if count == 0 {
+						first = metric.ID
+					}
+					count++
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("%w: tenant %s has %d metrics with id longer than %d, first is %s",
+				ErrIDTooLong, tenant, count, options.MaxIDLength, first)
+		}
+	}
+	return nil
+}
+
+// Summarize count metrics of tenant in context not expired by ttl and hash them in id order, update time
+// is not hashed because every repository stamps its own
+func Summarize(ctx context.Context, repository persistence.Repository, batchSize int, ttl time.Duration) (Summary, error) {
+	hash := sha256.New()
+	count := 0
+	err := walk(ctx, repository, batchSize, ttl, func(batch []models.Metric) error {
+		for _, metric := range batch {
+			writeMetric(hash, metric)
+		}
+		count += len(batch)
+		return nil
+	})
+	if err != nil {
+		return Summary{}, err
+	}
+	return Summary{Count: count, Checksum: hex.EncodeToString(hash.Sum(nil))}, nil
+}
+
+// walk pass metrics of tenant in context not expired by ttl to fn page by page in id order
+func walk(ctx context.Context, repository persistence.Repository, batchSize int, ttl time.Duration, fn func(batch []models.Metric) error) error {
+	filter := persistence.ListFilter{Sort: persistence.SortByID, Limit: batchSize}
+	for {
+		metrics, err := repository.List(ctx, filter)
+		if err != nil {
+			return fmt.Errorf("read tenant %s: %w", models.TenantFromContext(ctx), err)
+		}
+		if batch := fresh(metrics, ttl); len(batch) > 0 {
+			if err = fn(batch); err != nil {
+				return err
+			}
+		}
+		if len(metrics) < batchSize {
+			return nil
+		}
+		last := persistence.Key(&metrics[len(metrics)-1])
+		filter.After = &last
+	}
+}
+
+// fresh metrics updated within ttl, metrics without update time never expire like in MetricService
+func fresh(metrics []models.Metric, ttl time.Duration) []models.Metric {
+	if ttl <= 0 {
+		return metrics
+	}
+	result := make([]models.Metric, 0, len(metrics))
+	for _, metric := range metrics {
+		if metric.LastUpdated.IsZero() || time.Since(metric.LastUpdated) <= ttl {
+			result = append(result, metric)
+		}
+	}
+	return result
+}
+
+func writeMetric(hash interface{ Write([]byte) (int, error) }, metric models.Metric) {
+	_, _ = hash.Write([]byte(metric.ID))
+	_, _ = hash.Write([]byte{0})
+	_, _ = hash.Write([]byte(metric.Type))
+	_, _ = hash.Write([]byte{0})
+	var value [8]byte
+	switch {
+	case metric.Value != nil:
+		binary.BigEndian.PutUint64(value[:], math.Float64bits(*metric.Value))
+	case metric.Delta != nil:
+		binary.BigEndian.PutUint64(value[:], uint64(*metric.Delta))
+	}
+	_, _ = hash.Write(value[:])
+}
@@ -0,0 +1,153 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DimKa163/go-metrics/internal/files"
+	"github.com/DimKa163/go-metrics/internal/models"
+	"github.com/DimKa163/go-metrics/internal/persistence/mem"
+)
+
+func newStore(t *testing.T) *mem.MemoryStore {
+	store, err := mem.NewStore(files.NewFiler(filepath.Join(t.TempDir(), "dump"), []int{1}), mem.StoreOption{})
+	require.NoError(t, err)
+	return store
+}
+
+func seed(t *testing.T, store *mem.MemoryStore) {
+	ctx := context.Background()
+	var metrics []models.Metric
+	for i := 0; i < 7; i++ {
+		value := float64(i) / 2
+		metrics = append(metrics, models.Metric{ID: fmt.Sprintf("gauge%d", i), Type: models.GaugeType, Value: &value})
+	}
+	require.NoError(t, store.BatchUpsert(ctx, metrics))
+	delta := int64(42)
+	require.NoError(t, store.Upsert(models.WithTenant(ctx, "team"), &models.Metric{ID: "requests", Type: models.CounterType, Delta: &delta}))
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	source := newStore(t)
+	seed(t, source)
+	target := newStore(t)
+	// counter already stored in target is overwritten, not added to
+	stale := int64(1)
+	require.NoError(t, target.Upsert(models.WithTenant(ctx, "team"), &models.Metric{ID: "requests", Type: models.CounterType, Delta: &stale}))
+
+	var progress []Progress
+	report, err := Migrate(ctx, source, target, Options{BatchSize: 3, Progress: func(p Progress) {
+		progress = append(progress, p)
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, 8, report.Copied)
+	assert.Equal(t, []Progress{
+		{Tenant: models.DefaultTenant, Copied: 3, Total: 3},
+		{Tenant: models.DefaultTenant, Copied: 6, Total: 6},
+		{Tenant: models.DefaultTenant, Copied: 7, Total: 7},
+		{Tenant: "team", Copied: 1, Total: 8},
+	}, progress)
+	require.Len(t, report.Checks, 2)
+	for _, check := range report.Checks {
+		assert.True(t, check.Matches(), check.Tenant)
+	}
+	assert.Equal(t, 7, report.Checks[0].Source.Count)
+
+	metric, err := target.Find(models.WithTenant(ctx, "team"), "requests")
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), *metric.Delta)
+}
+
+func TestMigrateDryRun(t *testing.T) {
+	ctx := context.Background()
+	source := newStore(t)
+	seed(t, source)
+	target := newStore(t)
+
+	report, err := Migrate(ctx, source, target, Options{DryRun: true})
+	require.NoError(t, err)
+	assert.Equal(t, 8, report.Copied)
+	require.Len(t, report.Checks, 2)
+	assert.Equal(t, Summary{}, report.Checks[0].Target)
+
+	tenants, err := target.Tenants(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, tenants)
+}
+
+func TestMigrateVerificationFailed(t *testing.T) {
+	ctx := context.Background()
+	source := newStore(t)
+	seed(t, source)
+	target := newStore(t)
+	// metric missing in source makes target count differ
+	value := 1.0
+	require.NoError(t, target.Upsert(ctx, &models.Metric{ID: "extra", Type: models.GaugeType, Value: &value}))
+
+	report, err := Migrate(ctx, source, target, Options{})
+	assert.ErrorIs(t, err, ErrVerificationFailed)
+	require.Len(t, report.Checks, 2)
+	assert.False(t, report.Checks[0].Matches())
+	assert.Equal(t, 8, report.Checks[0].Target.Count)
+}
+
+func TestMigrateSkipsExpired(t *testing.T) {
+	ctx := context.Background()
+	source := newStore(t)
+	seed(t, source)
+	time.Sleep(100 * time.Millisecond)
+	value := 1.0
+	require.NoError(t, source.Upsert(ctx, &models.Metric{ID: "fresh", Type: models.GaugeType, Value: &value}))
+	target := newStore(t)
+
+	report, err := Migrate(ctx, source, target, Options{TTL: 50 * time.Millisecond})
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Copied)
+	require.Len(t, report.Checks, 2)
+	assert.Equal(t, 1, report.Checks[0].Source.Count)
+	assert.Equal(t, 0, report.Checks[1].Source.Count)
+
+	_, err = target.Find(models.WithTenant(ctx, "team"), "requests")
+	assert.Error(t, err, "expired metric is not copied")
+}
+
+func TestMigrateRejectsLongIDsBeforeWriting(t *testing.T) {
+	ctx := context.Background()
+	source := newStore(t)
+	seed(t, source)
+	value := 1.0
+	long := strings.Repeat("a", 30)
+	require.NoError(t, source.Upsert(models.WithTenant(ctx, "team"), &models.Metric{ID: long, Type: models.GaugeType, Value: &value}))
+	target := newStore(t)
+
+	_, err := Migrate(ctx, source, target, Options{MaxIDLength: 25})
+	require.ErrorIs(t, err, ErrIDTooLong)
+	assert.ErrorContains(t, err, long)
+	tenants, err := target.Tenants(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, tenants, "nothing is copied when some id doesn't fit target")
+}
+
+func TestSummarizeChecksumsValues(t *testing.T) {
+	ctx := context.Background()
+	first := newStore(t)
+	second := newStore(t)
+	one, two := 1.0, 2.0
+	require.NoError(t, first.Upsert(ctx, &models.Metric{ID: "g", Type: models.GaugeType, Value: &one}))
+	require.NoError(t, second.Upsert(ctx, &models.Metric{ID: "g", Type: models.GaugeType, Value: &two}))
+
+	left, err := Summarize(ctx, first, DefaultBatchSize, 0)
+	require.NoError(t, err)
+	right, err := Summarize(ctx, second, DefaultBatchSize, 0)
+	require.NoError(t, err)
+	assert.Equal(t, left.Count, right.Count)
+	assert.NotEqual(t, left.Checksum, right.Checksum)
+}
@@ -7,10 +7,12 @@ package mocks
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 
 	models "github.com/DimKa163/go-metrics/internal/models"
+	persistence "github.com/DimKa163/go-metrics/internal/persistence"
 )
 
 // MockRepository is a mock of Repository interface.
@@ -50,6 +52,36 @@ func (mr *MockRepositoryMockRecorder) BatchUpsert(ctx, metrics interface{}) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BatchUpsert", reflect.TypeOf((*MockRepository)(nil).BatchUpsert), ctx, metrics)
 }
 
+// Delete mocks base method.
+func (m *MockRepository) Delete(ctx context.Context, ids []string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", ctx, ids)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockRepositoryMockRecorder) Delete(ctx, ids interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockRepository)(nil).Delete), ctx, ids)
+}
+
+// Expire mocks base method.
+func (m *MockRepository) Expire(ctx context.Context, before time.Time) (map[string][]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Expire", ctx, before)
+	ret0, _ := ret[0].(map[string][]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Expire indicates an expected call of Expire.
+func (mr *MockRepositoryMockRecorder) Expire(ctx, before any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Expire", reflect.TypeOf((*MockRepository)(nil).Expire), ctx, before)
+}
+
 // Find mocks base method.
 func (m *MockRepository) Find(ctx context.Context, key string) (*models.Metric, error) {
 	m.ctrl.T.Helper()
@@ -80,6 +112,50 @@ func (mr *MockRepositoryMockRecorder) GetAll(ctx interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAll", reflect.TypeOf((*MockRepository)(nil).GetAll), ctx)
 }
 
+// List mocks base method.
+func (m *MockRepository) List(ctx context.Context, filter persistence.ListFilter) ([]models.Metric, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", ctx, filter)
+	ret0, _ := ret[0].([]models.Metric)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockRepositoryMockRecorder) List(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockRepository)(nil).List), ctx, filter)
+}
+
+// Replace mocks base method.
+func (m *MockRepository) Replace(ctx context.Context, remove []string, metrics []models.Metric) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Replace", ctx, remove, metrics)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Replace indicates an expected call of Replace.
+func (mr *MockRepositoryMockRecorder) Replace(ctx, remove, metrics interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Replace", reflect.TypeOf((*MockRepository)(nil).Replace), ctx, remove, metrics)
+}
+
+// Reset mocks base method.
+func (m *MockRepository) Reset(ctx context.Context, ids []string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Reset", ctx, ids)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Reset indicates an expected call of Reset.
+func (mr *MockRepositoryMockRecorder) Reset(ctx, ids interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reset", reflect.TypeOf((*MockRepository)(nil).Reset), ctx, ids)
+}
+
 // Upsert mocks base method.
 func (m *MockRepository) Upsert(ctx context.Context, metric *models.Metric) error {
 	m.ctrl.T.Helper()
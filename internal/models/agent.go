@@ -0,0 +1,29 @@
+package models
+
+import "context"
+
+type agentKey struct{}
+
+// WithAgent attach identity of client sending request to context
+func WithAgent(ctx context.Context, agent string) context.Context {
+	return context.WithValue(ctx, agentKey{}, agent)
+}
+
+// AgentFromContext identity of client attached to context, empty when there is none
+func AgentFromContext(ctx context.Context) string {
+	agent, _ := ctx.Value(agentKey{}).(string)
+	return agent
+}
+
+type addressKey struct{}
+
+// WithAddress attach network address of client to context
+func WithAddress(ctx context.Context, address string) context.Context {
+	return context.WithValue(ctx, addressKey{}, address)
+}
+
+// AddressFromContext network address of client attached to context, empty when there is none
+func AddressFromContext(ctx context.Context) string {
+	address, _ := ctx.Value(addressKey{}).(string)
+	return address
+}
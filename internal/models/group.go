@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// GroupKey identify metrics pushed by short-lived job
+type GroupKey struct {
+	Job      string
+	Instance string
+}
+
+// Group metrics pushed under job/instance key
+type Group struct {
+	GroupKey
+	Metrics   []Metric
+	UpdatedAt time.Time
+}
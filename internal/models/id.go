@@ -0,0 +1,66 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MaxIDLength longest metric id keeper stores whatever its repository is, longer ids are rejected
+const MaxIDLength = 255
+
+// ComposeID build metric id from name and labels in name{k="v",...} form with sorted keys
+func ComposeID(name string, labels map[string]string) string {
+	if len(labels) == 0 {
+		return name
+	}
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString(name)
+	b.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=%q", k, labels[k])
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// ParseID split id composed by ComposeID into name and labels,
+// id which is not in name{k="v",...} form is plain name
+func ParseID(id string) (string, map[string]string) {
+	open := strings.IndexByte(id, '{')
+	if open <= 0 || !strings.HasSuffix(id, "}") {
+		return id, nil
+	}
+	labels := make(map[string]string)
+	rest := id[open+1 : len(id)-1]
+	for rest != "" {
+		key, value, ok := strings.Cut(rest, "=")
+		if !ok || key == "" {
+			return id, nil
+		}
+		quoted, err := strconv.QuotedPrefix(value)
+		if err != nil {
+			return id, nil
+		}
+		if labels[key], err = strconv.Unquote(quoted); err != nil {
+			return id, nil
+		}
+		rest = value[len(quoted):]
+		if rest != "" {
+			if rest[0] != ',' {
+				return id, nil
+			}
+			rest = rest[1:]
+		}
+	}
+	return id[:open], labels
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseID(t *testing.T) {
+	labels := map[string]string{"host": "a,b", "path": `/"x"`}
+	name, parsed := ParseID(ComposeID("requests", labels))
+	assert.Equal(t, "requests", name)
+	assert.Equal(t, labels, parsed)
+
+	for _, id := range []string{"HeapAlloc", "{a=\"b\"}", "x{a=b}", "x{a=\"b\"", "x{a=\"b\"c=\"d\"}"} {
+		name, parsed = ParseID(id)
+		assert.Equal(t, id, name)
+		assert.Nil(t, parsed)
+	}
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// IdempotencyKey key of request being applied and time key expires at once request is applied
+type IdempotencyKey struct {
+	Key     string
+	Expires time.Time
+}
+
+type idempotencyKey struct{}
+
+// WithIdempotencyKey attach key of request to context, repository writing metrics in transaction marks
+// key done in the same transaction. Empty key detaches key of outer request
+func WithIdempotencyKey(ctx context.Context, key IdempotencyKey) context.Context {
+	return context.WithValue(ctx, idempotencyKey{}, key)
+}
+
+// IdempotencyKeyFromContext key attached to context, ok is false when there is none
+func IdempotencyKeyFromContext(ctx context.Context) (IdempotencyKey, bool) {
+	key, _ := ctx.Value(idempotencyKey{}).(IdempotencyKey)
+	return key, key.Key != ""
+}
@@ -3,6 +3,7 @@ package models
 import (
 	"errors"
 	"strconv"
+	"time"
 )
 
 const (
@@ -21,6 +22,8 @@ type Metric struct {
 	Type  string   `json:"type"`
 	Delta *int64   `json:"delta,omitempty"`
 	Value *float64 `json:"value,omitempty"`
+	// LastUpdated time of last write, stamped by repository
+	LastUpdated time.Time `json:"last_updated,omitzero"`
 }
 
 func (m *Metric) Update(metric Metric) {
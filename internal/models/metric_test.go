@@ -93,3 +93,11 @@ func TestValidateMetric(t *testing.T) {
 		t.Errorf("expected error for invalid metric")
 	}
 }
+
+func TestComposeID(t *testing.T) {
+	assert.Equal(t, "requests", ComposeID("requests", nil))
+	assert.Equal(t, `requests{code="200",method="get"}`, ComposeID("requests", map[string]string{
+		"method": "get",
+		"code":   "200",
+	}))
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"regexp"
+)
+
+// DefaultTenant tenant of requests without token, its admins manage tokens of every tenant
+const DefaultTenant = "default"
+
+var (
+	ErrInvalidTenant = errors.New("tenant must be 1-64 letters, digits, '-' or '_'")
+
+	tenantPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+)
+
+type tenantKey struct{}
+
+// WithTenant attach tenant to context, repositories read and write metrics of that tenant only
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext tenant attached to context, DefaultTenant when there is none
+func TenantFromContext(ctx context.Context) string {
+	if tenant, ok := ctx.Value(tenantKey{}).(string); ok && tenant != "" {
+		return tenant
+	}
+	return DefaultTenant
+}
+
+func ValidateTenant(tenant string) error {
+	if !tenantPattern.MatchString(tenant) {
+		return ErrInvalidTenant
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+type Scope string
+
+const (
+	ScopeRead  Scope = "read"
+	ScopeWrite Scope = "write"
+	// ScopeAdmin manage tokens, implies read and write
+	ScopeAdmin Scope = "admin"
+)
+
+var ErrUnknownScope = errors.New("unknown scope")
+
+// Token API token of tenant, only hash of secret is stored
+type Token struct {
+	ID        string    `json:"id"`
+	Tenant    string    `json:"tenant"`
+	Scopes    []Scope   `json:"scopes"`
+	Hash      string    `json:"hash"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (t *Token) HasScope(scope Scope) bool {
+	for _, it := range t.Scopes {
+		if it == scope || it == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+func ParseScope(value string) (Scope, error) {
+	switch scope := Scope(value); scope {
+	case ScopeRead, ScopeWrite, ScopeAdmin:
+		return scope, nil
+	default:
+		return "", ErrUnknownScope
+	}
+}
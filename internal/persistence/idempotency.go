@@ -0,0 +1,35 @@
+package persistence
+
+import (
+	"context"
+	"time"
+)
+
+// KeyState state of idempotency key found by ClaimKey
+type KeyState int
+
+const (
+	// KeyClaimed key was free and is pending now
+	KeyClaimed KeyState = iota
+	// KeyPending request with key is being applied
+	KeyPending
+	// KeyDone request with key was applied
+	KeyDone
+)
+
+// IdempotencyRepository remember idempotency keys of requests per tenant. Key is pending while its request
+// is applied and done once request was applied, key in either state is kept until it expires
+type IdempotencyRepository interface {
+	// ClaimKey store key of tenant in context as pending until lease unless it is stored already and not expired,
+	// report state of stored key
+	ClaimKey(ctx context.Context, key string, lease time.Time) (KeyState, error)
+
+	// CompleteKey mark key of tenant in context done until expires
+	CompleteKey(ctx context.Context, key string, expires time.Time) error
+
+	// ReleaseKey forget pending key of tenant in context, done key is kept
+	ReleaseKey(ctx context.Context, key string) error
+
+	// PurgeKeys remove keys of every tenant expired before given time and return how many of them were removed
+	PurgeKeys(ctx context.Context, before time.Time) (int, error)
+}
@@ -0,0 +1,74 @@
+package persistence
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/DimKa163/go-metrics/internal/models"
+)
+
+// SortField order of listed metrics, ties are broken by id
+type SortField string
+
+const (
+	SortByID   SortField = "id"
+	SortByType SortField = "type"
+)
+
+// ListKey position of metric in listing, cursor of next page
+type ListKey struct {
+	Type string
+	ID   string
+}
+
+// ListFilter selection and page of metrics, zero fields select everything.
+// Strings are compared bytewise, so every repository returns the same order
+type ListFilter struct {
+	Type   string
+	Prefix string
+	// Pattern id must match, pg evaluates it with ~ operator
+	Pattern *regexp.Regexp
+	// Since metrics last updated before are skipped
+	Since time.Time
+	Sort  SortField
+	Desc  bool
+	// After metrics following this key in listing order are returned
+	After *ListKey
+	// Limit max metrics returned, 0 is unlimited
+	Limit int
+}
+
+// Matches report whether metric is selected by filter, page position is not checked
+func (f *ListFilter) Matches(metric *models.Metric) bool {
+	if f.Type != "" && metric.Type != f.Type {
+		return false
+	}
+	if !strings.HasPrefix(metric.ID, f.Prefix) {
+		return false
+	}
+	if metric.LastUpdated.Before(f.Since) {
+		return false
+	}
+	return f.Pattern == nil || f.Pattern.MatchString(metric.ID)
+}
+
+// Compare order of metric keys in listing, negative when a goes first
+func (f *ListFilter) Compare(a ListKey, b ListKey) int {
+	result := 0
+	if f.Sort == SortByType {
+		result = strings.Compare(a.Type, b.Type)
+	}
+	if result == 0 {
+		result = strings.Compare(a.ID, b.ID)
+	}
+	if f.Desc {
+		return -result
+	}
+	return result
+}
+
+// Key position of metric in listing
+func Key(metric *models.Metric) ListKey {
+	return ListKey{Type: metric.Type, ID: metric.ID}
+}
@@ -0,0 +1,110 @@
+package mem
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/DimKa163/go-metrics/internal/models"
+	"github.com/DimKa163/go-metrics/internal/persistence"
+)
+
+// DefaultIdempotencyCapacity keys kept by IdempotencyStore when capacity is not set
+const DefaultIdempotencyCapacity = 100000
+
+type idempotencyEntry struct {
+	id      string
+	expires time.Time
+	done    bool
+}
+
+// IdempotencyStore keep idempotency keys in memory, oldest keys are evicted when capacity is reached
+type IdempotencyStore struct {
+	capacity int
+
+	mutex sync.Mutex
+	order *list.List
+	keys  map[string]*list.Element
+	now   func() time.Time
+}
+
+// NewIdempotencyStore create store, default capacity is used for non-positive one
+func NewIdempotencyStore(capacity int) *IdempotencyStore {
+	if capacity <= 0 {
+		capacity = DefaultIdempotencyCapacity
+	}
+	return &IdempotencyStore{
+		capacity: capacity,
+		order:    list.New(),
+		keys:     make(map[string]*list.Element),
+		now:      time.Now,
+	}
+}
+
+func (s *IdempotencyStore) ClaimKey(ctx context.Context, key string, lease time.Time) (persistence.KeyState, error) {
+	id := models.TenantFromContext(ctx) + "\x00" + key
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if element, ok := s.keys[id]; ok {
+		if entry := element.Value.(idempotencyEntry); entry.expires.After(s.now()) {
+			if entry.done {
+				return persistence.KeyDone, nil
+			}
+			return persistence.KeyPending, nil
+		}
+		s.order.Remove(element)
+		delete(s.keys, id)
+	}
+	s.push(idempotencyEntry{id: id, expires: lease})
+	return persistence.KeyClaimed, nil
+}
+
+// CompleteKey mark key done, key evicted or released meanwhile is stored again
+func (s *IdempotencyStore) CompleteKey(ctx context.Context, key string, expires time.Time) error {
+	id := models.TenantFromContext(ctx) + "\x00" + key
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if element, ok := s.keys[id]; ok {
+		s.order.Remove(element)
+		delete(s.keys, id)
+	}
+	s.push(idempotencyEntry{id: id, expires: expires, done: true})
+	return nil
+}
+
+func (s *IdempotencyStore) ReleaseKey(ctx context.Context, key string) error {
+	id := models.TenantFromContext(ctx) + "\x00" + key
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if element, ok := s.keys[id]; ok && !element.Value.(idempotencyEntry).done {
+		s.order.Remove(element)
+		delete(s.keys, id)
+	}
+	return nil
+}
+
+func (s *IdempotencyStore) PurgeKeys(_ context.Context, before time.Time) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	removed := 0
+	for element := s.order.Front(); element != nil; {
+		next := element.Next()
+		if entry := element.Value.(idempotencyEntry); entry.expires.Before(before) {
+			s.order.Remove(element)
+			delete(s.keys, entry.id)
+			removed++
+		}
+		element = next
+	}
+	return removed, nil
+}
+
+// push store entry as the newest one evicting the oldest ones over capacity, caller must hold mutex
+func (s *IdempotencyStore) push(entry idempotencyEntry) {
+	for s.order.Len() >= s.capacity {
+		oldest := s.order.Remove(s.order.Front()).(idempotencyEntry)
+		delete(s.keys, oldest.id)
+	}
+	s.keys[entry.id] = s.order.PushBack(entry)
+}
@@ -0,0 +1,56 @@
+package mem
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DimKa163/go-metrics/internal/persistence"
+)
+
+func TestIdempotencyStoreExpiry(t *testing.T) {
+	ctx := context.Background()
+	store := NewIdempotencyStore(2)
+	now := time.Now()
+
+	state, _ := store.ClaimKey(ctx, "a", now.Add(-time.Second))
+	assert.Equal(t, persistence.KeyClaimed, state)
+	state, _ = store.ClaimKey(ctx, "a", now.Add(time.Minute))
+	assert.Equal(t, persistence.KeyClaimed, state, "expired key is claimed again")
+	state, _ = store.ClaimKey(ctx, "a", now.Add(time.Minute))
+	assert.Equal(t, persistence.KeyPending, state)
+
+	state, _ = store.ClaimKey(ctx, "b", now.Add(-time.Second))
+	assert.Equal(t, persistence.KeyClaimed, state)
+	removed, _ := store.PurgeKeys(ctx, now)
+	assert.Equal(t, 1, removed)
+
+	store.ClaimKey(ctx, "b", now.Add(time.Minute))
+	store.ClaimKey(ctx, "c", now.Add(time.Minute))
+	state, _ = store.ClaimKey(ctx, "a", now.Add(time.Minute))
+	assert.Equal(t, persistence.KeyClaimed, state, "oldest key is evicted when capacity is reached")
+}
+
+func TestIdempotencyStoreStates(t *testing.T) {
+	ctx := context.Background()
+	store := NewIdempotencyStore(10)
+	now := time.Now()
+
+	store.ClaimKey(ctx, "a", now.Add(-time.Second))
+	state, _ := store.ClaimKey(ctx, "a", now.Add(time.Minute))
+	assert.Equal(t, persistence.KeyClaimed, state, "pending key whose lease elapsed is claimed again")
+
+	assert.NoError(t, store.CompleteKey(ctx, "a", now.Add(time.Hour)))
+	state, _ = store.ClaimKey(ctx, "a", now.Add(time.Minute))
+	assert.Equal(t, persistence.KeyDone, state)
+	assert.NoError(t, store.ReleaseKey(ctx, "a"))
+	state, _ = store.ClaimKey(ctx, "a", now.Add(time.Minute))
+	assert.Equal(t, persistence.KeyDone, state, "done key is not released")
+
+	store.ClaimKey(ctx, "b", now.Add(time.Minute))
+	assert.NoError(t, store.ReleaseKey(ctx, "b"))
+	state, _ = store.ClaimKey(ctx, "b", now.Add(time.Minute))
+	assert.Equal(t, persistence.KeyClaimed, state, "pending key is released")
+}
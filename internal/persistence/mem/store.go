@@ -4,7 +4,9 @@ import (
 	"context"
 	"errors"
 	"io"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/DimKa163/go-metrics/internal/files"
 	"github.com/DimKa163/go-metrics/internal/models"
@@ -16,82 +18,235 @@ type StoreOption struct {
 	UseSYNC bool
 }
 
+// MemoryStore keep metrics in map per tenant
 type MemoryStore struct {
-	metrics map[string]*models.Metric
+	tenants map[string]map[string]*models.Metric
 	filer   *files.Filer
 	mutex   *sync.RWMutex
 	option  StoreOption
 }
 
 func NewStore(filer *files.Filer, options StoreOption) (*MemoryStore, error) {
-	data := make(map[string]*models.Metric)
+	data := make(map[string]map[string]*models.Metric)
+	restored := time.Now()
 	if options.Restore {
-		metrics, err := filer.Restore()
+		tenants, err := filer.RestoreTenants()
 		if err != nil {
 			if !errors.Is(err, io.EOF) {
 				return nil, err
 			}
 		}
-		for _, metric := range metrics {
-			data[metric.ID] = &metric
+		for tenant, metrics := range tenants {
+			data[tenant] = make(map[string]*models.Metric, len(metrics))
+			for _, metric := range metrics {
+				// dumps written before update time was tracked start aging from restore
+				if metric.LastUpdated.IsZero() {
+					metric.LastUpdated = restored
+				}
+				data[tenant][metric.ID] = &metric
+			}
 		}
 	}
 	return &MemoryStore{
-		metrics: data,
+		tenants: data,
 		option:  options,
 		filer:   filer,
 		mutex:   &sync.RWMutex{},
 	}, nil
 }
 
-func (s *MemoryStore) Find(_ context.Context, key string) (*models.Metric, error) {
+func (s *MemoryStore) Find(ctx context.Context, key string) (*models.Metric, error) {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
-	if val, ok := s.metrics[key]; ok {
+	if val, ok := s.tenants[models.TenantFromContext(ctx)][key]; ok {
 		return val, nil
 	}
 	return nil, persistence.ErrMetricNotFound
 }
 
-func (s *MemoryStore) GetAll(_ context.Context) ([]models.Metric, error) {
+func (s *MemoryStore) GetAll(ctx context.Context) ([]models.Metric, error) {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 	var result []models.Metric
-	for _, metric := range s.metrics {
+	for _, metric := range s.tenants[models.TenantFromContext(ctx)] {
 		result = append(result, *metric)
 	}
 	return result, nil
 
 }
 
-func (s *MemoryStore) Upsert(_ context.Context, metric *models.Metric) error {
+func (s *MemoryStore) List(ctx context.Context, filter persistence.ListFilter) ([]models.Metric, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	var result []models.Metric
+	for _, metric := range s.tenants[models.TenantFromContext(ctx)] {
+		if !filter.Matches(metric) {
+			continue
+		}
+		if filter.After != nil && filter.Compare(persistence.Key(metric), *filter.After) <= 0 {
+			continue
+		}
+		result = append(result, *metric)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return filter.Compare(persistence.Key(&result[i]), persistence.Key(&result[j])) < 0
+	})
+	if filter.Limit > 0 && len(result) > filter.Limit {
+		result = result[:filter.Limit]
+	}
+	return result, nil
+}
+
+func (s *MemoryStore) Upsert(ctx context.Context, metric *models.Metric) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
-	delete(s.metrics, metric.ID)
-	s.metrics[metric.ID] = metric
+	metrics := s.tenant(ctx)
+	delete(metrics, metric.ID)
+	metric.LastUpdated = time.Now()
+	metrics[metric.ID] = metric
 	if s.option.UseSYNC {
-		var result []models.Metric
-		for _, met := range s.metrics {
-			result = append(result, *met)
-		}
-		return s.filer.Dump(result)
+		return s.filer.DumpTenants(s.snapshot())
 	}
 	return nil
 }
 
-func (s *MemoryStore) BatchUpsert(_ context.Context, metrics []models.Metric) error {
+func (s *MemoryStore) BatchUpsert(ctx context.Context, metrics []models.Metric) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
+	stored := s.tenant(ctx)
+	now := time.Now()
 	for _, metric := range metrics {
-		delete(s.metrics, metric.ID)
-		s.metrics[metric.ID] = &metric
+		delete(stored, metric.ID)
+		metric.LastUpdated = now
+		stored[metric.ID] = &metric
 	}
 	if s.option.UseSYNC {
-		var result []models.Metric
-		for _, met := range s.metrics {
-			result = append(result, *met)
-		}
-		return s.filer.Dump(result)
+		return s.filer.DumpTenants(s.snapshot())
 	}
 	return nil
 }
+
+// Replace remove metrics and store others, removal is dumped at once like deletion
+func (s *MemoryStore) Replace(ctx context.Context, remove []string, metrics []models.Metric) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	stored := s.tenant(ctx)
+	for _, id := range remove {
+		delete(stored, id)
+	}
+	now := time.Now()
+	for _, metric := range metrics {
+		metric.LastUpdated = now
+		stored[metric.ID] = &metric
+	}
+	if len(remove) > 0 || s.option.UseSYNC {
+		return s.filer.DumpTenants(s.snapshot())
+	}
+	return nil
+}
+
+// Delete remove metrics, removal is dumped at once even without sync mode, so restore can't bring them back
+func (s *MemoryStore) Delete(ctx context.Context, ids []string) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	stored := s.tenants[models.TenantFromContext(ctx)]
+	deleted := 0
+	for _, id := range ids {
+		if _, ok := stored[id]; ok {
+			delete(stored, id)
+			deleted++
+		}
+	}
+	if deleted == 0 {
+		return 0, nil
+	}
+	return deleted, s.filer.DumpTenants(s.snapshot())
+}
+
+// Reset zero metrics and stamp them as updated, reset is dumped at once like deletion
+func (s *MemoryStore) Reset(ctx context.Context, ids []string) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	stored := s.tenants[models.TenantFromContext(ctx)]
+	reset := 0
+	for _, id := range ids {
+		metric, ok := stored[id]
+		if !ok {
+			continue
+		}
+		zero := models.CreateGauge(id, 0)
+		if metric.Type == models.CounterType {
+			zero = models.CreateCounter(id, 0)
+		}
+		zero.LastUpdated = time.Now()
+		stored[id] = zero
+		reset++
+	}
+	if reset == 0 {
+		return 0, nil
+	}
+	return reset, s.filer.DumpTenants(s.snapshot())
+}
+
+// Expire remove metrics last updated before given time, removal is dumped at once like deletion
+func (s *MemoryStore) Expire(_ context.Context, before time.Time) (map[string][]string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	removed := make(map[string][]string)
+	for tenant, metrics := range s.tenants {
+		for id, metric := range metrics {
+			if metric.LastUpdated.Before(before) {
+				delete(metrics, id)
+				removed[tenant] = append(removed[tenant], id)
+			}
+		}
+	}
+	if len(removed) == 0 {
+		return removed, nil
+	}
+	return removed, s.filer.DumpTenants(s.snapshot())
+}
+
+// Tenants tenants having metrics in bytewise order
+func (s *MemoryStore) Tenants(_ context.Context) ([]string, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	tenants := make([]string, 0, len(s.tenants))
+	for tenant, metrics := range s.tenants {
+		if len(metrics) > 0 {
+			tenants = append(tenants, tenant)
+		}
+	}
+	sort.Strings(tenants)
+	return tenants, nil
+}
+
+// Snapshot metrics of every tenant
+func (s *MemoryStore) Snapshot(_ context.Context) (map[string][]models.Metric, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.snapshot(), nil
+}
+
+func (s *MemoryStore) snapshot() map[string][]models.Metric {
+	result := make(map[string][]models.Metric, len(s.tenants))
+	for tenant, metrics := range s.tenants {
+		list := make([]models.Metric, 0, len(metrics))
+		for _, metric := range metrics {
+			list = append(list, *metric)
+		}
+		result[tenant] = list
+	}
+	return result
+}
+
+// tenant metrics of context tenant, created on first write
+func (s *MemoryStore) tenant(ctx context.Context) map[string]*models.Metric {
+	tenant := models.TenantFromContext(ctx)
+	metrics, ok := s.tenants[tenant]
+	if !ok {
+		metrics = make(map[string]*models.Metric)
+		s.tenants[tenant] = metrics
+	}
+	return metrics
+}
@@ -0,0 +1,160 @@
+package mem
+
+import (
+	"context"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DimKa163/go-metrics/internal/files"
+	"github.com/DimKa163/go-metrics/internal/models"
+	"github.com/DimKa163/go-metrics/internal/persistence"
+)
+
+func TestStoreTenantsSurviveRestore(t *testing.T) {
+	filer := files.NewFiler(filepath.Join(t.TempDir(), "dump"), []int{1})
+	store, err := NewStore(filer, StoreOption{UseSYNC: true})
+	require.NoError(t, err)
+	alpha := models.WithTenant(context.Background(), "alpha")
+	beta := models.WithTenant(context.Background(), "beta")
+	require.NoError(t, store.Upsert(alpha, models.CreateGauge("HeapAlloc", 1)))
+	require.NoError(t, store.BatchUpsert(beta, []models.Metric{*models.CreateGauge("HeapAlloc", 2)}))
+	require.NoError(t, store.Upsert(context.Background(), models.CreateCounter("PollCount", 3)))
+
+	restored, err := NewStore(filer, StoreOption{Restore: true})
+	require.NoError(t, err)
+	metric, err := restored.Find(alpha, "HeapAlloc")
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, *metric.Value)
+	metric, err = restored.Find(beta, "HeapAlloc")
+	require.NoError(t, err)
+	assert.Equal(t, 2.0, *metric.Value)
+	_, err = restored.Find(context.Background(), "HeapAlloc")
+	assert.ErrorIs(t, err, persistence.ErrMetricNotFound)
+
+	all, err := restored.GetAll(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []models.Metric{*models.CreateCounter("PollCount", 3)}, withoutUpdateTime(all))
+}
+
+func TestTokenStorePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	store, err := NewTokenStore(path)
+	require.NoError(t, err)
+	ctx := context.Background()
+	require.NoError(t, store.CreateToken(ctx, &models.Token{ID: "a", Tenant: "alpha", Hash: "h1", Scopes: []models.Scope{models.ScopeRead}}))
+	require.NoError(t, store.CreateToken(ctx, &models.Token{ID: "b", Tenant: "beta", Hash: "h2", Scopes: []models.Scope{models.ScopeWrite}}))
+	assert.ErrorIs(t, store.DeleteToken(ctx, "alpha", "b"), persistence.ErrTokenNotFound)
+	require.NoError(t, store.DeleteToken(ctx, "", "b"))
+
+	reopened, err := NewTokenStore(path)
+	require.NoError(t, err)
+	token, err := reopened.FindToken(ctx, "h1")
+	require.NoError(t, err)
+	assert.Equal(t, "alpha", token.Tenant)
+	_, err = reopened.FindToken(ctx, "h2")
+	assert.ErrorIs(t, err, persistence.ErrTokenNotFound)
+}
+
+func TestStoreList(t *testing.T) {
+	store, err := NewStore(files.NewFiler(filepath.Join(t.TempDir(), "dump"), []int{1}), StoreOption{})
+	require.NoError(t, err)
+	ctx := context.Background()
+	require.NoError(t, store.BatchUpsert(ctx, []models.Metric{
+		*models.CreateGauge("HeapAlloc", 1),
+		*models.CreateGauge("HeapIdle", 2),
+		*models.CreateCounter("PollCount", 3),
+		*models.CreateGauge("heapLower", 4),
+		*models.CreateCounter("Hits", 5),
+	}))
+	ids := func(filter persistence.ListFilter) []string {
+		metrics, err := store.List(ctx, filter)
+		require.NoError(t, err)
+		result := make([]string, len(metrics))
+		for i, metric := range metrics {
+			result[i] = metric.ID
+		}
+		return result
+	}
+	assert.Equal(t, []string{"HeapAlloc", "HeapIdle", "Hits", "PollCount", "heapLower"}, ids(persistence.ListFilter{}), "bytewise order")
+	assert.Equal(t, []string{"HeapAlloc", "HeapIdle"}, ids(persistence.ListFilter{Prefix: "Heap"}))
+	assert.Equal(t, []string{"Hits", "PollCount"}, ids(persistence.ListFilter{Type: models.CounterType}))
+	assert.Equal(t, []string{"HeapIdle", "heapLower"}, ids(persistence.ListFilter{Pattern: regexp.MustCompile(`(?i)^heap[il]`)}))
+	assert.Equal(t, []string{"Hits", "PollCount", "HeapAlloc"}, ids(persistence.ListFilter{Sort: persistence.SortByType, Limit: 3}))
+	assert.Equal(t, []string{"HeapIdle", "HeapAlloc"}, ids(persistence.ListFilter{
+		Desc:  true,
+		After: &persistence.ListKey{Type: models.GaugeType, ID: "Hits"},
+	}))
+}
+
+func TestStoreDeleteAndResetSurviveRestore(t *testing.T) {
+	filer := files.NewFiler(filepath.Join(t.TempDir(), "dump"), []int{1})
+	store, err := NewStore(filer, StoreOption{})
+	require.NoError(t, err)
+	ctx := context.Background()
+	require.NoError(t, store.BatchUpsert(ctx, []models.Metric{
+		*models.CreateGauge("Alloc", 1),
+		*models.CreateGauge("Stale", 2),
+		*models.CreateCounter("PollCount", 3),
+	}))
+
+	deleted, err := store.Delete(ctx, []string{"Stale", "Missing"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+	reset, err := store.Reset(ctx, []string{"PollCount", "Alloc"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, reset)
+	deleted, err = store.Delete(ctx, []string{"Missing"})
+	require.NoError(t, err)
+	assert.Zero(t, deleted)
+
+	restored, err := NewStore(filer, StoreOption{Restore: true})
+	require.NoError(t, err)
+	all, err := restored.GetAll(ctx)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []models.Metric{
+		*models.CreateGauge("Alloc", 0),
+		*models.CreateCounter("PollCount", 0),
+	}, withoutUpdateTime(all))
+}
+
+func TestStoreExpire(t *testing.T) {
+	store, err := NewStore(files.NewFiler(filepath.Join(t.TempDir(), "dump"), []int{1}), StoreOption{})
+	require.NoError(t, err)
+	alpha := models.WithTenant(context.Background(), "alpha")
+	require.NoError(t, store.Upsert(alpha, models.CreateGauge("Stale", 1)))
+	require.NoError(t, store.Upsert(alpha, models.CreateGauge("Reset", 1)))
+	require.NoError(t, store.Upsert(context.Background(), models.CreateCounter("Stale", 1)))
+	before := time.Now()
+	require.NoError(t, store.BatchUpsert(alpha, []models.Metric{*models.CreateGauge("Fresh", 2)}))
+
+	metric, err := store.Find(alpha, "Fresh")
+	require.NoError(t, err)
+	assert.False(t, metric.LastUpdated.Before(before))
+	reset, err := store.Reset(alpha, []string{"Reset"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, reset)
+
+	listed, err := store.List(alpha, persistence.ListFilter{Since: before})
+	require.NoError(t, err)
+	assert.Equal(t, []models.Metric{*models.CreateGauge("Fresh", 2), *models.CreateGauge("Reset", 0)}, withoutUpdateTime(listed),
+		"reset metric counts as updated")
+
+	removed, err := store.Expire(context.Background(), before)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{"alpha": {"Stale"}, models.DefaultTenant: {"Stale"}}, removed)
+	all, err := store.List(alpha, persistence.ListFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, []models.Metric{*models.CreateGauge("Fresh", 2), *models.CreateGauge("Reset", 0)}, withoutUpdateTime(all))
+}
+
+func withoutUpdateTime(metrics []models.Metric) []models.Metric {
+	for i := range metrics {
+		metrics[i].LastUpdated = time.Time{}
+	}
+	return metrics
+}
@@ -0,0 +1,121 @@
+package mem
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/DimKa163/go-metrics/internal/models"
+	"github.com/DimKa163/go-metrics/internal/persistence"
+)
+
+// TokenStore keep tokens in memory, every change is written to file when path is set
+type TokenStore struct {
+	path   string
+	mutex  sync.RWMutex
+	tokens map[string]models.Token
+}
+
+func NewTokenStore(path string) (*TokenStore, error) {
+	store := &TokenStore{path: path, tokens: make(map[string]models.Token)}
+	if path == "" {
+		return store, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return store, nil
+		}
+		return nil, err
+	}
+	var tokens []models.Token
+	if err = json.Unmarshal(data, &tokens); err != nil {
+		return nil, err
+	}
+	for _, token := range tokens {
+		store.tokens[token.ID] = token
+	}
+	return store, nil
+}
+
+func (s *TokenStore) FindToken(_ context.Context, hash string) (*models.Token, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	for _, token := range s.tokens {
+		if token.Hash == hash {
+			return &token, nil
+		}
+	}
+	return nil, persistence.ErrTokenNotFound
+}
+
+func (s *TokenStore) ListTokens(_ context.Context, tenant string) ([]models.Token, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.list(tenant), nil
+}
+
+func (s *TokenStore) CreateToken(_ context.Context, token *models.Token) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.tokens[token.ID] = *token
+	if err := s.save(); err != nil {
+		delete(s.tokens, token.ID)
+		return err
+	}
+	return nil
+}
+
+func (s *TokenStore) DeleteToken(_ context.Context, tenant string, id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	token, ok := s.tokens[id]
+	if !ok || tenant != "" && token.Tenant != tenant {
+		return persistence.ErrTokenNotFound
+	}
+	delete(s.tokens, id)
+	if err := s.save(); err != nil {
+		s.tokens[id] = token
+		return err
+	}
+	return nil
+}
+
+func (s *TokenStore) list(tenant string) []models.Token {
+	result := make([]models.Token, 0, len(s.tokens))
+	for _, token := range s.tokens {
+		if tenant == "" || token.Tenant == tenant {
+			result = append(result, token)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].CreatedAt.Before(result[j].CreatedAt) })
+	return result
+}
+
+// save write tokens to temporary file and rename it so file is never left half written
+func (s *TokenStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(s.list(""))
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
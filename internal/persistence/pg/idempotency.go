@@ -0,0 +1,75 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/DimKa163/go-metrics/internal/models"
+	"github.com/DimKa163/go-metrics/internal/persistence"
+)
+
+const completeKeySQL = "UPDATE idempotency_keys SET done = true, expires_at = $3 WHERE tenant = $1 AND key = $2;"
+
+// ClaimKey insert pending key or take over expired one, state of live key is read when nothing is affected
+func (s *Store) ClaimKey(ctx context.Context, key string, lease time.Time) (persistence.KeyState, error) {
+	claimSQL := `INSERT INTO idempotency_keys (tenant, key, expires_at, done) VALUES ($1, $2, $3, false)
+ON CONFLICT (tenant, key) DO UPDATE SET expires_at = EXCLUDED.expires_at, done = false
+WHERE idempotency_keys.expires_at <= now();`
+	stateSQL := "SELECT done FROM idempotency_keys WHERE tenant = $1 AND key = $2;"
+	tenant := models.TenantFromContext(ctx)
+	var state persistence.KeyState
+	err := s.execWithRetry(ctx, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, claimSQL, tenant, key, lease)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() > 0 {
+			state = persistence.KeyClaimed
+			return nil
+		}
+		var done bool
+		if err = tx.QueryRow(ctx, stateSQL, tenant, key).Scan(&done); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				// key was purged right after conflict, its request is treated as still in flight
+				state = persistence.KeyPending
+				return nil
+			}
+			return err
+		}
+		state = persistence.KeyPending
+		if done {
+			state = persistence.KeyDone
+		}
+		return nil
+	})
+	return state, err
+}
+
+func (s *Store) CompleteKey(ctx context.Context, key string, expires time.Time) error {
+	_, err := s.execAffected(ctx, completeKeySQL, models.TenantFromContext(ctx), key, expires)
+	return err
+}
+
+func (s *Store) ReleaseKey(ctx context.Context, key string) error {
+	query := "DELETE FROM idempotency_keys WHERE tenant = $1 AND key = $2 AND NOT done;"
+	_, err := s.execAffected(ctx, query, models.TenantFromContext(ctx), key)
+	return err
+}
+
+func (s *Store) PurgeKeys(ctx context.Context, before time.Time) (int, error) {
+	return s.execAffected(ctx, "DELETE FROM idempotency_keys WHERE expires_at < $1;", before)
+}
+
+// completeKey mark idempotency key of request in context done within transaction writing metrics,
+// so key can't be left pending once write is committed
+func completeKey(ctx context.Context, tx pgx.Tx) error {
+	key, ok := models.IdempotencyKeyFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	_, err := tx.Exec(ctx, completeKeySQL, models.TenantFromContext(ctx), key.Key, key.Expires)
+	return err
+}
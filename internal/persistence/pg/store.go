@@ -4,7 +4,10 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/cenkalti/backoff/v5"
 	"github.com/golang-migrate/migrate/v4"
@@ -19,6 +22,9 @@ import (
 	"github.com/DimKa163/go-metrics/internal/persistence"
 )
 
+// MaxIDLength longest metric id fitting metrics table
+const MaxIDLength = models.MaxIDLength
+
 type Store struct {
 	*pgxpool.Pool
 	mutex    *sync.RWMutex
@@ -41,13 +47,15 @@ func (s *Store) Find(ctx context.Context, key string) (*models.Metric, error) {
 	defer s.mutex.RUnlock()
 	seconds := s.attempts
 	attempt := 0
-	query := "SELECT id, type,  delta, value value FROM metrics WHERE id = $1;"
+	query := "SELECT id, type, delta, value, last_updated FROM metrics WHERE tenant = $1 AND id = $2;"
+	tenant := models.TenantFromContext(ctx)
 	metric, err := backoff.Retry(ctx, func() (*models.Metric, error) {
 		var m models.Metric
-		if err := s.QueryRow(ctx, query, key).Scan(&m.ID,
+		if err := s.QueryRow(ctx, query, tenant, key).Scan(&m.ID,
 			&m.Type,
 			&m.Delta,
-			&m.Value); err != nil {
+			&m.Value,
+			&m.LastUpdated); err != nil {
 			if errors.Is(err, pgx.ErrNoRows) {
 				return nil, backoff.Permanent(err)
 			}
@@ -75,11 +83,73 @@ func (s *Store) Find(ctx context.Context, key string) (*models.Metric, error) {
 func (s *Store) GetAll(ctx context.Context) ([]models.Metric, error) {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
+	query := "SELECT id, type, delta, value, last_updated FROM metrics WHERE tenant = $1 ORDER BY id ASC;"
+	return s.queryMetrics(ctx, query, models.TenantFromContext(ctx))
+}
+
+// List filter, order and limit metrics in database, ids are compared in C collation like in Go
+func (s *Store) List(ctx context.Context, filter persistence.ListFilter) ([]models.Metric, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	var query strings.Builder
+	args := []any{models.TenantFromContext(ctx)}
+	arg := func(value any) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(len(args))
+	}
+	query.WriteString("SELECT id, type, delta, value, last_updated FROM metrics WHERE tenant = $1")
+	if filter.Type != "" {
+		query.WriteString(" AND type = " + arg(filter.Type))
+	}
+	if filter.Prefix != "" {
+		prefix := arg(filter.Prefix)
+		query.WriteString(" AND left(id, char_length(" + prefix + ")) = " + prefix)
+	}
+	if filter.Pattern != nil {
+		query.WriteString(" AND id ~ " + arg(filter.Pattern.String()))
+	}
+	if !filter.Since.IsZero() {
+		query.WriteString(" AND last_updated >= " + arg(filter.Since))
+	}
+	direction, operator := "ASC", ">"
+	if filter.Desc {
+		direction, operator = "DESC", "<"
+	}
+	if filter.After != nil {
+		if filter.Sort == persistence.SortByType {
+			query.WriteString(` AND (type COLLATE "C", id COLLATE "C") ` + operator + " (" + arg(filter.After.Type) + ", " + arg(filter.After.ID) + ")")
+		} else {
+			query.WriteString(` AND id COLLATE "C" ` + operator + " " + arg(filter.After.ID))
+		}
+	}
+	if filter.Sort == persistence.SortByType {
+		query.WriteString(` ORDER BY type COLLATE "C" ` + direction + `, id COLLATE "C" ` + direction)
+	} else {
+		query.WriteString(` ORDER BY id COLLATE "C" ` + direction)
+	}
+	if filter.Limit > 0 {
+		query.WriteString(" LIMIT " + arg(filter.Limit))
+	}
+	return s.queryMetrics(ctx, query.String(), args...)
+}
+
+// Tenants tenants having metrics in bytewise order
+func (s *Store) Tenants(ctx context.Context) ([]string, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	rows, err := s.Query(ctx, `SELECT DISTINCT tenant FROM metrics ORDER BY tenant COLLATE "C";`)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+// queryMetrics run metric select with retry of transient errors
+func (s *Store) queryMetrics(ctx context.Context, query string, args ...any) ([]models.Metric, error) {
 	seconds := s.attempts
 	attempt := 0
-	query := "SELECT id, type,  delta, value FROM metrics ORDER BY id ASC;"
-	metrics, err := backoff.Retry(ctx, func() ([]models.Metric, error) {
-		cursor, err := s.Query(ctx, query)
+	return backoff.Retry(ctx, func() ([]models.Metric, error) {
+		cursor, err := s.Query(ctx, query, args...)
 		if err != nil {
 			var pgerr *pgconn.PgError
 			if errors.As(err, &pgerr) {
@@ -99,7 +169,7 @@ func (s *Store) GetAll(ctx context.Context) ([]models.Metric, error) {
 			}
 			var metric models.Metric
 			if err = cursor.Scan(&metric.ID,
-				&metric.Type, &metric.Delta, &metric.Value); err != nil {
+				&metric.Type, &metric.Delta, &metric.Value, &metric.LastUpdated); err != nil {
 				var pgerr *pgconn.PgError
 				if errors.As(err, &pgerr) {
 					if shouldRetry(pgerr) && attempt < len(seconds) {
@@ -117,23 +187,22 @@ func (s *Store) GetAll(ctx context.Context) ([]models.Metric, error) {
 		}
 		return metrics, nil
 	})
-
-	return metrics, err
 }
 
 func (s *Store) Upsert(ctx context.Context, metric *models.Metric) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
-	deleteSQL := "DELETE FROM metrics WHERE id = $1;"
-	insertSQL := "INSERT INTO metrics (id, type, delta, value) VALUES ($1, $2, $3, $4);"
+	deleteSQL := "DELETE FROM metrics WHERE tenant = $1 AND id = $2;"
+	insertSQL := "INSERT INTO metrics (tenant, id, type, delta, value, last_updated) VALUES ($1, $2, $3, $4, $5, now());"
+	tenant := models.TenantFromContext(ctx)
 	return s.execWithRetry(ctx, func(tx pgx.Tx) error {
-		if _, err := tx.Exec(ctx, deleteSQL, metric.ID); err != nil {
+		if _, err := tx.Exec(ctx, deleteSQL, tenant, metric.ID); err != nil {
 			return err
 		}
-		if _, err := tx.Exec(ctx, insertSQL, metric.ID, metric.Type, metric.Delta, metric.Value); err != nil {
+		if _, err := tx.Exec(ctx, insertSQL, tenant, metric.ID, metric.Type, metric.Delta, metric.Value); err != nil {
 			return err
 		}
-		return nil
+		return completeKey(ctx, tx)
 	})
 }
 
@@ -141,19 +210,100 @@ func (s *Store) BatchUpsert(ctx context.Context, metrics []models.Metric) error
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 	var err error
-	deleteSQL := "DELETE FROM metrics WHERE id = $1;"
-	insertSQL := "INSERT INTO metrics (id, type, delta, value) VALUES ($1, $2, $3, $4);"
+	deleteSQL := "DELETE FROM metrics WHERE tenant = $1 AND id = $2;"
+	insertSQL := "INSERT INTO metrics (tenant, id, type, delta, value, last_updated) VALUES ($1, $2, $3, $4, $5, now());"
+	tenant := models.TenantFromContext(ctx)
 	return s.execWithRetry(ctx, func(tx pgx.Tx) error {
 		for _, metric := range metrics {
-			if _, err = tx.Exec(ctx, deleteSQL, metric.ID); err != nil {
+			if _, err = tx.Exec(ctx, deleteSQL, tenant, metric.ID); err != nil {
 				return err
 			}
-			if _, err = tx.Exec(ctx, insertSQL, metric.ID, metric.Type, metric.Delta, metric.Value); err != nil {
+			if _, err = tx.Exec(ctx, insertSQL, tenant, metric.ID, metric.Type, metric.Delta, metric.Value); err != nil {
 				return err
 			}
 		}
+		return completeKey(ctx, tx)
+	})
+}
+
+// Replace remove metrics and store others in one transaction
+func (s *Store) Replace(ctx context.Context, remove []string, metrics []models.Metric) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	removeSQL := "DELETE FROM metrics WHERE tenant = $1 AND id = ANY($2);"
+	deleteSQL := "DELETE FROM metrics WHERE tenant = $1 AND id = $2;"
+	insertSQL := "INSERT INTO metrics (tenant, id, type, delta, value, last_updated) VALUES ($1, $2, $3, $4, $5, now());"
+	tenant := models.TenantFromContext(ctx)
+	return s.execWithRetry(ctx, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, removeSQL, tenant, remove); err != nil {
+			return err
+		}
+		for _, metric := range metrics {
+			if _, err := tx.Exec(ctx, deleteSQL, tenant, metric.ID); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(ctx, insertSQL, tenant, metric.ID, metric.Type, metric.Delta, metric.Value); err != nil {
+				return err
+			}
+		}
+		return completeKey(ctx, tx)
+	})
+}
+
+func (s *Store) Delete(ctx context.Context, ids []string) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	deleteSQL := "DELETE FROM metrics WHERE tenant = $1 AND id = ANY($2);"
+	return s.execAffected(ctx, deleteSQL, models.TenantFromContext(ctx), ids)
+}
+
+func (s *Store) Reset(ctx context.Context, ids []string) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	resetSQL := `UPDATE metrics SET
+		delta = CASE WHEN type = 'counter' THEN 0 END,
+		value = CASE WHEN type = 'counter' THEN NULL ELSE 0 END,
+		last_updated = now()
+		WHERE tenant = $1 AND id = ANY($2);`
+	return s.execAffected(ctx, resetSQL, models.TenantFromContext(ctx), ids)
+}
+
+func (s *Store) Expire(ctx context.Context, before time.Time) (map[string][]string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	expireSQL := "DELETE FROM metrics WHERE last_updated < $1 RETURNING tenant, id;"
+	var removed map[string][]string
+	err := s.execWithRetry(ctx, func(tx pgx.Tx) error {
+		removed = make(map[string][]string)
+		rows, err := tx.Query(ctx, expireSQL, before)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		var tenant, id string
+		for rows.Next() {
+			if err = rows.Scan(&tenant, &id); err != nil {
+				return err
+			}
+			removed[tenant] = append(removed[tenant], id)
+		}
+		return rows.Err()
+	})
+	return removed, err
+}
+
+// execAffected run statement in transaction and return number of affected rows
+func (s *Store) execAffected(ctx context.Context, sql string, args ...any) (int, error) {
+	var affected int64
+	err := s.execWithRetry(ctx, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, sql, args...)
+		if err != nil {
+			return err
+		}
+		affected = tag.RowsAffected()
 		return nil
 	})
+	return int(affected), err
 }
 
 func migrateDB(pgx *pgxpool.Pool) error {
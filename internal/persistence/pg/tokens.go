@@ -0,0 +1,111 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/cenkalti/backoff/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/DimKa163/go-metrics/internal/models"
+	"github.com/DimKa163/go-metrics/internal/persistence"
+)
+
+func (s *Store) FindToken(ctx context.Context, hash string) (*models.Token, error) {
+	query := "SELECT id, tenant, scopes, hash, created_at FROM tokens WHERE hash = $1;"
+	attempt := 0
+	token, err := backoff.Retry(ctx, func() (*models.Token, error) {
+		token, err := scanToken(s.QueryRow(ctx, query, hash))
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil, backoff.Permanent(err)
+			}
+			return nil, s.retryError(err, &attempt)
+		}
+		return token, nil
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, persistence.ErrTokenNotFound
+		}
+		return nil, err
+	}
+	return token, nil
+}
+
+func (s *Store) ListTokens(ctx context.Context, tenant string) ([]models.Token, error) {
+	query := "SELECT id, tenant, scopes, hash, created_at FROM tokens WHERE $1 = '' OR tenant = $1 ORDER BY created_at ASC;"
+	attempt := 0
+	return backoff.Retry(ctx, func() ([]models.Token, error) {
+		cursor, err := s.Query(ctx, query, tenant)
+		if err != nil {
+			return nil, s.retryError(err, &attempt)
+		}
+		defer cursor.Close()
+		tokens := make([]models.Token, 0)
+		for cursor.Next() {
+			token, err := scanToken(cursor)
+			if err != nil {
+				return nil, s.retryError(err, &attempt)
+			}
+			tokens = append(tokens, *token)
+		}
+		if err = cursor.Err(); err != nil {
+			return nil, backoff.Permanent(err)
+		}
+		return tokens, nil
+	})
+}
+
+func (s *Store) CreateToken(ctx context.Context, token *models.Token) error {
+	insertSQL := "INSERT INTO tokens (id, tenant, scopes, hash, created_at) VALUES ($1, $2, $3, $4, $5);"
+	scopes := make([]string, len(token.Scopes))
+	for i, scope := range token.Scopes {
+		scopes[i] = string(scope)
+	}
+	return s.execWithRetry(ctx, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, insertSQL, token.ID, token.Tenant, strings.Join(scopes, ","), token.Hash, token.CreatedAt)
+		return err
+	})
+}
+
+func (s *Store) DeleteToken(ctx context.Context, tenant string, id string) error {
+	deleteSQL := "DELETE FROM tokens WHERE id = $1 AND ($2 = '' OR tenant = $2);"
+	return s.execWithRetry(ctx, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, deleteSQL, id, tenant)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return persistence.ErrTokenNotFound
+		}
+		return nil
+	})
+}
+
+func scanToken(row pgx.Row) (*models.Token, error) {
+	var token models.Token
+	var scopes string
+	if err := row.Scan(&token.ID, &token.Tenant, &scopes, &token.Hash, &token.CreatedAt); err != nil {
+		return nil, err
+	}
+	for _, scope := range strings.Split(scopes, ",") {
+		if scope != "" {
+			token.Scopes = append(token.Scopes, models.Scope(scope))
+		}
+	}
+	return &token, nil
+}
+
+// retryError wrap err for backoff, retryable postgres errors are retried while attempts last
+func (s *Store) retryError(err error, attempt *int) error {
+	var pgerr *pgconn.PgError
+	if errors.As(err, &pgerr) && shouldRetry(pgerr) && *attempt < len(s.attempts) {
+		at := *attempt
+		*attempt++
+		return backoff.RetryAfter(s.attempts[at])
+	}
+	return backoff.Permanent(err)
+}
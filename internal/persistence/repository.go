@@ -2,6 +2,7 @@ package persistence
 
 import (
 	"context"
+	"time"
 
 	"github.com/DimKa163/go-metrics/internal/models"
 )
@@ -11,7 +12,21 @@ type Repository interface {
 
 	GetAll(ctx context.Context) ([]models.Metric, error)
 
+	List(ctx context.Context, filter ListFilter) ([]models.Metric, error)
+
 	Upsert(ctx context.Context, metric *models.Metric) error
 
 	BatchUpsert(ctx context.Context, metrics []models.Metric) error
+
+	// Replace remove metrics and store others at once, failed replace leaves stored metrics untouched
+	Replace(ctx context.Context, remove []string, metrics []models.Metric) error
+
+	// Delete remove metrics and return how many of them existed
+	Delete(ctx context.Context, ids []string) (int, error)
+
+	// Reset set value of metrics to zero, stamp them as updated and return how many of them existed
+	Reset(ctx context.Context, ids []string) (int, error)
+
+	// Expire remove metrics of every tenant last updated before given time and return removed ids per tenant
+	Expire(ctx context.Context, before time.Time) (map[string][]string, error)
 }
@@ -0,0 +1,17 @@
+package persistence
+
+import (
+	"context"
+
+	"github.com/DimKa163/go-metrics/internal/models"
+)
+
+// Snapshotter repository able to return metrics of every tenant at once, used for dumps
+type Snapshotter interface {
+	Snapshot(ctx context.Context) (map[string][]models.Metric, error)
+}
+
+// TenantLister repository able to name tenants having metrics, used to walk every tenant during migration
+type TenantLister interface {
+	Tenants(ctx context.Context) ([]string, error)
+}
@@ -0,0 +1,23 @@
+package persistence
+
+import (
+	"context"
+	"errors"
+
+	"github.com/DimKa163/go-metrics/internal/models"
+)
+
+var ErrTokenNotFound = errors.New("token not found")
+
+type TokenRepository interface {
+	// FindToken find token by hash of its secret
+	FindToken(ctx context.Context, hash string) (*models.Token, error)
+
+	// ListTokens tokens of tenant, every token when tenant is empty
+	ListTokens(ctx context.Context, tenant string) ([]models.Token, error)
+
+	CreateToken(ctx context.Context, token *models.Token) error
+
+	// DeleteToken delete token of tenant, token of any tenant when tenant is empty
+	DeleteToken(ctx context.Context, tenant string, id string) error
+}
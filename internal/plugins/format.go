@@ -0,0 +1,187 @@
+package plugins
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/DimKa163/go-metrics/internal/models"
+)
+
+const (
+	FormatLines  = "lines"
+	FormatJSON   = "json"
+	FormatInflux = "influx"
+)
+
+var ErrUnknownFormat = errors.New("unknown plugin output format")
+
+// Parser convert command output to metrics
+type Parser func(output []byte) ([]*models.Metric, error)
+
+// NewParser return parser for the given format, lines is default
+func NewParser(format string) (Parser, error) {
+	switch format {
+	case "", FormatLines:
+		return ParseLines, nil
+	case FormatJSON:
+		return ParseJSON, nil
+	case FormatInflux:
+		return ParseInflux, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+	}
+}
+
+// ParseLines parse "name type value" lines, blank lines and # comments are skipped
+func ParseLines(output []byte) ([]*models.Metric, error) {
+	var metrics []*models.Metric
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("line %d: expected \"name type value\"", lineNum)
+		}
+		metric, err := models.CreateMetric(fields[1], fields[0], fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		metrics = append(metrics, &metric)
+	}
+	return metrics, scanner.Err()
+}
+
+// ParseJSON parse array of metrics in keeper format
+func ParseJSON(output []byte) ([]*models.Metric, error) {
+	var metrics []*models.Metric
+	if err := json.Unmarshal(output, &metrics); err != nil {
+		return nil, err
+	}
+	for i, metric := range metrics {
+		if metric == nil {
+			return nil, fmt.Errorf("item %d: empty metric", i)
+		}
+		if err := models.ValidateMetric(metric); err != nil {
+			return nil, fmt.Errorf("item %d: %w", i, err)
+		}
+		if metric.Type == models.GaugeType && metric.Value == nil || metric.Type == models.CounterType && metric.Delta == nil {
+			return nil, fmt.Errorf("item %d: value is required", i)
+		}
+	}
+	return metrics, nil
+}
+
+// ParseInflux parse Influx line protocol, every numeric field becomes gauge measurement_field{tags}
+func ParseInflux(output []byte) ([]*models.Metric, error) {
+	var metrics []*models.Metric
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var parts []string
+		for _, part := range splitUnescaped(line, ' ') {
+			if part != "" {
+				parts = append(parts, part)
+			}
+		}
+		if len(parts) < 2 || len(parts) > 3 {
+			return nil, fmt.Errorf("line %d: expected \"measurement[,tags] fields [timestamp]\"", lineNum)
+		}
+		key := splitUnescaped(parts[0], ',')
+		measurement := unescape(key[0])
+		tags := make(map[string]string, len(key)-1)
+		for _, tag := range key[1:] {
+			kv := splitUnescaped(tag, '=')
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("line %d: invalid tag %q", lineNum, tag)
+			}
+			tags[unescape(kv[0])] = unescape(kv[1])
+		}
+		for _, field := range splitUnescaped(parts[1], ',') {
+			kv := splitUnescaped(field, '=')
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("line %d: invalid field %q", lineNum, field)
+			}
+			value, ok, err := parseInfluxValue(kv[1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNum, err)
+			}
+			if !ok {
+				continue
+			}
+			id := models.ComposeID(measurement+"_"+unescape(kv[0]), tags)
+			metrics = append(metrics, models.CreateGauge(id, value))
+		}
+	}
+	return metrics, scanner.Err()
+}
+
+// parseInfluxValue returns false for string fields which can't be represented as metric
+func parseInfluxValue(s string) (float64, bool, error) {
+	if strings.HasPrefix(s, "\"") {
+		return 0, false, nil
+	}
+	switch s {
+	case "t", "T", "true", "True", "TRUE":
+		return 1, true, nil
+	case "f", "F", "false", "False", "FALSE":
+		return 0, true, nil
+	}
+	if trimmed, ok := strings.CutSuffix(s, "i"); ok {
+		v, err := strconv.ParseInt(trimmed, 10, 64)
+		return float64(v), err == nil, err
+	}
+	if trimmed, ok := strings.CutSuffix(s, "u"); ok {
+		v, err := strconv.ParseUint(trimmed, 10, 64)
+		return float64(v), err == nil, err
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	return v, err == nil, err
+}
+
+// splitUnescaped split by separator ignoring escaped and quoted occurrences
+func splitUnescaped(s string, sep byte) []string {
+	var parts []string
+	start := 0
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func unescape(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,53 @@
+package plugins
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DimKa163/go-metrics/internal/models"
+)
+
+func TestParseLines(t *testing.T) {
+	metrics, err := ParseLines([]byte("# disk check\nDiskFree gauge 12.5\n\nErrors counter 3\n"))
+	require.NoError(t, err)
+	require.Len(t, metrics, 2)
+	assert.Equal(t, models.CreateGauge("DiskFree", 12.5), metrics[0])
+	assert.Equal(t, models.CreateCounter("Errors", 3), metrics[1])
+
+	_, err = ParseLines([]byte("DiskFree gauge"))
+	assert.Error(t, err)
+
+	_, err = ParseLines([]byte("DiskFree histogram 1"))
+	assert.ErrorIs(t, err, models.ErrUnknownMetricType)
+}
+
+func TestParseJSON(t *testing.T) {
+	metrics, err := ParseJSON([]byte(`[{"id":"Queue","type":"gauge","value":4},{"id":"Jobs","type":"counter","delta":2}]`))
+	require.NoError(t, err)
+	require.Len(t, metrics, 2)
+	assert.Equal(t, float64(4), *metrics[0].Value)
+	assert.Equal(t, int64(2), *metrics[1].Delta)
+
+	_, err = ParseJSON([]byte(`[{"id":"Queue","type":"gauge"}]`))
+	assert.Error(t, err)
+}
+
+func TestParseInflux(t *testing.T) {
+	metrics, err := ParseInflux([]byte(`disk,host=web\ 1,mount=/ free=12.5,used=3i,ok=true,label="x" 1556813561098000000`))
+	require.NoError(t, err)
+	require.Len(t, metrics, 3)
+	assert.Equal(t, `disk_free{host="web 1",mount="/"}`, metrics[0].ID)
+	assert.Equal(t, 12.5, *metrics[0].Value)
+	assert.Equal(t, float64(3), *metrics[1].Value)
+	assert.Equal(t, float64(1), *metrics[2].Value)
+
+	_, err = ParseInflux([]byte("disk"))
+	assert.Error(t, err)
+}
+
+func TestNewParserUnknownFormat(t *testing.T) {
+	_, err := NewParser("yaml")
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+}
@@ -0,0 +1,204 @@
+// Package plugins run external commands and collect their output as metrics
+package plugins
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/DimKa163/go-metrics/internal/client"
+	"github.com/DimKa163/go-metrics/internal/models"
+)
+
+const (
+	defaultInterval = 10
+	defaultTimeout  = 5
+)
+
+var ErrBusy = errors.New("previous plugin run still in progress")
+
+// baseEnv variables of agent every plugin gets, agent secrets like KEY or CRYPTO_KEY never leak to plugins
+var baseEnv = []string{"PATH", "HOME"}
+
+// Plugin external command producing metrics on stdout. Command gets PATH, HOME, variables of agent
+// named in PassEnv and Env, nothing else of agent environment
+type Plugin struct {
+	Name        string            `json:"name"`
+	Command     string            `json:"command"`
+	Args        []string          `json:"args"`
+	Env         map[string]string `json:"env"`
+	PassEnv     []string          `json:"pass_env"`
+	Format      string            `json:"format"`
+	Interval    int               `json:"interval"`
+	Timeout     int               `json:"timeout"`
+	Concurrency int               `json:"concurrency"`
+}
+
+// Limiter bound number of commands running at once across plugins
+type Limiter struct {
+	slots chan struct{}
+}
+
+// NewLimiter create limiter, non-positive size means unlimited
+func NewLimiter(size int) *Limiter {
+	if size <= 0 {
+		return nil
+	}
+	return &Limiter{slots: make(chan struct{}, size)}
+}
+
+func (l *Limiter) acquire(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *Limiter) release() {
+	if l == nil {
+		return
+	}
+	<-l.slots
+}
+
+// Runner execute plugin on schedule and forward parsed metrics
+type Runner struct {
+	plugin  Plugin
+	client  client.MetricClient
+	parse   Parser
+	running chan struct{}
+	limiter *Limiter
+}
+
+func NewRunner(plugin Plugin, metricClient client.MetricClient, limiter *Limiter) (*Runner, error) {
+	if plugin.Command == "" {
+		return nil, fmt.Errorf("plugin %q: command is required", plugin.Name)
+	}
+	parse, err := NewParser(plugin.Format)
+	if err != nil {
+		return nil, fmt.Errorf("plugin %q: %w", plugin.Name, err)
+	}
+	if plugin.Interval <= 0 {
+		plugin.Interval = defaultInterval
+	}
+	if plugin.Timeout <= 0 {
+		plugin.Timeout = defaultTimeout
+	}
+	if plugin.Concurrency <= 0 {
+		plugin.Concurrency = 1
+	}
+	return &Runner{
+		plugin:  plugin,
+		client:  metricClient,
+		parse:   parse,
+		running: make(chan struct{}, plugin.Concurrency),
+		limiter: limiter,
+	}, nil
+}
+
+// Run execute plugin on interval until context cancelled
+func (r *Runner) Run(ctx context.Context) error {
+	ticker := time.NewTicker(time.Duration(r.plugin.Interval) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			select {
+			case r.running <- struct{}{}:
+			default:
+				fmt.Printf("Plugin %s skipped: %v\n", r.plugin.Name, ErrBusy)
+				continue
+			}
+			go func() {
+				defer func() { <-r.running }()
+				metrics, err := r.Collect(ctx)
+				if err != nil {
+					fmt.Printf("Plugin %s failed: %v\n", r.plugin.Name, err)
+					return
+				}
+				if len(metrics) == 0 {
+					return
+				}
+				if err = r.client.BatchUpdate(metrics); err != nil {
+					fmt.Printf("Error sending plugin metrics: %v\n", err)
+				}
+			}()
+		}
+	}
+}
+
+// Collect run command once and parse its output
+func (r *Runner) Collect(ctx context.Context) ([]*models.Metric, error) {
+	if err := r.limiter.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer r.limiter.release()
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(r.plugin.Timeout)*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, r.plugin.Command, r.plugin.Args...)
+	cmd.Env = r.environ()
+	cmd.WaitDelay = time.Second
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("timed out after %ds: %w", r.plugin.Timeout, ctx.Err())
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+	metrics, err := r.parse(stdout.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return r.dropLongIDs(metrics), nil
+}
+
+// environ minimal environment of command
+func (r *Runner) environ() []string {
+	var env []string
+	for _, names := range [][]string{baseEnv, r.plugin.PassEnv} {
+		for _, name := range names {
+			if value, ok := os.LookupEnv(name); ok {
+				env = append(env, name+"="+value)
+			}
+		}
+	}
+	for k, v := range r.plugin.Env {
+		env = append(env, k+"="+v)
+	}
+	return env
+}
+
+// dropLongIDs skip metrics keeper would reject whole batch for, influx output easily exceeds id limit with tags
+func (r *Runner) dropLongIDs(metrics []*models.Metric) []*models.Metric {
+	kept := metrics[:0]
+	var skipped []string
+	for _, metric := range metrics {
+		if len(metric.ID) > models.MaxIDLength {
+			skipped = append(skipped, metric.ID)
+			continue
+		}
+		kept = append(kept, metric)
+	}
+	if len(skipped) > 0 {
+		fmt.Printf("Plugin %s: skipped %d metrics with id longer than %d, first is %s\n",
+			r.plugin.Name, len(skipped), models.MaxIDLength, skipped[0])
+	}
+	return kept
+}
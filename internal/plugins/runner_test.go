@@ -0,0 +1,71 @@
+package plugins
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DimKa163/go-metrics/internal/models"
+)
+
+func TestCollectPassesEnvironment(t *testing.T) {
+	t.Setenv("KEY", "secret")
+	t.Setenv("CRYPTO_KEY", "/etc/agent/key.pem")
+	t.Setenv("DISK_PATH", "/data")
+	sut, err := NewRunner(Plugin{
+		Name:    "env",
+		Command: "sh",
+		Args:    []string{"-c", `echo "Checks counter $CHECKS"; echo "Secrets gauge ${#KEY}${#CRYPTO_KEY}"; echo "Passed gauge ${#DISK_PATH}"`},
+		Env:     map[string]string{"CHECKS": "7"},
+		PassEnv: []string{"DISK_PATH"},
+	}, nil, nil)
+	require.NoError(t, err)
+
+	metrics, err := sut.Collect(context.Background())
+	require.NoError(t, err)
+	require.Len(t, metrics, 3)
+	assert.Equal(t, "Checks", metrics[0].ID)
+	assert.Equal(t, int64(7), *metrics[0].Delta)
+	assert.Equal(t, float64(0), *metrics[1].Value, "agent secrets are stripped")
+	assert.Equal(t, float64(5), *metrics[2].Value, "allowed variable is passed")
+}
+
+func TestCollectSkipsLongIDs(t *testing.T) {
+	long := strings.Repeat("a", models.MaxIDLength)
+	sut, err := NewRunner(Plugin{
+		Name:    "long",
+		Command: "sh",
+		Args:    []string{"-c", "echo 'disk,path=" + long + " used=1'; echo 'disk,path=/ used=2'"},
+		Format:  FormatInflux,
+	}, nil, nil)
+	require.NoError(t, err)
+
+	metrics, err := sut.Collect(context.Background())
+	require.NoError(t, err)
+	require.Len(t, metrics, 1, "metric with too long id is skipped instead of failing batch")
+	assert.Equal(t, float64(2), *metrics[0].Value)
+}
+
+func TestCollectTimeout(t *testing.T) {
+	sut, err := NewRunner(Plugin{
+		Name:    "slow",
+		Command: "sleep",
+		Args:    []string{"5"},
+		Timeout: 1,
+	}, nil, NewLimiter(1))
+	require.NoError(t, err)
+
+	_, err = sut.Collect(context.Background())
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestCollectReportsStderr(t *testing.T) {
+	sut, err := NewRunner(Plugin{Command: "sh", Args: []string{"-c", "echo broken >&2; exit 3"}}, nil, nil)
+	require.NoError(t, err)
+
+	_, err = sut.Collect(context.Background())
+	assert.ErrorContains(t, err, "broken")
+}
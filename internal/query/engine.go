@@ -0,0 +1,250 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/DimKa163/go-metrics/internal/models"
+)
+
+const (
+	// DefaultLookback how old the last sample of metric may be to be selected at moment
+	DefaultLookback = 5 * time.Minute
+	// MaxSteps longest range evaluation
+	MaxSteps = 11000
+	// MaxSeriesSteps series evaluations range query may do in total, every evaluation scans history of series
+	MaxSeriesSteps = 50000
+)
+
+// Storage metrics of tenant taken from context
+type Storage interface {
+	// Metrics current values of all metrics
+	Metrics(ctx context.Context) ([]models.Metric, error)
+	// Samples recorded values of metric with time in (from, to], oldest first
+	Samples(ctx context.Context, id string, from time.Time, to time.Time) []Sample
+}
+
+// Engine evaluate queries over storage
+type Engine struct {
+	storage  Storage
+	lookback time.Duration
+}
+
+func NewEngine(storage Storage, lookback time.Duration) *Engine {
+	if lookback <= 0 {
+		lookback = DefaultLookback
+	}
+	return &Engine{storage: storage, lookback: lookback}
+}
+
+// Instant evaluate expression at moment. Zero moment evaluates current values of metrics,
+// recorded samples are used otherwise. Range selector returns its raw samples as matrix
+func (e *Engine) Instant(ctx context.Context, expr Expr, at time.Time) (Result, error) {
+	current := at.IsZero()
+	if current {
+		at = time.Now()
+	}
+	ev, err := e.newEvaluator(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+	if selector, ok := expr.(*Selector); ok && selector.Range > 0 {
+		return Result{Type: MatrixType, Series: ev.rangeSelector(selector, at)}, nil
+	}
+	vector, err := ev.eval(expr, at, current)
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{Type: VectorType, Series: vector}, nil
+}
+
+// Range evaluate expression at every step from start to end and join results into matrix
+func (e *Engine) Range(ctx context.Context, expr Expr, start time.Time, end time.Time, step time.Duration) (Result, error) {
+	if selector, ok := expr.(*Selector); ok && selector.Range > 0 {
+		return Result{}, fmt.Errorf("%w: range query expects instant expression, got %s", ErrInvalidQuery, expr)
+	}
+	if step <= 0 || end.Before(start) {
+		return Result{}, fmt.Errorf("%w: step must be positive and end not before start", ErrInvalidQuery)
+	}
+	if end.Sub(start)/step >= MaxSteps {
+		return Result{}, fmt.Errorf("%w: more than %d steps", ErrInvalidQuery, MaxSteps)
+	}
+	ev, err := e.newEvaluator(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+	steps := int(end.Sub(start)/step) + 1
+	if series := ev.matched(expr); series*steps > MaxSeriesSteps {
+		return Result{}, fmt.Errorf("%w: %d series at %d steps is more than %d evaluations, narrow selector or increase step",
+			ErrInvalidQuery, series, steps, MaxSeriesSteps)
+	}
+	merged := make(map[string]*Series)
+	for at := start; !at.After(end); at = at.Add(step) {
+		vector, err := ev.eval(expr, at, false)
+		if err != nil {
+			return Result{}, err
+		}
+		for _, series := range vector {
+			key := series.key()
+			if it, ok := merged[key]; ok {
+				it.Samples = append(it.Samples, series.Samples...)
+				continue
+			}
+			merged[key] = &series
+		}
+	}
+	matrix := make([]Series, 0, len(merged))
+	for _, series := range merged {
+		matrix = append(matrix, *series)
+	}
+	sortSeries(matrix)
+	return Result{Type: MatrixType, Series: matrix}, nil
+}
+
+// candidate metric parsed into name and labels
+type candidate struct {
+	id     string
+	name   string
+	labels map[string]string
+	value  float64
+}
+
+type evaluator struct {
+	ctx        context.Context
+	storage    Storage
+	lookback   time.Duration
+	candidates []candidate
+}
+
+func (e *Engine) newEvaluator(ctx context.Context) (*evaluator, error) {
+	metrics, err := e.storage.Metrics(ctx)
+	if err != nil {
+		return nil, err
+	}
+	candidates := make([]candidate, 0, len(metrics))
+	for _, metric := range metrics {
+		name, labels := models.ParseID(metric.ID)
+		c := candidate{id: metric.ID, name: name, labels: labels}
+		switch {
+		case metric.Value != nil:
+			c.value = *metric.Value
+		case metric.Delta != nil:
+			c.value = float64(*metric.Delta)
+		}
+		candidates = append(candidates, c)
+	}
+	return &evaluator{ctx: ctx, storage: e.storage, lookback: e.lookback, candidates: candidates}, nil
+}
+
+func (ev *evaluator) eval(expr Expr, at time.Time, current bool) ([]Series, error) {
+	switch expr := expr.(type) {
+	case *Selector:
+		if expr.Range > 0 {
+			return nil, fmt.Errorf("%w: range selector %s must be passed to function", ErrInvalidQuery, expr)
+		}
+		return ev.instantSelector(expr, at, current), nil
+	case *Call:
+		return ev.call(expr, at), nil
+	case *Aggregate:
+		vector, err := ev.eval(expr.Expr, at, current)
+		if err != nil {
+			return nil, err
+		}
+		return aggregate(expr, vector, at), nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported expression %s", ErrInvalidQuery, expr)
+	}
+}
+
+func (ev *evaluator) instantSelector(selector *Selector, at time.Time, current bool) []Series {
+	var vector []Series
+	for _, c := range ev.selectCandidates(selector) {
+		value := c.value
+		if !current {
+			samples := ev.storage.Samples(ev.ctx, c.id, at.Add(-ev.lookback), at)
+			if len(samples) == 0 {
+				continue
+			}
+			value = samples[len(samples)-1].Value
+		}
+		vector = append(vector, Series{Name: c.name, Labels: c.labels, Samples: []Sample{{Time: at, Value: value}}})
+	}
+	sortSeries(vector)
+	return vector
+}
+
+func (ev *evaluator) rangeSelector(selector *Selector, at time.Time) []Series {
+	var matrix []Series
+	for _, c := range ev.selectCandidates(selector) {
+		samples := ev.storage.Samples(ev.ctx, c.id, at.Add(-selector.Range), at)
+		if len(samples) == 0 {
+			continue
+		}
+		matrix = append(matrix, Series{Name: c.name, Labels: c.labels, Samples: samples})
+	}
+	sortSeries(matrix)
+	return matrix
+}
+
+func (ev *evaluator) call(call *Call, at time.Time) []Series {
+	var vector []Series
+	for _, c := range ev.selectCandidates(call.Arg) {
+		samples := ev.storage.Samples(ev.ctx, c.id, at.Add(-call.Arg.Range), at)
+		value, ok := apply(call.Func, samples, call.Arg.Range)
+		if !ok {
+			continue
+		}
+		// like PromQL functions drop metric name, result is no longer that metric
+		vector = append(vector, Series{Labels: c.labels, Samples: []Sample{{Time: at, Value: value}}})
+	}
+	sortSeries(vector)
+	return vector
+}
+
+// matched number of series selectors of expression read at every step
+func (ev *evaluator) matched(expr Expr) int {
+	switch expr := expr.(type) {
+	case *Selector:
+		return len(ev.selectCandidates(expr))
+	case *Call:
+		return len(ev.selectCandidates(expr.Arg))
+	case *Aggregate:
+		return ev.matched(expr.Expr)
+	default:
+		return 0
+	}
+}
+
+func (ev *evaluator) selectCandidates(selector *Selector) []candidate {
+	var result []candidate
+	for _, c := range ev.candidates {
+		if selector.Name != "" {
+			if ok, _ := path.Match(selector.Name, c.name); !ok {
+				continue
+			}
+		}
+		matched := true
+		for i := range selector.Matchers {
+			matcher := &selector.Matchers[i]
+			value := c.labels[matcher.Label]
+			if matcher.Label == NameLabel {
+				value = c.name
+			}
+			if !matcher.matches(value) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+func sortSeries(series []Series) {
+	sort.Slice(series, func(i, j int) bool { return series[i].key() < series[j].key() })
+}
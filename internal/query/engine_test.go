@@ -0,0 +1,154 @@
+package query
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DimKa163/go-metrics/internal/models"
+)
+
+type testStorage struct {
+	metrics []models.Metric
+	history *History
+}
+
+func (s *testStorage) Metrics(_ context.Context) ([]models.Metric, error) {
+	return s.metrics, nil
+}
+
+func (s *testStorage) Samples(_ context.Context, id string, from time.Time, to time.Time) []Sample {
+	return s.history.Range(models.DefaultTenant, id, from, to)
+}
+
+func newTestEngine(start time.Time) *Engine {
+	storage := &testStorage{history: NewHistory(10)}
+	add := func(id string, values ...float64) {
+		for i, value := range values {
+			storage.history.Add(models.DefaultTenant, id, Sample{Time: start.Add(time.Duration(i) * time.Minute), Value: value})
+		}
+		storage.metrics = append(storage.metrics, *models.CreateGauge(id, values[len(values)-1]))
+	}
+	add(`CPUutilization{host="a",core="1"}`, 10, 20, 30)
+	add(`CPUutilization{host="a",core="2"}`, 40, 50, 60)
+	add(`CPUutilization{host="b",core="1"}`, 70, 80, 90)
+	add("PollCount", 0, 60, 120, 30)
+	add("HeapAlloc", 5)
+	return NewEngine(storage, 5*time.Minute)
+}
+
+func TestEngineInstant(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	engine := newTestEngine(start)
+	at := start.Add(3 * time.Minute)
+	cases := []struct {
+		query    string
+		expected map[string]float64
+	}{
+		{`CPUutilization{host="a"}`, map[string]float64{`CPUutilization{core="1",host="a"}`: 30, `CPUutilization{core="2",host="a"}`: 60}},
+		{`sum by (host) (CPUutilization*)`, map[string]float64{`{host="a"}`: 90, `{host="b"}`: 90}},
+		{`avg(CPU*)`, map[string]float64{`{}`: 60}},
+		{`min without (core) (CPUutilization)`, map[string]float64{`{host="a"}`: 30, `{host="b"}`: 90}},
+		{`max(CPUutilization{core=~"1|2", host!="b"})`, map[string]float64{`{}`: 60}},
+		{`increase(PollCount[5m])`, map[string]float64{`{}`: 150}},
+		{`rate(PollCount[5m])`, map[string]float64{`{}`: 0.5}},
+		{`delta(CPUutilization{host="b"}[5m])`, map[string]float64{`{core="1",host="b"}`: 20}},
+		{`delta(HeapAlloc[5m])`, map[string]float64{}},
+		{`{__name__="HeapAlloc"}`, map[string]float64{`HeapAlloc{}`: 5}},
+	}
+	for _, c := range cases {
+		t.Run(c.query, func(t *testing.T) {
+			expr, err := Parse(c.query)
+			require.NoError(t, err)
+			result, err := engine.Instant(context.Background(), expr, at)
+			require.NoError(t, err)
+			assert.Equal(t, VectorType, result.Type)
+			assert.Equal(t, c.expected, values(result))
+		})
+	}
+}
+
+func TestEngineTopk(t *testing.T) {
+	engine := newTestEngine(time.Unix(1700000000, 0))
+	expr, err := Parse("topk(2, CPUutilization)")
+	require.NoError(t, err)
+	result, err := engine.Instant(context.Background(), expr, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, result.Series, 2)
+	assert.Equal(t, 90.0, result.Series[0].Samples[0].Value, "largest first")
+	assert.Equal(t, 60.0, result.Series[1].Samples[0].Value)
+	assert.Equal(t, "CPUutilization", result.Series[0].Name)
+}
+
+func TestEngineRange(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	engine := newTestEngine(start)
+	expr, err := Parse(`sum(CPUutilization{core="1"})`)
+	require.NoError(t, err)
+	result, err := engine.Range(context.Background(), expr, start, start.Add(2*time.Minute), time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, MatrixType, result.Type)
+	require.Len(t, result.Series, 1)
+	var got []float64
+	for _, sample := range result.Series[0].Samples {
+		got = append(got, sample.Value)
+	}
+	assert.Equal(t, []float64{80, 100, 120}, got)
+
+	raw, err := Parse("PollCount[2m]")
+	require.NoError(t, err)
+	result, err = engine.Instant(context.Background(), raw, start.Add(3*time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, MatrixType, result.Type)
+	require.Len(t, result.Series, 1)
+	assert.Len(t, result.Series[0].Samples, 2)
+
+	_, err = engine.Range(context.Background(), raw, start, start.Add(time.Minute), time.Minute)
+	assert.ErrorIs(t, err, ErrInvalidQuery)
+	_, err = engine.Range(context.Background(), expr, start, start.Add(time.Hour), time.Millisecond)
+	assert.ErrorIs(t, err, ErrInvalidQuery, "too many steps")
+
+	broad, err := Parse(`sum({__name__=~".+"})`)
+	require.NoError(t, err)
+	_, err = engine.Range(context.Background(), broad, start, start.Add(10000*time.Second), time.Second)
+	assert.ErrorIs(t, err, ErrInvalidQuery, "5 series at 10001 steps are too many evaluations")
+	_, err = engine.Range(context.Background(), expr, start, start.Add(10000*time.Second), time.Second)
+	assert.NoError(t, err, "2 series at 10001 steps fit")
+}
+
+func TestHistoryRing(t *testing.T) {
+	history := NewHistory(3)
+	start := time.Unix(1700000000, 0)
+	for i := 0; i < 5; i++ {
+		history.Add("alpha", "PollCount", Sample{Time: start.Add(time.Duration(i) * time.Second), Value: float64(i)})
+	}
+	history.Add("alpha", "PollCount", Sample{Time: start, Value: 100})
+	samples := history.Range("alpha", "PollCount", start, start.Add(time.Minute))
+	assert.Equal(t, []float64{2, 3, 4}, sampleValues(samples), "oldest overwritten, out of order ignored")
+	assert.Empty(t, history.Range("beta", "PollCount", start, start.Add(time.Minute)))
+	history.Forget("alpha", "PollCount")
+	assert.Empty(t, history.Range("alpha", "PollCount", start, start.Add(time.Minute)))
+}
+
+func values(result Result) map[string]float64 {
+	got := make(map[string]float64)
+	for _, series := range result.Series {
+		id := models.ComposeID(series.Name, series.Labels)
+		if len(series.Labels) == 0 {
+			id += "{}"
+		}
+		got[id] = series.Samples[0].Value
+	}
+	return got
+}
+
+func sampleValues(samples []Sample) []float64 {
+	result := make([]float64, len(samples))
+	for i, sample := range samples {
+		result[i] = sample.Value
+	}
+	return result
+}
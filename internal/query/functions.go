@@ -0,0 +1,126 @@
+package query
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// apply range function to samples, at least two samples are needed
+func apply(function string, samples []Sample, window time.Duration) (float64, bool) {
+	if len(samples) < 2 {
+		return 0, false
+	}
+	switch function {
+	case "delta":
+		return samples[len(samples)-1].Value - samples[0].Value, true
+	case "increase":
+		return increase(samples), true
+	case "rate":
+		return increase(samples) / window.Seconds(), true
+	default:
+		return 0, false
+	}
+}
+
+// increase sum of growth between samples, drop of value is counter reset and counts from zero
+func increase(samples []Sample) float64 {
+	var result float64
+	for i := 1; i < len(samples); i++ {
+		diff := samples[i].Value - samples[i-1].Value
+		if diff < 0 {
+			diff = samples[i].Value
+		}
+		result += diff
+	}
+	return result
+}
+
+type group struct {
+	labels map[string]string
+	series []Series
+}
+
+// aggregate vector within groups made by grouping labels
+func aggregate(agg *Aggregate, vector []Series, at time.Time) []Series {
+	groups := make(map[string]*group)
+	var order []string
+	for _, series := range vector {
+		labels := groupLabels(agg, series.Labels)
+		key := (&Series{Labels: labels}).key()
+		g, ok := groups[key]
+		if !ok {
+			g = &group{labels: labels}
+			groups[key] = g
+			order = append(order, key)
+		}
+		g.series = append(g.series, series)
+	}
+	sort.Strings(order)
+	var result []Series
+	for _, key := range order {
+		g := groups[key]
+		if agg.Op == "topk" {
+			result = append(result, topk(g.series, int(agg.Param))...)
+			continue
+		}
+		result = append(result, Series{Labels: g.labels, Samples: []Sample{{Time: at, Value: reduce(agg.Op, g.series)}}})
+	}
+	return result
+}
+
+func groupLabels(agg *Aggregate, labels map[string]string) map[string]string {
+	result := make(map[string]string)
+	if agg.Without {
+		for k, v := range labels {
+			result[k] = v
+		}
+		for _, label := range agg.Labels {
+			delete(result, label)
+		}
+		return result
+	}
+	for _, label := range agg.Labels {
+		if v, ok := labels[label]; ok {
+			result[label] = v
+		}
+	}
+	return result
+}
+
+func reduce(op string, series []Series) float64 {
+	switch op {
+	case "sum", "avg":
+		var sum float64
+		for _, s := range series {
+			sum += s.Samples[0].Value
+		}
+		if op == "avg" {
+			return sum / float64(len(series))
+		}
+		return sum
+	case "min":
+		result := math.Inf(1)
+		for _, s := range series {
+			result = math.Min(result, s.Samples[0].Value)
+		}
+		return result
+	default:
+		result := math.Inf(-1)
+		for _, s := range series {
+			result = math.Max(result, s.Samples[0].Value)
+		}
+		return result
+	}
+}
+
+// topk k series with largest values, largest first
+func topk(series []Series, k int) []Series {
+	sorted := make([]Series, len(series))
+	copy(sorted, series)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Samples[0].Value > sorted[j].Samples[0].Value })
+	if len(sorted) > k {
+		sorted = sorted[:k]
+	}
+	return sorted
+}
@@ -0,0 +1,91 @@
+package query
+
+import (
+	"sync"
+	"time"
+)
+
+// History keep last samples of every metric of every tenant in fixed size ring buffers
+type History struct {
+	mutex    sync.RWMutex
+	capacity int
+	tenants  map[string]map[string]*ring
+}
+
+// NewHistory history keeping capacity samples per metric
+func NewHistory(capacity int) *History {
+	return &History{capacity: capacity, tenants: make(map[string]map[string]*ring)}
+}
+
+// Add append sample of metric, oldest sample is overwritten when buffer is full.
+// Sample older than the last one is ignored
+func (h *History) Add(tenant string, id string, sample Sample) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	metrics, ok := h.tenants[tenant]
+	if !ok {
+		metrics = make(map[string]*ring)
+		h.tenants[tenant] = metrics
+	}
+	r, ok := metrics[id]
+	if !ok {
+		r = &ring{samples: make([]Sample, h.capacity)}
+		metrics[id] = r
+	}
+	r.add(sample)
+}
+
+// Range samples of metric with time in (from, to], oldest first
+func (h *History) Range(tenant string, id string, from time.Time, to time.Time) []Sample {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	r, ok := h.tenants[tenant][id]
+	if !ok {
+		return nil
+	}
+	return r.between(from, to)
+}
+
+// Forget drop samples of metric
+func (h *History) Forget(tenant string, id string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	delete(h.tenants[tenant], id)
+}
+
+type ring struct {
+	samples []Sample
+	start   int
+	size    int
+}
+
+func (r *ring) add(sample Sample) {
+	if len(r.samples) == 0 {
+		return
+	}
+	if r.size > 0 && sample.Time.Before(r.at(r.size-1).Time) {
+		return
+	}
+	if r.size < len(r.samples) {
+		r.samples[(r.start+r.size)%len(r.samples)] = sample
+		r.size++
+		return
+	}
+	r.samples[r.start] = sample
+	r.start = (r.start + 1) % len(r.samples)
+}
+
+func (r *ring) at(i int) Sample {
+	return r.samples[(r.start+i)%len(r.samples)]
+}
+
+func (r *ring) between(from time.Time, to time.Time) []Sample {
+	var result []Sample
+	for i := 0; i < r.size; i++ {
+		sample := r.at(i)
+		if sample.Time.After(from) && !sample.Time.After(to) {
+			result = append(result, sample)
+		}
+	}
+	return result
+}
@@ -0,0 +1,128 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenDuration
+	tokenLeftParen
+	tokenRightParen
+	tokenLeftBrace
+	tokenRightBrace
+	tokenLeftBracket
+	tokenRightBracket
+	tokenComma
+	tokenEqual
+	tokenNotEqual
+	tokenRegexMatch
+	tokenRegexNotMatch
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	pos   int
+}
+
+// lex split query into tokens, identifiers may contain glob characters
+func lex(input string) ([]token, error) {
+	var tokens []token
+	runes := []rune(input)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case isIdentStart(r):
+			start := i
+			for i < len(runes) && isIdentPart(runes[i]) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, value: string(runes[start:i]), pos: start})
+		case unicode.IsDigit(r) || r == '.':
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			kind := tokenNumber
+			// duration is number followed by units, like 5m or 1h30m
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i])) {
+				kind = tokenDuration
+				i++
+			}
+			tokens = append(tokens, token{kind: kind, value: string(runes[start:i]), pos: start})
+		case r == '"' || r == '\'':
+			start := i
+			i++
+			var b strings.Builder
+			for ; i < len(runes) && runes[i] != r; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				b.WriteRune(runes[i])
+			}
+			if i == len(runes) {
+				return nil, fmt.Errorf("%w: unterminated string at %d", ErrInvalidQuery, start)
+			}
+			i++
+			tokens = append(tokens, token{kind: tokenString, value: b.String(), pos: start})
+		default:
+			kind, width := punctuation(runes[i:])
+			if width == 0 {
+				return nil, fmt.Errorf("%w: unexpected %q at %d", ErrInvalidQuery, r, i)
+			}
+			tokens = append(tokens, token{kind: kind, value: string(runes[i : i+width]), pos: i})
+			i += width
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(runes)}), nil
+}
+
+func punctuation(runes []rune) (tokenKind, int) {
+	if len(runes) > 1 {
+		switch string(runes[:2]) {
+		case "!=":
+			return tokenNotEqual, 2
+		case "=~":
+			return tokenRegexMatch, 2
+		case "!~":
+			return tokenRegexNotMatch, 2
+		}
+	}
+	switch runes[0] {
+	case '(':
+		return tokenLeftParen, 1
+	case ')':
+		return tokenRightParen, 1
+	case '{':
+		return tokenLeftBrace, 1
+	case '}':
+		return tokenRightBrace, 1
+	case '[':
+		return tokenLeftBracket, 1
+	case ']':
+		return tokenRightBracket, 1
+	case ',':
+		return tokenComma, 1
+	case '=':
+		return tokenEqual, 1
+	}
+	return tokenEOF, 0
+}
+
+func isIdentStart(r rune) bool {
+	return unicode.IsLetter(r) || r == '_' || r == ':' || r == '*' || r == '?'
+}
+
+func isIdentPart(r rune) bool {
+	return isIdentStart(r) || unicode.IsDigit(r) || r == '.'
+}
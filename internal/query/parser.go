@@ -0,0 +1,347 @@
+package query
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Expr parsed query expression
+type Expr interface {
+	String() string
+}
+
+type MatchOp string
+
+const (
+	MatchEqual    MatchOp = "="
+	MatchNotEqual MatchOp = "!="
+	MatchRegex    MatchOp = "=~"
+	MatchNotRegex MatchOp = "!~"
+)
+
+// Matcher condition on label value, regular expressions are anchored
+type Matcher struct {
+	Label string
+	Op    MatchOp
+	Value string
+	re    *regexp.Regexp
+}
+
+func (m *Matcher) matches(value string) bool {
+	switch m.Op {
+	case MatchEqual:
+		return value == m.Value
+	case MatchNotEqual:
+		return value != m.Value
+	case MatchRegex:
+		return m.re.MatchString(value)
+	default:
+		return !m.re.MatchString(value)
+	}
+}
+
+// Selector metrics whose name matches glob and labels match every matcher,
+// Range is set for range selectors
+type Selector struct {
+	Name     string
+	Matchers []Matcher
+	Range    time.Duration
+}
+
+func (s *Selector) String() string {
+	var b strings.Builder
+	b.WriteString(s.Name)
+	if len(s.Matchers) > 0 {
+		b.WriteByte('{')
+		for i, m := range s.Matchers {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			fmt.Fprintf(&b, "%s%s%q", m.Label, m.Op, m.Value)
+		}
+		b.WriteByte('}')
+	}
+	if s.Range > 0 {
+		fmt.Fprintf(&b, "[%s]", s.Range)
+	}
+	return b.String()
+}
+
+// Call range function applied to range selector
+type Call struct {
+	Func string
+	Arg  *Selector
+}
+
+func (c *Call) String() string {
+	return fmt.Sprintf("%s(%s)", c.Func, c.Arg)
+}
+
+// Aggregate aggregation of instant vector, Param is k of topk
+type Aggregate struct {
+	Op      string
+	Param   float64
+	Labels  []string
+	Without bool
+	Expr    Expr
+}
+
+func (a *Aggregate) String() string {
+	var b strings.Builder
+	b.WriteString(a.Op)
+	if len(a.Labels) > 0 {
+		if a.Without {
+			b.WriteString(" without (")
+		} else {
+			b.WriteString(" by (")
+		}
+		b.WriteString(strings.Join(a.Labels, ","))
+		b.WriteString(")")
+	}
+	b.WriteByte('(')
+	if a.Op == "topk" {
+		b.WriteString(strconv.FormatFloat(a.Param, 'f', -1, 64))
+		b.WriteString(", ")
+	}
+	b.WriteString(a.Expr.String())
+	b.WriteByte(')')
+	return b.String()
+}
+
+var (
+	functions    = map[string]struct{}{"rate": {}, "increase": {}, "delta": {}}
+	aggregations = map[string]struct{}{"sum": {}, "avg": {}, "min": {}, "max": {}, "topk": {}}
+)
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// Parse query into expression
+func Parse(input string) (Expr, error) {
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	expr, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, p.errorf(t, "unexpected %q", t.value)
+	}
+	return expr, nil
+}
+
+func (p *parser) parseExpr() (Expr, error) {
+	t := p.peek()
+	if t.kind != tokenIdent && t.kind != tokenLeftBrace {
+		return nil, p.errorf(t, "expected metric selector, function or aggregation")
+	}
+	// identifier followed by parenthesis or grouping is function or aggregation, otherwise metric name
+	if after := p.tokens[p.pos+1]; t.kind == tokenIdent && (after.kind == tokenLeftParen || isGrouping(after)) {
+		if _, ok := functions[t.value]; ok {
+			return p.parseCall()
+		}
+		if _, ok := aggregations[t.value]; ok {
+			return p.parseAggregate()
+		}
+		return nil, p.errorf(t, "unknown function %q", t.value)
+	}
+	return p.parseSelector()
+}
+
+func (p *parser) parseCall() (Expr, error) {
+	name := p.next().value
+	if _, err := p.expect(tokenLeftParen); err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	arg, err := p.parseSelector()
+	if err != nil {
+		return nil, err
+	}
+	if arg.Range == 0 {
+		return nil, p.errorf(t, "%s expects range selector like %s[5m]", name, arg)
+	}
+	if _, err = p.expect(tokenRightParen); err != nil {
+		return nil, err
+	}
+	return &Call{Func: name, Arg: arg}, nil
+}
+
+func (p *parser) parseAggregate() (Expr, error) {
+	agg := &Aggregate{Op: p.next().value}
+	if isGrouping(p.peek()) {
+		if err := p.parseGrouping(agg); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := p.expect(tokenLeftParen); err != nil {
+		return nil, err
+	}
+	if agg.Op == "topk" {
+		t, err := p.expect(tokenNumber)
+		if err != nil {
+			return nil, err
+		}
+		agg.Param, err = strconv.ParseFloat(t.value, 64)
+		if err != nil || agg.Param < 1 {
+			return nil, p.errorf(t, "topk expects positive number")
+		}
+		if _, err = p.expect(tokenComma); err != nil {
+			return nil, err
+		}
+	}
+	t := p.peek()
+	expr, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if s, ok := expr.(*Selector); ok && s.Range > 0 {
+		return nil, p.errorf(t, "%s expects instant vector, got range selector", agg.Op)
+	}
+	agg.Expr = expr
+	if _, err = p.expect(tokenRightParen); err != nil {
+		return nil, err
+	}
+	if len(agg.Labels) == 0 && isGrouping(p.peek()) {
+		if err = p.parseGrouping(agg); err != nil {
+			return nil, err
+		}
+	}
+	return agg, nil
+}
+
+func (p *parser) parseGrouping(agg *Aggregate) error {
+	agg.Without = p.next().value == "without"
+	if _, err := p.expect(tokenLeftParen); err != nil {
+		return err
+	}
+	for p.peek().kind != tokenRightParen {
+		t, err := p.expect(tokenIdent)
+		if err != nil {
+			return err
+		}
+		agg.Labels = append(agg.Labels, t.value)
+		if p.peek().kind == tokenComma {
+			p.next()
+		}
+	}
+	p.next()
+	return nil
+}
+
+func (p *parser) parseSelector() (*Selector, error) {
+	selector := &Selector{}
+	if t := p.peek(); t.kind == tokenIdent {
+		p.next()
+		if _, err := path.Match(t.value, ""); err != nil {
+			return nil, p.errorf(t, "bad name pattern %q", t.value)
+		}
+		selector.Name = t.value
+	}
+	if p.peek().kind == tokenLeftBrace {
+		p.next()
+		for p.peek().kind != tokenRightBrace {
+			matcher, err := p.parseMatcher()
+			if err != nil {
+				return nil, err
+			}
+			selector.Matchers = append(selector.Matchers, matcher)
+			if p.peek().kind == tokenComma {
+				p.next()
+			} else if p.peek().kind != tokenRightBrace {
+				return nil, p.errorf(p.peek(), "expected ',' or '}'")
+			}
+		}
+		p.next()
+	}
+	if selector.Name == "" && len(selector.Matchers) == 0 {
+		return nil, p.errorf(p.peek(), "empty selector")
+	}
+	if p.peek().kind == tokenLeftBracket {
+		p.next()
+		t, err := p.expect(tokenDuration)
+		if err != nil {
+			return nil, err
+		}
+		selector.Range, err = time.ParseDuration(t.value)
+		if err != nil || selector.Range <= 0 {
+			return nil, p.errorf(t, "bad duration %q", t.value)
+		}
+		if _, err = p.expect(tokenRightBracket); err != nil {
+			return nil, err
+		}
+	}
+	return selector, nil
+}
+
+func (p *parser) parseMatcher() (Matcher, error) {
+	label, err := p.expect(tokenIdent)
+	if err != nil {
+		return Matcher{}, err
+	}
+	t := p.next()
+	var op MatchOp
+	switch t.kind {
+	case tokenEqual:
+		op = MatchEqual
+	case tokenNotEqual:
+		op = MatchNotEqual
+	case tokenRegexMatch:
+		op = MatchRegex
+	case tokenRegexNotMatch:
+		op = MatchNotRegex
+	default:
+		return Matcher{}, p.errorf(t, "expected label match operator")
+	}
+	value, err := p.expect(tokenString)
+	if err != nil {
+		return Matcher{}, err
+	}
+	matcher := Matcher{Label: label.value, Op: op, Value: value.value}
+	if op == MatchRegex || op == MatchNotRegex {
+		if matcher.re, err = regexp.Compile("^(?:" + value.value + ")$"); err != nil {
+			return Matcher{}, p.errorf(value, "bad regular expression: %v", err)
+		}
+	}
+	return matcher, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) expect(kind tokenKind) (token, error) {
+	t := p.next()
+	if t.kind != kind {
+		if t.kind == tokenEOF {
+			return t, p.errorf(t, "unexpected end of query")
+		}
+		return t, p.errorf(t, "unexpected %q", t.value)
+	}
+	return t, nil
+}
+
+func (p *parser) errorf(t token, format string, args ...any) error {
+	return fmt.Errorf("%w: %s at %d", ErrInvalidQuery, fmt.Sprintf(format, args...), t.pos)
+}
+
+func isGrouping(t token) bool {
+	return t.kind == tokenIdent && (t.value == "by" || t.value == "without")
+}
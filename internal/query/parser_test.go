@@ -0,0 +1,58 @@
+package query
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected string
+	}{
+		{"CPUutilization*", "CPUutilization*"},
+		{`http{code=~"5..", method!='GET'}`, `http{code=~"5..",method!="GET"}`},
+		{"PollCount[5m]", "PollCount[5m0s]"},
+		{"rate(PollCount[1m30s])", "rate(PollCount[1m30s])"},
+		{"sum by (host) (rate(req[5m]))", "sum by (host)(rate(req[5m0s]))"},
+		{"max(Heap*) without (instance)", "max without (instance)(Heap*)"},
+		{"topk(3, CPUutilization*)", "topk(3, CPUutilization*)"},
+		{`{__name__="PollCount"}`, `{__name__="PollCount"}`},
+	}
+	for _, c := range cases {
+		t.Run(c.input, func(t *testing.T) {
+			expr, err := Parse(c.input)
+			require.NoError(t, err)
+			assert.Equal(t, c.expected, expr.String())
+		})
+	}
+	selector, err := Parse("PollCount[1h]")
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour, selector.(*Selector).Range)
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, input := range []string{
+		"",
+		"rate(PollCount)",
+		"sum(PollCount[5m])",
+		"median(PollCount)",
+		"topk(PollCount)",
+		"topk(0, PollCount)",
+		`PollCount{code=~"("}`,
+		`PollCount{code="5xx"`,
+		`PollCount{code="5xx}`,
+		"PollCount[5x]",
+		"PollCount )",
+		"{}",
+		"Poll#Count",
+	} {
+		t.Run(input, func(t *testing.T) {
+			_, err := Parse(input)
+			assert.ErrorIs(t, err, ErrInvalidQuery)
+		})
+	}
+}
@@ -0,0 +1,71 @@
+// Package query small PromQL-like language over stored metrics.
+//
+// Selector picks metrics by name glob and label matchers, labels come from metric id in
+// name{label="value"} form:
+//
+//	CPUutilization*
+//	http_requests{method="GET", code=~"5.."}
+//	PollCount[5m]
+//
+// Range selector is accepted by rate, increase and delta, instant vectors are aggregated
+// by sum, avg, min, max and topk with optional by/without clause:
+//
+//	sum by (host) (rate(http_requests[5m]))
+//	topk(3, CPUutilization*)
+package query
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// NameLabel label holding metric name in matchers
+const NameLabel = "__name__"
+
+var ErrInvalidQuery = errors.New("invalid query")
+
+type ValueType string
+
+const (
+	VectorType ValueType = "vector"
+	MatrixType ValueType = "matrix"
+)
+
+// Sample value of metric at moment
+type Sample struct {
+	Time  time.Time
+	Value float64
+}
+
+// Series metric identity with samples, instant vector series have exactly one sample
+type Series struct {
+	Name    string
+	Labels  map[string]string
+	Samples []Sample
+}
+
+// key identity of series used to group and merge them
+func (s *Series) key() string {
+	var b strings.Builder
+	b.WriteString(s.Name)
+	keys := make([]string, 0, len(s.Labels))
+	for k := range s.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		b.WriteByte(0)
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(s.Labels[k])
+	}
+	return b.String()
+}
+
+// Result evaluated query
+type Result struct {
+	Type   ValueType
+	Series []Series
+}
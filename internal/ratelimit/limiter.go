@@ -0,0 +1,93 @@
+// Package ratelimit token buckets keyed by client
+package ratelimit
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxKeys number of buckets after which full buckets are dropped, when there are too few of them
+// buckets idle for longest are dropped as well, so rotating keys can't grow the map without bound
+const maxKeys = 10000
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter token bucket per key, every bucket refills with rate tokens per second up to burst
+type Limiter struct {
+	rate  float64
+	burst float64
+
+	mutex   sync.Mutex
+	buckets map[string]*bucket
+	maxKeys int
+	now     func() time.Time
+}
+
+// NewLimiter create limiter, burst defaults to rate when not positive
+func NewLimiter(rate int, burst int) *Limiter {
+	if burst <= 0 {
+		burst = rate
+	}
+	return &Limiter{
+		rate:    float64(rate),
+		burst:   float64(burst),
+		buckets: make(map[string]*bucket),
+		maxKeys: maxKeys,
+		now:     time.Now,
+	}
+}
+
+// Take n tokens from bucket of key. When bucket has too few tokens nothing is taken and
+// time after which request will fit is returned. Batches larger than burst are let through
+// from full bucket and leave it in debt, so they are slowed down but never rejected forever
+func (l *Limiter) Take(key string, n int) (bool, time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	now := l.now()
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= l.maxKeys {
+			l.dropFull(now)
+		}
+		if len(l.buckets) >= l.maxKeys {
+			l.dropIdle()
+		}
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	need := math.Min(float64(n), l.burst)
+	if b.tokens < need {
+		wait := (need - b.tokens) / l.rate
+		return false, time.Duration(wait * float64(time.Second))
+	}
+	b.tokens -= float64(n)
+	return true, 0
+}
+
+// dropFull remove buckets which refilled completely, they are equal to new ones
+func (l *Limiter) dropFull(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// dropIdle remove quarter of buckets which were used longest ago, their clients start with full bucket again
+func (l *Limiter) dropIdle() {
+	keys := make([]string, 0, len(l.buckets))
+	for key := range l.buckets {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return l.buckets[keys[i]].last.Before(l.buckets[keys[j]].last) })
+	for _, key := range keys[:len(keys)/4+1] {
+		delete(l.buckets, key)
+	}
+}
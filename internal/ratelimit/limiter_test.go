@@ -0,0 +1,53 @@
+package ratelimit
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiterTake(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	limiter := NewLimiter(10, 20)
+	limiter.now = func() time.Time { return now }
+
+	ok, _ := limiter.Take("a", 20)
+	assert.True(t, ok)
+	ok, wait := limiter.Take("a", 5)
+	assert.False(t, ok)
+	assert.Equal(t, 500*time.Millisecond, wait)
+	ok, _ = limiter.Take("b", 5)
+	assert.True(t, ok, "keys have separate buckets")
+
+	now = now.Add(500 * time.Millisecond)
+	ok, _ = limiter.Take("a", 5)
+	assert.True(t, ok)
+}
+
+func TestLimiterBatchLargerThanBurst(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	limiter := NewLimiter(10, 0)
+	limiter.now = func() time.Time { return now }
+
+	ok, _ := limiter.Take("a", 30)
+	assert.True(t, ok, "full bucket lets large batch through")
+	ok, wait := limiter.Take("a", 1)
+	assert.False(t, ok)
+	assert.Equal(t, 2100*time.Millisecond, wait, "debt is paid off before next request")
+}
+
+func TestLimiterBoundsKeys(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	limiter := NewLimiter(1, 10)
+	limiter.maxKeys = 8
+	limiter.now = func() time.Time { return now }
+	for i := 0; i < 100; i++ {
+		now = now.Add(time.Millisecond)
+		ok, _ := limiter.Take(strconv.Itoa(i), 10)
+		assert.True(t, ok)
+	}
+	assert.LessOrEqual(t, len(limiter.buckets), 8, "drained buckets of rotating keys are evicted")
+	assert.Contains(t, limiter.buckets, "99", "recent bucket is kept")
+}
@@ -0,0 +1,187 @@
+// Package scrape pull metrics from Prometheus text endpoints
+package scrape
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	TypeCounter   = "counter"
+	TypeGauge     = "gauge"
+	TypeHistogram = "histogram"
+	TypeSummary   = "summary"
+	TypeUntyped   = "untyped"
+)
+
+var ErrInvalidLine = errors.New("invalid exposition line")
+
+// Sample single value of exposition format
+type Sample struct {
+	Name   string
+	Labels map[string]string
+	Value  float64
+	Type   string
+	// Family name declared by TYPE line, for histogram and summary it differs from Name
+	Family string
+}
+
+// Parse read Prometheus text exposition format
+func Parse(r io.Reader) ([]Sample, error) {
+	types := make(map[string]string)
+	var samples []Sample
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			fields := strings.Fields(line[1:])
+			if len(fields) >= 3 && fields[0] == "TYPE" {
+				types[fields[1]] = strings.ToLower(fields[2])
+			}
+			continue
+		}
+		sample, err := parseSample(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		sample.Family, sample.Type = resolveType(sample.Name, types)
+		samples = append(samples, sample)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return samples, nil
+}
+
+func resolveType(name string, types map[string]string) (string, string) {
+	if t, ok := types[name]; ok {
+		return name, t
+	}
+	for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+		family, ok := strings.CutSuffix(name, suffix)
+		if !ok {
+			continue
+		}
+		if t, ok := types[family]; ok && (t == TypeHistogram || t == TypeSummary) {
+			return family, t
+		}
+	}
+	return name, TypeUntyped
+}
+
+func parseSample(line string) (Sample, error) {
+	sample := Sample{Labels: make(map[string]string)}
+	i := 0
+	for i < len(line) && isNameChar(line[i], i == 0) {
+		i++
+	}
+	if i == 0 {
+		return Sample{}, ErrInvalidLine
+	}
+	sample.Name = line[:i]
+	rest := line[i:]
+	if strings.HasPrefix(rest, "{") {
+		n, err := parseLabels(rest, sample.Labels)
+		if err != nil {
+			return Sample{}, err
+		}
+		rest = rest[n:]
+	}
+	fields := strings.Fields(rest)
+	if len(fields) == 0 || len(fields) > 2 {
+		return Sample{}, ErrInvalidLine
+	}
+	value, err := parseValue(fields[0])
+	if err != nil {
+		return Sample{}, err
+	}
+	sample.Value = value
+	return sample, nil
+}
+
+// parseLabels parse {a="b",c="d"} block and return consumed length
+func parseLabels(s string, labels map[string]string) (int, error) {
+	i := 1
+	for {
+		for i < len(s) && (s[i] == ' ' || s[i] == ',') {
+			i++
+		}
+		if i >= len(s) {
+			return 0, ErrInvalidLine
+		}
+		if s[i] == '}' {
+			return i + 1, nil
+		}
+		start := i
+		for i < len(s) && isNameChar(s[i], i == start) {
+			i++
+		}
+		name := s[start:i]
+		if name == "" {
+			return 0, ErrInvalidLine
+		}
+		for i < len(s) && s[i] == ' ' {
+			i++
+		}
+		if i+1 >= len(s) || s[i] != '=' || s[i+1] != '"' {
+			return 0, ErrInvalidLine
+		}
+		i += 2
+		var value strings.Builder
+		closed := false
+		for i < len(s) {
+			c := s[i]
+			if c == '\\' && i+1 < len(s) {
+				switch s[i+1] {
+				case 'n':
+					value.WriteByte('\n')
+				default:
+					value.WriteByte(s[i+1])
+				}
+				i += 2
+				continue
+			}
+			if c == '"' {
+				closed = true
+				i++
+				break
+			}
+			value.WriteByte(c)
+			i++
+		}
+		if !closed {
+			return 0, ErrInvalidLine
+		}
+		labels[name] = value.String()
+	}
+}
+
+func parseValue(s string) (float64, error) {
+	switch s {
+	case "+Inf", "Inf":
+		return math.Inf(1), nil
+	case "-Inf":
+		return math.Inf(-1), nil
+	case "NaN":
+		return math.NaN(), nil
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+func isNameChar(c byte, first bool) bool {
+	if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == ':' {
+		return true
+	}
+	return !first && c >= '0' && c <= '9'
+}
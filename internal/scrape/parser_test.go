@@ -0,0 +1,77 @@
+package scrape
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const exposition = `# HELP http_requests_total Total requests.
+# TYPE http_requests_total counter
+http_requests_total{method="post",code="200"} 1027 1395066363000
+http_requests_total{method="get",code="400"} 3
+
+# TYPE temperature gauge
+temperature 21.5
+# TYPE request_duration_seconds histogram
+request_duration_seconds_bucket{le="0.1"} 5
+request_duration_seconds_bucket{le="+Inf"} 7
+request_duration_seconds_sum 1.25
+request_duration_seconds_count 7
+escaped{path="C:\\dir\"x\""} NaN
+`
+
+func TestParse(t *testing.T) {
+	samples, err := Parse(strings.NewReader(exposition))
+	require.NoError(t, err)
+	require.Len(t, samples, 8)
+
+	assert.Equal(t, "http_requests_total", samples[0].Name)
+	assert.Equal(t, TypeCounter, samples[0].Type)
+	assert.Equal(t, map[string]string{"method": "post", "code": "200"}, samples[0].Labels)
+	assert.Equal(t, float64(1027), samples[0].Value)
+
+	assert.Equal(t, TypeGauge, samples[2].Type)
+	assert.Equal(t, 21.5, samples[2].Value)
+
+	assert.Equal(t, TypeHistogram, samples[3].Type)
+	assert.Equal(t, "request_duration_seconds", samples[3].Family)
+	assert.Equal(t, "+Inf", samples[4].Labels["le"])
+	assert.Equal(t, TypeHistogram, samples[6].Type)
+
+	assert.Equal(t, TypeUntyped, samples[7].Type)
+	assert.Equal(t, `C:\dir"x"`, samples[7].Labels["path"])
+	assert.True(t, math.IsNaN(samples[7].Value))
+}
+
+func TestParseInvalidLine(t *testing.T) {
+	_, err := Parse(strings.NewReader(`metric{label="unclosed} 1`))
+	assert.ErrorIs(t, err, ErrInvalidLine)
+
+	_, err = Parse(strings.NewReader(`metric not_a_number`))
+	assert.Error(t, err)
+}
+
+func TestRelabel(t *testing.T) {
+	rules, err := compileRelabel([]RelabelConfig{
+		{SourceLabels: []string{"code"}, Regex: "4..", Action: ActionDrop},
+		{SourceLabels: []string{NameLabel}, Regex: "http_(.*)", TargetLabel: NameLabel, Replacement: "app_$1"},
+		{Regex: "code", Action: ActionLabelDrop},
+	})
+	require.NoError(t, err)
+
+	labels := map[string]string{NameLabel: "http_requests_total", "code": "200", "method": "get"}
+	assert.True(t, relabel(labels, rules))
+	assert.Equal(t, map[string]string{NameLabel: "app_requests_total", "method": "get"}, labels)
+
+	labels = map[string]string{NameLabel: "http_requests_total", "code": "404"}
+	assert.False(t, relabel(labels, rules))
+}
+
+func TestCompileRelabelUnknownAction(t *testing.T) {
+	_, err := compileRelabel([]RelabelConfig{{Action: "explode"}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,110 @@
+package scrape
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const NameLabel = "__name__"
+
+const (
+	ActionReplace   = "replace"
+	ActionKeep      = "keep"
+	ActionDrop      = "drop"
+	ActionLabelDrop = "labeldrop"
+	ActionLabelKeep = "labelkeep"
+)
+
+// RelabelConfig rewrite rule applied to every scraped sample, __name__ holds metric name
+type RelabelConfig struct {
+	SourceLabels []string `json:"source_labels"`
+	Separator    string   `json:"separator"`
+	Regex        string   `json:"regex"`
+	TargetLabel  string   `json:"target_label"`
+	Replacement  string   `json:"replacement"`
+	Action       string   `json:"action"`
+}
+
+type relabelRule struct {
+	RelabelConfig
+	regex *regexp.Regexp
+}
+
+func compileRelabel(configs []RelabelConfig) ([]relabelRule, error) {
+	rules := make([]relabelRule, len(configs))
+	for i, conf := range configs {
+		if conf.Action == "" {
+			conf.Action = ActionReplace
+		}
+		if conf.Separator == "" {
+			conf.Separator = ";"
+		}
+		if conf.Regex == "" {
+			conf.Regex = "(.*)"
+		}
+		if conf.Replacement == "" {
+			conf.Replacement = "$1"
+		}
+		switch conf.Action {
+		case ActionReplace:
+			if conf.TargetLabel == "" {
+				return nil, fmt.Errorf("relabel rule %d: target_label is required for replace", i)
+			}
+		case ActionKeep, ActionDrop, ActionLabelDrop, ActionLabelKeep:
+		default:
+			return nil, fmt.Errorf("relabel rule %d: unknown action %q", i, conf.Action)
+		}
+		regex, err := regexp.Compile("^(?:" + conf.Regex + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("relabel rule %d: %w", i, err)
+		}
+		rules[i] = relabelRule{RelabelConfig: conf, regex: regex}
+	}
+	return rules, nil
+}
+
+// relabel apply rules to labels, returns false when sample must be dropped
+func relabel(labels map[string]string, rules []relabelRule) bool {
+	for _, rule := range rules {
+		values := make([]string, len(rule.SourceLabels))
+		for i, name := range rule.SourceLabels {
+			values[i] = labels[name]
+		}
+		value := strings.Join(values, rule.Separator)
+		switch rule.Action {
+		case ActionKeep:
+			if !rule.regex.MatchString(value) {
+				return false
+			}
+		case ActionDrop:
+			if rule.regex.MatchString(value) {
+				return false
+			}
+		case ActionReplace:
+			match := rule.regex.FindStringSubmatchIndex(value)
+			if match == nil {
+				continue
+			}
+			result := rule.regex.ExpandString(nil, rule.Replacement, value, match)
+			if len(result) == 0 {
+				delete(labels, rule.TargetLabel)
+				continue
+			}
+			labels[rule.TargetLabel] = string(result)
+		case ActionLabelDrop:
+			for name := range labels {
+				if name != NameLabel && rule.regex.MatchString(name) {
+					delete(labels, name)
+				}
+			}
+		case ActionLabelKeep:
+			for name := range labels {
+				if name != NameLabel && !rule.regex.MatchString(name) {
+					delete(labels, name)
+				}
+			}
+		}
+	}
+	return labels[NameLabel] != ""
+}
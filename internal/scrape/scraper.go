@@ -0,0 +1,239 @@
+package scrape
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/DimKa163/go-metrics/internal/client"
+	"github.com/DimKa163/go-metrics/internal/models"
+)
+
+const (
+	defaultInterval = 15
+	defaultTimeout  = 10
+)
+
+// Target Prometheus endpoint scraped by agent
+type Target struct {
+	Name     string          `json:"name"`
+	URL      string          `json:"url"`
+	Interval int             `json:"interval"`
+	Timeout  int             `json:"timeout"`
+	Include  []string        `json:"include"`
+	Exclude  []string        `json:"exclude"`
+	Relabel  []RelabelConfig `json:"relabel"`
+}
+
+// Scraper periodically pull target and forward samples to keeper
+type Scraper struct {
+	target  Target
+	client  client.MetricClient
+	http    *http.Client
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+	rules   []relabelRule
+	last    map[string]series
+}
+
+// series last cumulative value of counter and fraction of its increments not sent yet,
+// so fractional counters don't drift while keeper counters are integer
+type series struct {
+	value     float64
+	remainder float64
+}
+
+func NewScraper(target Target, metricClient client.MetricClient) (*Scraper, error) {
+	if target.URL == "" {
+		return nil, fmt.Errorf("scrape target %q: url is required", target.Name)
+	}
+	if target.Interval <= 0 {
+		target.Interval = defaultInterval
+	}
+	if target.Timeout <= 0 {
+		target.Timeout = defaultTimeout
+	}
+	include, err := compileFilters(target.Include)
+	if err != nil {
+		return nil, fmt.Errorf("scrape target %q: %w", target.Name, err)
+	}
+	exclude, err := compileFilters(target.Exclude)
+	if err != nil {
+		return nil, fmt.Errorf("scrape target %q: %w", target.Name, err)
+	}
+	rules, err := compileRelabel(target.Relabel)
+	if err != nil {
+		return nil, fmt.Errorf("scrape target %q: %w", target.Name, err)
+	}
+	return &Scraper{
+		target:  target,
+		client:  metricClient,
+		http:    &http.Client{Timeout: time.Duration(target.Timeout) * time.Second},
+		include: include,
+		exclude: exclude,
+		rules:   rules,
+		last:    make(map[string]series),
+	}, nil
+}
+
+// Run scrape target on interval until context cancelled
+func (s *Scraper) Run(ctx context.Context) error {
+	ticker := time.NewTicker(time.Duration(s.target.Interval) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			metrics, err := s.Scrape(ctx)
+			if err != nil {
+				fmt.Printf("Error scraping %s: %v\n", s.target.URL, err)
+				continue
+			}
+			if len(metrics) == 0 {
+				continue
+			}
+			if err = s.client.BatchUpdate(metrics); err != nil {
+				fmt.Printf("Error sending scraped metrics: %v\n", err)
+			}
+		}
+	}
+}
+
+// Scrape pull target once and convert samples to metrics
+func (s *Scraper) Scrape(ctx context.Context) ([]*models.Metric, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.target.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/plain;version=0.0.4")
+	res, err := s.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", res.StatusCode)
+	}
+	samples, err := Parse(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	return s.convert(samples), nil
+}
+
+func (s *Scraper) convert(samples []Sample) []*models.Metric {
+	var metrics []*models.Metric
+	var skipped []string
+	seen := make(map[string]struct{}, len(s.last))
+	for _, sample := range samples {
+		if math.IsNaN(sample.Value) || math.IsInf(sample.Value, 0) {
+			continue
+		}
+		if !s.accept(sample.Name) {
+			continue
+		}
+		labels := make(map[string]string, len(sample.Labels)+1)
+		for k, v := range sample.Labels {
+			labels[k] = v
+		}
+		labels[NameLabel] = sample.Name
+		if !relabel(labels, s.rules) {
+			continue
+		}
+		name := labels[NameLabel]
+		delete(labels, NameLabel)
+		id := models.ComposeID(name, labels)
+		if len(id) > models.MaxIDLength {
+			// keeper rejects whole batch because of one such series
+			skipped = append(skipped, id)
+			continue
+		}
+		if isCumulative(sample) {
+			seen[id] = struct{}{}
+			if delta, ok := s.delta(id, sample.Value); ok {
+				metrics = append(metrics, models.CreateCounter(id, delta))
+			}
+			continue
+		}
+		metrics = append(metrics, models.CreateGauge(id, sample.Value))
+	}
+	if len(skipped) > 0 {
+		fmt.Printf("Skipped %d series of %s with id longer than %d, first is %s\n",
+			len(skipped), s.target.URL, models.MaxIDLength, skipped[0])
+	}
+	// series gone from target start over with new baseline if they come back
+	for id := range s.last {
+		if _, ok := seen[id]; !ok {
+			delete(s.last, id)
+		}
+	}
+	return metrics
+}
+
+// delta turn cumulative value into counter increment, first observation only sets baseline.
+// Increment is rounded and rounding error is carried to next increment of the series
+func (s *Scraper) delta(id string, value float64) (int64, bool) {
+	prev, ok := s.last[id]
+	if !ok {
+		s.last[id] = series{value: value}
+		return 0, false
+	}
+	increment := value - prev.value
+	if value < prev.value {
+		increment = value
+	}
+	increment += prev.remainder
+	rounded := math.Round(increment)
+	s.last[id] = series{value: value, remainder: increment - rounded}
+	return int64(rounded), true
+}
+
+func (s *Scraper) accept(name string) bool {
+	if len(s.include) > 0 {
+		matched := false
+		for _, re := range s.include {
+			if re.MatchString(name) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	for _, re := range s.exclude {
+		if re.MatchString(name) {
+			return false
+		}
+	}
+	return true
+}
+
+func isCumulative(sample Sample) bool {
+	switch sample.Type {
+	case TypeCounter:
+		return true
+	case TypeHistogram:
+		return strings.HasSuffix(sample.Name, "_bucket") || strings.HasSuffix(sample.Name, "_count")
+	case TypeSummary:
+		return strings.HasSuffix(sample.Name, "_count")
+	}
+	return false
+}
+
+func compileFilters(patterns []string) ([]*regexp.Regexp, error) {
+	result := make([]*regexp.Regexp, len(patterns))
+	for i, pattern := range patterns {
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return nil, err
+		}
+		result[i] = re
+	}
+	return result, nil
+}
@@ -0,0 +1,115 @@
+package scrape
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DimKa163/go-metrics/internal/mocks"
+	"github.com/DimKa163/go-metrics/internal/models"
+)
+
+func TestScrapeConvertsSamples(t *testing.T) {
+	total := "10"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("# TYPE jobs_total counter\njobs_total " + total + "\n" +
+			"# TYPE queue gauge\nqueue{shard=\"a\"} 4\n" +
+			"# TYPE go_goroutines gauge\ngo_goroutines 12\n"))
+	}))
+	defer server.Close()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sut, err := NewScraper(Target{URL: server.URL, Exclude: []string{"go_.*"}}, mocks.NewMockMetricClient(ctrl))
+	require.NoError(t, err)
+
+	metrics, err := sut.Scrape(context.Background())
+	require.NoError(t, err)
+	require.Len(t, metrics, 1, "first scrape only sets counter baseline")
+	assert.Equal(t, `queue{shard="a"}`, metrics[0].ID)
+	assert.Equal(t, models.GaugeType, metrics[0].Type)
+	assert.Equal(t, float64(4), *metrics[0].Value)
+
+	total = "25"
+	metrics, err = sut.Scrape(context.Background())
+	require.NoError(t, err)
+	require.Len(t, metrics, 2)
+	assert.Equal(t, "jobs_total", metrics[0].ID)
+	assert.Equal(t, int64(15), *metrics[0].Delta)
+
+	total = "5"
+	metrics, err = sut.Scrape(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), *metrics[0].Delta, "counter reset should count from zero")
+}
+
+func TestScrapeFractionalCounter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sut, err := NewScraper(Target{URL: "http://localhost"}, mocks.NewMockMetricClient(ctrl))
+	require.NoError(t, err)
+
+	var sent int64
+	for i := 0; i <= 10; i++ {
+		metrics := sut.convert([]Sample{{Name: "cpu_seconds_total", Type: TypeCounter, Value: float64(i) * 0.4}})
+		for _, metric := range metrics {
+			sent += *metric.Delta
+		}
+	}
+	assert.Equal(t, int64(4), sent, "rounding error must not accumulate")
+}
+
+func TestScrapeForgetsMissingSeries(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sut, err := NewScraper(Target{URL: "http://localhost"}, mocks.NewMockMetricClient(ctrl))
+	require.NoError(t, err)
+
+	sut.convert([]Sample{
+		{Name: "a_total", Type: TypeCounter, Value: 1},
+		{Name: "b_total", Type: TypeCounter, Value: 1},
+	})
+	sut.convert([]Sample{{Name: "a_total", Type: TypeCounter, Value: 2}})
+	assert.Len(t, sut.last, 1)
+	assert.Contains(t, sut.last, "a_total")
+}
+
+func TestScrapeSkipsLongIDs(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sut, err := NewScraper(Target{URL: "http://localhost"}, mocks.NewMockMetricClient(ctrl))
+	require.NoError(t, err)
+
+	metrics := sut.convert([]Sample{
+		{Name: "queue", Type: TypeGauge, Labels: map[string]string{"path": strings.Repeat("a", models.MaxIDLength)}, Value: 1},
+		{Name: "queue", Type: TypeGauge, Labels: map[string]string{"path": "/"}, Value: 2},
+	})
+	require.Len(t, metrics, 1, "series with too long id is skipped instead of failing batch")
+	assert.Equal(t, `queue{path="/"}`, metrics[0].ID)
+}
+
+func TestScrapeUnexpectedStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sut, err := NewScraper(Target{URL: server.URL}, mocks.NewMockMetricClient(ctrl))
+	require.NoError(t, err)
+
+	_, err = sut.Scrape(context.Background())
+	assert.Error(t, err)
+}
+
+func TestNewScraperRequiresURL(t *testing.T) {
+	_, err := NewScraper(Target{Name: "empty"}, nil)
+	assert.Error(t, err)
+}
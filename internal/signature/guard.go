@@ -0,0 +1,119 @@
+package signature
+
+import (
+	"container/list"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultSkew          = 5 * time.Minute
+	DefaultNonceCapacity = 100000
+
+	minNonceLength = 16
+	maxNonceLength = 128
+)
+
+var (
+	ErrInvalidTimestamp = errors.New("invalid signature timestamp")
+	ErrStaleTimestamp   = errors.New("signature timestamp is outside of allowed skew")
+	ErrInvalidNonce     = errors.New("invalid signature nonce")
+	ErrReplayed         = errors.New("request was already accepted")
+)
+
+type nonceEntry struct {
+	nonce     string
+	timestamp int64
+}
+
+// ReplayGuard reject requests whose timestamp is outside skew window or whose nonce was seen within it.
+// Nonces are kept in bounded cache, when it overflows oldest nonce is evicted and requests signed
+// not later than it are rejected, so eviction never opens a replay window.
+type ReplayGuard struct {
+	skew     time.Duration
+	capacity int
+
+	mutex sync.Mutex
+	order *list.List
+	seen  map[string]*list.Element
+	floor int64
+	now   func() time.Time
+}
+
+// NewReplayGuard create guard, defaults are used for non-positive skew and capacity
+func NewReplayGuard(skew time.Duration, capacity int) *ReplayGuard {
+	if skew <= 0 {
+		skew = DefaultSkew
+	}
+	if capacity <= 0 {
+		capacity = DefaultNonceCapacity
+	}
+	return &ReplayGuard{
+		skew:     skew,
+		capacity: capacity,
+		order:    list.New(),
+		seen:     make(map[string]*list.Element),
+		now:      time.Now,
+	}
+}
+
+// Check validate timestamp and remember nonce
+func (g *ReplayGuard) Check(timestamp string, nonce string) error {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidTimestamp
+	}
+	if len(nonce) < minNonceLength || len(nonce) > maxNonceLength {
+		return ErrInvalidNonce
+	}
+	now := g.now()
+	if diff := now.Sub(time.Unix(ts, 0)); diff > g.skew || diff < -g.skew {
+		return ErrStaleTimestamp
+	}
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.expire(now.Add(-g.skew).Unix())
+	if ts <= g.floor {
+		return ErrReplayed
+	}
+	if _, ok := g.seen[nonce]; ok {
+		return ErrReplayed
+	}
+	for g.order.Len() >= g.capacity {
+		oldest := g.order.Remove(g.order.Front()).(nonceEntry)
+		delete(g.seen, oldest.nonce)
+		if oldest.timestamp > g.floor {
+			g.floor = oldest.timestamp
+		}
+	}
+	if ts <= g.floor {
+		return ErrReplayed
+	}
+	g.seen[nonce] = g.order.PushBack(nonceEntry{nonce: nonce, timestamp: ts})
+	return nil
+}
+
+// Release forget nonce of request which was not applied, so it can be retried
+func (g *ReplayGuard) Release(nonce string) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if element, ok := g.seen[nonce]; ok {
+		g.order.Remove(element)
+		delete(g.seen, nonce)
+	}
+}
+
+// expire drop nonces signed before deadline from the front of insertion order,
+// their requests fail skew check anyway
+func (g *ReplayGuard) expire(deadline int64) {
+	for element := g.order.Front(); element != nil; element = g.order.Front() {
+		entry := element.Value.(nonceEntry)
+		if entry.timestamp >= deadline {
+			return
+		}
+		g.order.Remove(element)
+		delete(g.seen, entry.nonce)
+	}
+}
@@ -0,0 +1,54 @@
+package signature
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplayGuard(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	guard := NewReplayGuard(time.Minute, 10)
+	guard.now = func() time.Time { return now }
+	ts := Timestamp(now)
+
+	assert.NoError(t, guard.Check(ts, "nonce-0000000001"))
+	assert.ErrorIs(t, guard.Check(ts, "nonce-0000000001"), ErrReplayed)
+	assert.NoError(t, guard.Check(ts, "nonce-0000000002"))
+
+	assert.ErrorIs(t, guard.Check(Timestamp(now.Add(-2*time.Minute)), "nonce-0000000003"), ErrStaleTimestamp)
+	assert.ErrorIs(t, guard.Check(Timestamp(now.Add(2*time.Minute)), "nonce-0000000003"), ErrStaleTimestamp)
+	assert.ErrorIs(t, guard.Check("yesterday", "nonce-0000000003"), ErrInvalidTimestamp)
+	assert.ErrorIs(t, guard.Check(ts, "short"), ErrInvalidNonce)
+
+	guard.Release("nonce-0000000001")
+	assert.NoError(t, guard.Check(ts, "nonce-0000000001"), "released nonce can be retried")
+}
+
+func TestReplayGuardOverflow(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	guard := NewReplayGuard(time.Minute, 2)
+	guard.now = func() time.Time { return now }
+
+	first := Timestamp(now.Add(-30 * time.Second))
+	assert.NoError(t, guard.Check(first, "nonce-0000000001"))
+	assert.NoError(t, guard.Check(Timestamp(now.Add(-20*time.Second)), "nonce-0000000002"))
+	assert.NoError(t, guard.Check(Timestamp(now), "nonce-0000000003"))
+
+	assert.ErrorIs(t, guard.Check(first, "nonce-0000000001"), ErrReplayed, "evicted nonce must stay rejected")
+	assert.NoError(t, guard.Check(Timestamp(now), "nonce-0000000004"))
+}
+
+func TestReplayGuardExpiry(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	guard := NewReplayGuard(time.Minute, 100)
+	guard.now = func() time.Time { return now }
+	for i := 0; i < 50; i++ {
+		assert.NoError(t, guard.Check(Timestamp(now), "nonce-"+strconv.Itoa(1000000000+i)))
+	}
+	now = now.Add(2 * time.Minute)
+	assert.NoError(t, guard.Check(Timestamp(now), "nonce-0000000001"))
+	assert.Equal(t, 1, guard.order.Len())
+}
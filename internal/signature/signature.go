@@ -0,0 +1,68 @@
+// Package signature HMAC request signing shared by agent transport and keeper middleware
+package signature
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	Header          = "HashSHA256"
+	TimestampHeader = "X-Signature-Timestamp"
+	NonceHeader     = "X-Signature-Nonce"
+)
+
+// Canonical content covered by signature: method, path with query, timestamp, nonce and body, newline separated
+func Canonical(method string, path string, timestamp string, nonce string, body []byte) []byte {
+	content := make([]byte, 0, len(method)+len(path)+len(timestamp)+len(nonce)+len(body)+4)
+	content = append(content, method...)
+	content = append(content, '\n')
+	content = append(content, path...)
+	content = append(content, '\n')
+	content = append(content, timestamp...)
+	content = append(content, '\n')
+	content = append(content, nonce...)
+	content = append(content, '\n')
+	return append(content, body...)
+}
+
+// Sign HMAC-SHA256 of canonical content
+func Sign(key string, method string, path string, timestamp string, nonce string, body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write(Canonical(method, path, timestamp, nonce, body))
+	return mac.Sum(nil)
+}
+
+// SignResponse HMAC-SHA256 of complete response body
+func SignResponse(key string, body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// Path escaped request path with raw query, as it is signed
+func Path(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.EscapedPath()
+	}
+	return u.EscapedPath() + "?" + u.RawQuery
+}
+
+// Timestamp format time as signed timestamp header
+func Timestamp(t time.Time) string {
+	return strconv.FormatInt(t.Unix(), 10)
+}
+
+// NewNonce random nonce for one request
+func NewNonce() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
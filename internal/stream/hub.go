@@ -0,0 +1,219 @@
+// Package stream fan out accepted metric updates to live subscribers.
+//
+// Publishing never waits for subscribers: every subscription keeps latest value of each
+// pending metric, so updates of the same metric are coalesced while consumer is busy, and
+// updates of new metrics are dropped once buffer of subscription is full.
+package stream
+
+import (
+	"path"
+	"sort"
+	"sync"
+
+	"github.com/DimKa163/go-metrics/internal/models"
+)
+
+// DefaultBuffer pending metrics kept per subscription
+const DefaultBuffer = 1024
+
+// Filter selection of streamed metrics, zero value selects everything
+type Filter struct {
+	Type string
+	// Names globs in path.Match syntax, metric must match any of them
+	Names []string
+}
+
+// Validate report malformed name glob
+func (f *Filter) Validate() error {
+	for _, name := range f.Names {
+		if _, err := path.Match(name, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Matches report whether metric is selected by filter
+func (f *Filter) Matches(metric *models.Metric) bool {
+	if f.Type != "" && metric.Type != f.Type {
+		return false
+	}
+	if len(f.Names) == 0 {
+		return true
+	}
+	for _, name := range f.Names {
+		if ok, _ := path.Match(name, metric.ID); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Hub subscriptions of every tenant
+type Hub struct {
+	mutex   sync.RWMutex
+	tenants map[string]map[*Subscription]struct{}
+	buffer  int
+	closed  bool
+}
+
+// NewHub hub keeping at most buffer pending metrics per subscription, DefaultBuffer when buffer isn't positive
+func NewHub(buffer int) *Hub {
+	if buffer <= 0 {
+		buffer = DefaultBuffer
+	}
+	return &Hub{
+		tenants: make(map[string]map[*Subscription]struct{}),
+		buffer:  buffer,
+	}
+}
+
+// Publish hand metrics of tenant to matching subscriptions without blocking
+func (h *Hub) Publish(tenant string, metrics []models.Metric) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	for subscription := range h.tenants[tenant] {
+		subscription.offer(metrics)
+	}
+}
+
+// Subscribe start receiving updates of tenant selected by filter, subscription of closed hub is done at once
+func (h *Hub) Subscribe(tenant string, filter Filter) *Subscription {
+	subscription := &Subscription{
+		hub:     h,
+		tenant:  tenant,
+		filter:  filter,
+		buffer:  h.buffer,
+		pending: make(map[string]models.Metric),
+		ready:   make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.closed {
+		close(subscription.done)
+		return subscription
+	}
+	subscriptions, ok := h.tenants[tenant]
+	if !ok {
+		subscriptions = make(map[*Subscription]struct{})
+		h.tenants[tenant] = subscriptions
+	}
+	subscriptions[subscription] = struct{}{}
+	return subscription
+}
+
+// Subscribers count of live subscriptions
+func (h *Hub) Subscribers() int {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	count := 0
+	for _, subscriptions := range h.tenants {
+		count += len(subscriptions)
+	}
+	return count
+}
+
+// Close end every subscription, so streaming requests return before server shutdown
+func (h *Hub) Close() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.closed = true
+	for tenant, subscriptions := range h.tenants {
+		for subscription := range subscriptions {
+			close(subscription.done)
+		}
+		delete(h.tenants, tenant)
+	}
+}
+
+func (h *Hub) remove(subscription *Subscription) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	subscriptions, ok := h.tenants[subscription.tenant]
+	if !ok {
+		return
+	}
+	if _, ok = subscriptions[subscription]; !ok {
+		return
+	}
+	delete(subscriptions, subscription)
+	if len(subscriptions) == 0 {
+		delete(h.tenants, subscription.tenant)
+	}
+	close(subscription.done)
+}
+
+// Subscription pending updates of one consumer
+type Subscription struct {
+	hub     *Hub
+	tenant  string
+	filter  Filter
+	buffer  int
+	mutex   sync.Mutex
+	pending map[string]models.Metric
+	dropped int64
+	ready   chan struct{}
+	done    chan struct{}
+}
+
+// Ready signalled when there are pending updates
+func (s *Subscription) Ready() <-chan struct{} {
+	return s.ready
+}
+
+// Done closed when subscription ends
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// Take pending updates ordered by id and count of updates dropped since previous take
+func (s *Subscription) Take() ([]models.Metric, int64) {
+	// signal is cleared before pending updates are taken, so offer made meanwhile signals again
+	select {
+	case <-s.ready:
+	default:
+	}
+	s.mutex.Lock()
+	pending, dropped := s.pending, s.dropped
+	s.pending = make(map[string]models.Metric, len(pending))
+	s.dropped = 0
+	s.mutex.Unlock()
+	metrics := make([]models.Metric, 0, len(pending))
+	for _, metric := range pending {
+		metrics = append(metrics, metric)
+	}
+	sort.Slice(metrics, func(i, j int) bool {
+		return metrics[i].ID < metrics[j].ID
+	})
+	return metrics, dropped
+}
+
+// Close stop receiving updates
+func (s *Subscription) Close() {
+	s.hub.remove(s)
+}
+
+func (s *Subscription) offer(metrics []models.Metric) {
+	offered := false
+	s.mutex.Lock()
+	for _, metric := range metrics {
+		if !s.filter.Matches(&metric) {
+			continue
+		}
+		if _, ok := s.pending[metric.ID]; !ok && len(s.pending) >= s.buffer {
+			s.dropped++
+			continue
+		}
+		s.pending[metric.ID] = metric
+		offered = true
+	}
+	s.mutex.Unlock()
+	if !offered {
+		return
+	}
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,77 @@
+package stream
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DimKa163/go-metrics/internal/models"
+)
+
+func TestHubCoalescesAndDrops(t *testing.T) {
+	hub := NewHub(2)
+	subscription := hub.Subscribe("alpha", Filter{})
+	defer subscription.Close()
+
+	hub.Publish("alpha", []models.Metric{*models.CreateGauge("b", 1), *models.CreateGauge("a", 1)})
+	hub.Publish("alpha", []models.Metric{*models.CreateGauge("b", 2), *models.CreateGauge("c", 3)})
+	hub.Publish("beta", []models.Metric{*models.CreateGauge("a", 9)})
+
+	select {
+	case <-subscription.Ready():
+	default:
+		t.Fatal("subscription is not ready")
+	}
+	metrics, dropped := subscription.Take()
+	assert.Equal(t, []models.Metric{*models.CreateGauge("a", 1), *models.CreateGauge("b", 2)}, metrics)
+	assert.Equal(t, int64(1), dropped)
+
+	metrics, dropped = subscription.Take()
+	assert.Empty(t, metrics)
+	assert.Zero(t, dropped)
+}
+
+func TestHubFilter(t *testing.T) {
+	hub := NewHub(0)
+	filter := Filter{Type: models.GaugeType, Names: []string{"cpu.*", "mem"}}
+	require.NoError(t, filter.Validate())
+	subscription := hub.Subscribe(models.DefaultTenant, filter)
+	defer subscription.Close()
+
+	hub.Publish(models.DefaultTenant, []models.Metric{
+		*models.CreateGauge("cpu.user", 1),
+		*models.CreateCounter("cpu.count", 1),
+		*models.CreateGauge("mem", 2),
+		*models.CreateGauge("memory", 3),
+	})
+	metrics, _ := subscription.Take()
+	assert.Equal(t, []models.Metric{*models.CreateGauge("cpu.user", 1), *models.CreateGauge("mem", 2)}, metrics)
+
+	hub.Publish(models.DefaultTenant, []models.Metric{*models.CreateGauge("disk", 1)})
+	select {
+	case <-subscription.Ready():
+		t.Fatal("filtered update signalled subscription")
+	default:
+	}
+	assert.Error(t, (&Filter{Names: []string{"["}}).Validate())
+}
+
+func TestHubClose(t *testing.T) {
+	hub := NewHub(0)
+	first := hub.Subscribe("alpha", Filter{})
+	second := hub.Subscribe("alpha", Filter{})
+	assert.Equal(t, 2, hub.Subscribers())
+
+	first.Close()
+	first.Close()
+	assert.Equal(t, 1, hub.Subscribers())
+	<-first.Done()
+
+	hub.Close()
+	<-second.Done()
+	second.Close()
+	assert.Zero(t, hub.Subscribers())
+	<-hub.Subscribe("alpha", Filter{}).Done()
+	hub.Publish("alpha", []models.Metric{*models.CreateGauge("a", 1)})
+}
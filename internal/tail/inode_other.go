@@ -0,0 +1,10 @@
+//go:build !unix
+
+package tail
+
+import "os"
+
+// inode is not available, rotation across restarts is detected by size only
+func inode(_ os.FileInfo) uint64 {
+	return 0
+}
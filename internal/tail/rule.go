@@ -0,0 +1,79 @@
+package tail
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/DimKa163/go-metrics/internal/models"
+)
+
+// Rule regex applied to every line, counter counts matches and gauge takes captured value
+type Rule struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+	Type    string `json:"type"`
+	// Group capture group name or index holding gauge value, first group by default
+	Group string `json:"group"`
+}
+
+type rule struct {
+	Rule
+	regex *regexp.Regexp
+	group int
+}
+
+func compileRule(r Rule) (*rule, error) {
+	if r.Name == "" {
+		return nil, fmt.Errorf("tail rule %q: name is required", r.Pattern)
+	}
+	if r.Type == "" {
+		r.Type = models.CounterType
+	}
+	regex, err := regexp.Compile(r.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("tail rule %q: %w", r.Name, err)
+	}
+	compiled := &rule{Rule: r, regex: regex}
+	switch r.Type {
+	case models.CounterType:
+	case models.GaugeType:
+		compiled.group, err = resolveGroup(regex, r.Group)
+		if err != nil {
+			return nil, fmt.Errorf("tail rule %q: %w", r.Name, err)
+		}
+	default:
+		return nil, fmt.Errorf("tail rule %q: %w", r.Name, models.ErrUnknownMetricType)
+	}
+	return compiled, nil
+}
+
+func resolveGroup(regex *regexp.Regexp, group string) (int, error) {
+	if group == "" {
+		group = "1"
+	}
+	if idx := regex.SubexpIndex(group); idx >= 0 {
+		return idx, nil
+	}
+	idx, err := strconv.Atoi(group)
+	if err != nil || idx < 1 || idx > regex.NumSubexp() {
+		return 0, fmt.Errorf("capture group %q not found", group)
+	}
+	return idx, nil
+}
+
+// match returns gauge value when rule is gauge, ok reports whether line matched
+func (r *rule) match(line string) (float64, bool) {
+	if r.Type == models.CounterType {
+		return 0, r.regex.MatchString(line)
+	}
+	groups := r.regex.FindStringSubmatch(line)
+	if groups == nil {
+		return 0, false
+	}
+	value, err := strconv.ParseFloat(groups[r.group], 64)
+	if err != nil {
+		return 0, false
+	}
+	return value, true
+}
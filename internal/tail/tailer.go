@@ -0,0 +1,294 @@
+// Package tail derive metrics from log files
+package tail
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/DimKa163/go-metrics/internal/client"
+	"github.com/DimKa163/go-metrics/internal/models"
+)
+
+// File log file watched by tailer
+type File struct {
+	Path          string `json:"path"`
+	FromBeginning bool   `json:"from_beginning"`
+	Rules         []Rule `json:"rules"`
+}
+
+type Options struct {
+	// StatePath file persisting read offsets between restarts, offsets are kept in memory when empty
+	StatePath      string
+	PollInterval   time.Duration
+	ReportInterval time.Duration
+}
+
+type position struct {
+	Offset int64  `json:"offset"`
+	Inode  uint64 `json:"inode"`
+}
+
+type watchedFile struct {
+	File
+	rules []*rule
+	file  *os.File
+	info  os.FileInfo
+	pos   position
+	known bool
+}
+
+// Tailer follow log files surviving rotation and truncation
+type Tailer struct {
+	files    []*watchedFile
+	client   client.MetricClient
+	options  Options
+	counters map[string]int64
+	gauges   map[string]float64
+}
+
+func NewTailer(files []File, metricClient client.MetricClient, options Options) (*Tailer, error) {
+	if options.PollInterval <= 0 {
+		options.PollInterval = time.Second
+	}
+	if options.ReportInterval <= 0 {
+		options.ReportInterval = 10 * time.Second
+	}
+	t := &Tailer{
+		client:   metricClient,
+		options:  options,
+		counters: make(map[string]int64),
+		gauges:   make(map[string]float64),
+	}
+	for _, f := range files {
+		if f.Path == "" {
+			return nil, errors.New("tail file: path is required")
+		}
+		wf := &watchedFile{File: f}
+		for _, r := range f.Rules {
+			compiled, err := compileRule(r)
+			if err != nil {
+				return nil, err
+			}
+			wf.rules = append(wf.rules, compiled)
+		}
+		t.files = append(t.files, wf)
+	}
+	if err := t.loadState(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Run follow files until context cancelled
+func (t *Tailer) Run(ctx context.Context) error {
+	pollTicker := time.NewTicker(t.options.PollInterval)
+	defer pollTicker.Stop()
+	reportTicker := time.NewTicker(t.options.ReportInterval)
+	defer reportTicker.Stop()
+	defer t.close()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-pollTicker.C:
+			t.Poll()
+		case <-reportTicker.C:
+			if err := t.Flush(); err != nil {
+				fmt.Printf("Error sending log metrics: %v\n", err)
+			}
+		}
+	}
+}
+
+// Poll read new lines of every file
+func (t *Tailer) Poll() {
+	for _, wf := range t.files {
+		if err := t.poll(wf); err != nil {
+			fmt.Printf("Error tailing %s: %v\n", wf.Path, err)
+		}
+	}
+}
+
+// Flush send accumulated metrics and persist offsets on success
+func (t *Tailer) Flush() error {
+	metrics := make([]*models.Metric, 0, len(t.counters)+len(t.gauges))
+	for id, delta := range t.counters {
+		metrics = append(metrics, models.CreateCounter(id, delta))
+	}
+	for id, value := range t.gauges {
+		metrics = append(metrics, models.CreateGauge(id, value))
+	}
+	if len(metrics) > 0 {
+		if err := t.client.BatchUpdate(metrics); err != nil {
+			return err
+		}
+		clear(t.counters)
+		clear(t.gauges)
+	}
+	return t.saveState()
+}
+
+func (t *Tailer) poll(wf *watchedFile) error {
+	info, err := os.Stat(wf.Path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		if wf.file != nil {
+			return t.read(wf)
+		}
+		return nil
+	}
+	if wf.file != nil && !os.SameFile(wf.info, info) {
+		// rotated: finish old file before switching to the new one
+		if err = t.read(wf); err != nil {
+			return err
+		}
+		_ = wf.file.Close()
+		wf.file = nil
+		wf.pos = position{}
+		wf.known = true
+	}
+	if wf.file == nil {
+		if err = t.open(wf); err != nil {
+			return err
+		}
+		info = wf.info
+	}
+	if info.Size() < wf.pos.Offset {
+		wf.pos.Offset = 0
+	}
+	wf.info = info
+	return t.read(wf)
+}
+
+func (t *Tailer) open(wf *watchedFile) error {
+	file, err := os.Open(wf.Path)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	id := inode(info)
+	switch {
+	case !wf.known:
+		wf.pos = position{}
+		if !wf.FromBeginning {
+			wf.pos.Offset = info.Size()
+		}
+	case wf.pos.Inode != 0 && wf.pos.Inode != id, info.Size() < wf.pos.Offset:
+		wf.pos = position{}
+	}
+	wf.pos.Inode = id
+	wf.known = true
+	wf.file = file
+	wf.info = info
+	return nil
+}
+
+// read process complete lines after current offset, partial line is left for the next poll
+func (t *Tailer) read(wf *watchedFile) error {
+	if _, err := wf.file.Seek(wf.pos.Offset, io.SeekStart); err != nil {
+		return err
+	}
+	reader := bufio.NewReader(wf.file)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		wf.pos.Offset += int64(len(line))
+		t.apply(wf, strings.TrimRight(line, "\r\n"))
+	}
+}
+
+func (t *Tailer) apply(wf *watchedFile, line string) {
+	for _, r := range wf.rules {
+		value, ok := r.match(line)
+		if !ok {
+			continue
+		}
+		if r.Type == models.CounterType {
+			t.counters[r.Name]++
+		} else {
+			t.gauges[r.Name] = value
+		}
+	}
+}
+
+func (t *Tailer) close() {
+	for _, wf := range t.files {
+		if wf.file != nil {
+			_ = wf.file.Close()
+			wf.file = nil
+		}
+	}
+}
+
+func (t *Tailer) loadState() error {
+	if t.options.StatePath == "" {
+		return nil
+	}
+	data, err := os.ReadFile(t.options.StatePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	state := make(map[string]position)
+	if err = json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("tail state %s: %w", t.options.StatePath, err)
+	}
+	for _, wf := range t.files {
+		if pos, ok := state[wf.Path]; ok {
+			wf.pos = pos
+			wf.known = true
+		}
+	}
+	return nil
+}
+
+func (t *Tailer) saveState() error {
+	if t.options.StatePath == "" {
+		return nil
+	}
+	state := make(map[string]position, len(t.files))
+	for _, wf := range t.files {
+		if wf.known {
+			state[wf.Path] = wf.pos
+		}
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(t.options.StatePath), filepath.Base(t.options.StatePath)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), t.options.StatePath)
+}
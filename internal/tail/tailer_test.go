@@ -0,0 +1,135 @@
+package tail
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DimKa163/go-metrics/internal/mocks"
+	"github.com/DimKa163/go-metrics/internal/models"
+)
+
+var testRules = []Rule{
+	{Name: "Errors", Pattern: "ERROR"},
+	{Name: "Latency", Pattern: `latency=(?P<ms>\d+)`, Type: models.GaugeType, Group: "ms"},
+}
+
+func TestTailerCountsAndSurvivesRotation(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "app.log")
+	appendLines(t, logPath, "ERROR old line before start\n")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := mocks.NewMockMetricClient(ctrl)
+	sent := captureBatches(client)
+
+	sut, err := NewTailer([]File{{Path: logPath, Rules: testRules}}, client, Options{StatePath: filepath.Join(dir, "state.json")})
+	require.NoError(t, err)
+
+	sut.Poll()
+	appendLines(t, logPath, "ERROR first\nINFO latency=40\nERROR partial")
+	sut.Poll()
+	require.NoError(t, sut.Flush())
+	require.Len(t, *sent, 1)
+	assert.Equal(t, map[string]models.Metric{
+		"Errors":  *models.CreateCounter("Errors", 1),
+		"Latency": *models.CreateGauge("Latency", 40),
+	}, metricsByID((*sent)[0]))
+
+	require.NoError(t, os.Rename(logPath, logPath+".1"))
+	appendLines(t, logPath+".1", " line\n")
+	appendLines(t, logPath, "ERROR in new file\n")
+	sut.Poll()
+	require.NoError(t, sut.Flush())
+	require.Len(t, *sent, 2)
+	assert.Equal(t, int64(2), *metricsByID((*sent)[1])["Errors"].Delta)
+	sut.close()
+}
+
+func TestTailerResumesFromPersistedOffset(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "app.log")
+	statePath := filepath.Join(dir, "state.json")
+	appendLines(t, logPath, "ERROR one\n")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := mocks.NewMockMetricClient(ctrl)
+	sent := captureBatches(client)
+
+	first, err := NewTailer([]File{{Path: logPath, FromBeginning: true, Rules: testRules}}, client, Options{StatePath: statePath})
+	require.NoError(t, err)
+	first.Poll()
+	require.NoError(t, first.Flush())
+	first.close()
+
+	appendLines(t, logPath, "ERROR two\nERROR three\n")
+	second, err := NewTailer([]File{{Path: logPath, FromBeginning: true, Rules: testRules}}, client, Options{StatePath: statePath})
+	require.NoError(t, err)
+	second.Poll()
+	require.NoError(t, second.Flush())
+	second.close()
+
+	require.Len(t, *sent, 2)
+	assert.Equal(t, int64(1), *metricsByID((*sent)[0])["Errors"].Delta)
+	assert.Equal(t, int64(2), *metricsByID((*sent)[1])["Errors"].Delta)
+}
+
+func TestTailerHandlesTruncation(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "app.log")
+	appendLines(t, logPath, "ERROR one\nERROR two\n")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := mocks.NewMockMetricClient(ctrl)
+	sent := captureBatches(client)
+
+	sut, err := NewTailer([]File{{Path: logPath, Rules: testRules}}, client, Options{})
+	require.NoError(t, err)
+	sut.Poll()
+	require.NoError(t, os.Truncate(logPath, 0))
+	appendLines(t, logPath, "ERROR x\n")
+	sut.Poll()
+	require.NoError(t, sut.Flush())
+	sut.close()
+
+	require.Len(t, *sent, 1)
+	assert.Equal(t, int64(1), *metricsByID((*sent)[0])["Errors"].Delta)
+}
+
+func TestCompileRuleUnknownGroup(t *testing.T) {
+	_, err := compileRule(Rule{Name: "Latency", Pattern: `latency=(\d+)`, Type: models.GaugeType, Group: "ms"})
+	assert.Error(t, err)
+}
+
+func captureBatches(client *mocks.MockMetricClient) *[][]*models.Metric {
+	var sent [][]*models.Metric
+	client.EXPECT().BatchUpdate(gomock.Any()).DoAndReturn(func(metrics []*models.Metric) error {
+		sent = append(sent, metrics)
+		return nil
+	}).AnyTimes()
+	return &sent
+}
+
+func metricsByID(metrics []*models.Metric) map[string]models.Metric {
+	result := make(map[string]models.Metric, len(metrics))
+	for _, m := range metrics {
+		result[m.ID] = *m
+	}
+	return result
+}
+
+func appendLines(t *testing.T, path string, data string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = f.WriteString(data)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+}
@@ -12,12 +12,12 @@ import (
 )
 
 type DumpTask struct {
-	repository persistence.Repository
+	repository persistence.Snapshotter
 	filer      *files.Filer
 	interval   time.Duration
 }
 
-func NewDumpTask(repository persistence.Repository, filer *files.Filer, interval time.Duration) *DumpTask {
+func NewDumpTask(repository persistence.Snapshotter, filer *files.Filer, interval time.Duration) *DumpTask {
 	return &DumpTask{
 		repository: repository,
 		filer:      filer,
@@ -42,11 +42,11 @@ func (task *DumpTask) run(ctx context.Context) error {
 		case <-storeTicker.C:
 			logging.Log.Info("Storing metrics...")
 			startTime := time.Now()
-			metrics, err := task.repository.GetAll(ctx)
+			tenants, err := task.repository.Snapshot(ctx)
 			if err != nil {
 				logging.Log.Error("dump task cancelled", zap.Error(err))
 			}
-			if err := task.filer.Dump(metrics); err != nil {
+			if err := task.filer.DumpTenants(tenants); err != nil {
 				logging.Log.Error("Dump with error", zap.Error(err))
 			}
 			elapsed := time.Since(startTime)
@@ -0,0 +1,134 @@
+package tasks
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/DimKa163/go-metrics/internal/logging"
+	"github.com/DimKa163/go-metrics/internal/usecase"
+)
+
+// GroupExpiryTask remove pushed groups which were not updated within ttl
+type GroupExpiryTask struct {
+	service *usecase.PushService
+	ttl     time.Duration
+}
+
+func NewGroupExpiryTask(service *usecase.PushService, ttl time.Duration) *GroupExpiryTask {
+	return &GroupExpiryTask{
+		service: service,
+		ttl:     ttl,
+	}
+}
+
+func (task *GroupExpiryTask) Start(ctx context.Context) {
+	go task.run(ctx)
+}
+
+func (task *GroupExpiryTask) run(ctx context.Context) {
+	interval := task.ttl / 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			removed, err := task.service.Expire(ctx, task.ttl)
+			if err != nil {
+				logging.Log.Error("pushed group expiry failed", zap.Error(err))
+				continue
+			}
+			if removed > 0 {
+				logging.Log.Info("expired pushed groups", zap.Int("count", removed))
+			}
+		}
+	}
+}
+
+// MetricExpiryTask remove metrics whose gc horizon elapsed, it runs every half of ttl
+type MetricExpiryTask struct {
+	service *usecase.MetricService
+	ttl     time.Duration
+}
+
+func NewMetricExpiryTask(service *usecase.MetricService, ttl time.Duration) *MetricExpiryTask {
+	return &MetricExpiryTask{
+		service: service,
+		ttl:     ttl,
+	}
+}
+
+func (task *MetricExpiryTask) Start(ctx context.Context) {
+	go task.run(ctx)
+}
+
+func (task *MetricExpiryTask) run(ctx context.Context) {
+	interval := task.ttl / 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			removed, err := task.service.Expire(ctx)
+			if err != nil {
+				logging.Log.Error("metric expiry failed", zap.Error(err))
+				continue
+			}
+			if removed > 0 {
+				logging.Log.Info("expired metrics", zap.Int("count", removed))
+			}
+		}
+	}
+}
+
+// IdempotencyPurgeTask remove idempotency keys whose ttl elapsed
+type IdempotencyPurgeTask struct {
+	service *usecase.IdempotencyService
+	ttl     time.Duration
+}
+
+func NewIdempotencyPurgeTask(service *usecase.IdempotencyService, ttl time.Duration) *IdempotencyPurgeTask {
+	return &IdempotencyPurgeTask{
+		service: service,
+		ttl:     ttl,
+	}
+}
+
+func (task *IdempotencyPurgeTask) Start(ctx context.Context) {
+	go task.run(ctx)
+}
+
+func (task *IdempotencyPurgeTask) run(ctx context.Context) {
+	interval := task.ttl / 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			removed, err := task.service.Purge(ctx)
+			if err != nil {
+				logging.Log.Error("idempotency key purge failed", zap.Error(err))
+				continue
+			}
+			if removed > 0 {
+				logging.Log.Debug("purged idempotency keys", zap.Int("count", removed))
+			}
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package telemetry
+
+import (
+	"github.com/DimKa163/go-metrics/internal/client"
+	"github.com/DimKa163/go-metrics/internal/models"
+)
+
+type instrumentedClient struct {
+	client.MetricClient
+	stats *Stats
+}
+
+// NewClient wrap client recording result of every send
+func NewClient(metricClient client.MetricClient, stats *Stats) client.MetricClient {
+	return &instrumentedClient{MetricClient: metricClient, stats: stats}
+}
+
+func (c *instrumentedClient) UpdateGauge(name string, value float64) error {
+	err := c.MetricClient.UpdateGauge(name, value)
+	c.stats.ObserveSend(err)
+	return err
+}
+
+func (c *instrumentedClient) UpdateCounter(name string, value int64) error {
+	err := c.MetricClient.UpdateCounter(name, value)
+	c.stats.ObserveSend(err)
+	return err
+}
+
+func (c *instrumentedClient) BatchUpdate(metrics []*models.Metric) error {
+	err := c.MetricClient.BatchUpdate(metrics)
+	c.stats.ObserveSend(err)
+	if err != nil {
+		c.stats.Drop(len(metrics))
+	}
+	return err
+}
@@ -0,0 +1,74 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Server local listener exposing agent stats and health
+type Server struct {
+	*http.Server
+	stats     *Stats
+	unhealthy time.Duration
+}
+
+// NewServer create listener, /healthz fails when nothing was sent successfully for unhealthyAfter
+func NewServer(addr string, stats *Stats, unhealthyAfter time.Duration) *Server {
+	s := &Server{stats: stats, unhealthy: unhealthyAfter}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /stats", s.Stats)
+	mux.HandleFunc("GET /healthz", s.Health)
+	s.Server = &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	return s
+}
+
+// Start bind address and serve in background until context cancelled, error of binding is returned
+// while error of serving is printed
+func (s *Server) Start(ctx context.Context) error {
+	listener, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return fmt.Errorf("telemetry listener: %w", err)
+	}
+	go func() {
+		<-ctx.Done()
+		timeoutCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = s.Shutdown(timeoutCtx)
+	}()
+	go func() {
+		if err := s.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Printf("Error serving telemetry: %v\n", err)
+		}
+	}()
+	return nil
+}
+
+func (s *Server) Stats(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, s.stats.Snapshot())
+}
+
+func (s *Server) Health(w http.ResponseWriter, _ *http.Request) {
+	last := s.stats.LastSuccess()
+	status := http.StatusOK
+	body := map[string]any{"status": "ok", "last_success": s.stats.Snapshot().LastSuccess}
+	if time.Since(last) > s.unhealthy {
+		status = http.StatusServiceUnavailable
+		body["status"] = "unhealthy"
+	}
+	writeJSON(w, status, body)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
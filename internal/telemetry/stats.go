@@ -0,0 +1,123 @@
+// Package telemetry agent self statistics and health
+package telemetry
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/DimKa163/go-metrics/internal/client"
+)
+
+// TransportFailure status used for sends failed before keeper responded
+const TransportFailure = "transport"
+
+// Stats agent counters, safe for concurrent use
+type Stats struct {
+	mutex          sync.RWMutex
+	startedAt      time.Time
+	pollDuration   time.Duration
+	reportDuration time.Duration
+	sendSuccess    int64
+	sendFailures   map[string]int64
+	dropped        int64
+	lastSuccess    time.Time
+	queueDepth     func() int
+}
+
+// Snapshot point in time copy of Stats
+type Snapshot struct {
+	StartedAt        time.Time        `json:"started_at"`
+	PollDurationMs   float64          `json:"poll_duration_ms"`
+	ReportDurationMs float64          `json:"report_duration_ms"`
+	SendSuccess      int64            `json:"send_success"`
+	SendFailures     map[string]int64 `json:"send_failures"`
+	QueueDepth       int              `json:"queue_depth"`
+	Dropped          int64            `json:"dropped"`
+	LastSuccess      *time.Time       `json:"last_success,omitempty"`
+}
+
+func NewStats() *Stats {
+	return &Stats{
+		startedAt:    time.Now(),
+		sendFailures: make(map[string]int64),
+	}
+}
+
+// SetQueueDepth register function reporting number of queued jobs
+func (s *Stats) SetQueueDepth(depth func() int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.queueDepth = depth
+}
+
+func (s *Stats) ObservePoll(elapsed time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.pollDuration = elapsed
+}
+
+func (s *Stats) ObserveReport(elapsed time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.reportDuration = elapsed
+}
+
+// ObserveSend count send result, failures are grouped by response status code
+func (s *Stats) ObserveSend(err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err == nil {
+		s.sendSuccess++
+		s.lastSuccess = time.Now()
+		return
+	}
+	status := TransportFailure
+	var statusErr *client.StatusError
+	if errors.As(err, &statusErr) {
+		status = strconv.Itoa(statusErr.Code)
+	}
+	s.sendFailures[status]++
+}
+
+// Drop count metrics discarded without being delivered
+func (s *Stats) Drop(count int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.dropped += int64(count)
+}
+
+// LastSuccess time of last successful send, agent start time when nothing was sent yet
+func (s *Stats) LastSuccess() time.Time {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if s.lastSuccess.IsZero() {
+		return s.startedAt
+	}
+	return s.lastSuccess
+}
+
+func (s *Stats) Snapshot() Snapshot {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	snapshot := Snapshot{
+		StartedAt:        s.startedAt,
+		PollDurationMs:   float64(s.pollDuration) / float64(time.Millisecond),
+		ReportDurationMs: float64(s.reportDuration) / float64(time.Millisecond),
+		SendSuccess:      s.sendSuccess,
+		SendFailures:     make(map[string]int64, len(s.sendFailures)),
+		Dropped:          s.dropped,
+	}
+	for status, count := range s.sendFailures {
+		snapshot.SendFailures[status] = count
+	}
+	if s.queueDepth != nil {
+		snapshot.QueueDepth = s.queueDepth()
+	}
+	if !s.lastSuccess.IsZero() {
+		last := s.lastSuccess
+		snapshot.LastSuccess = &last
+	}
+	return snapshot
+}
@@ -0,0 +1,77 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DimKa163/go-metrics/internal/client"
+	"github.com/DimKa163/go-metrics/internal/mocks"
+	"github.com/DimKa163/go-metrics/internal/models"
+)
+
+func TestInstrumentedClientRecordsSends(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	inner := mocks.NewMockMetricClient(ctrl)
+	inner.EXPECT().UpdateGauge("Alloc", 1.0).Return(nil)
+	inner.EXPECT().UpdateCounter("PollCount", int64(1)).Return(&client.StatusError{Code: http.StatusBadRequest})
+	inner.EXPECT().BatchUpdate(gomock.Any()).Return(errors.New("connection refused"))
+
+	stats := NewStats()
+	stats.SetQueueDepth(func() int { return 3 })
+	sut := NewClient(inner, stats)
+
+	assert.NoError(t, sut.UpdateGauge("Alloc", 1.0))
+	assert.Error(t, sut.UpdateCounter("PollCount", 1))
+	assert.Error(t, sut.BatchUpdate([]*models.Metric{models.CreateGauge("A", 1), models.CreateGauge("B", 2)}))
+
+	snapshot := stats.Snapshot()
+	assert.Equal(t, int64(1), snapshot.SendSuccess)
+	assert.Equal(t, map[string]int64{"400": 1, TransportFailure: 1}, snapshot.SendFailures)
+	assert.Equal(t, int64(2), snapshot.Dropped)
+	assert.Equal(t, 3, snapshot.QueueDepth)
+	assert.NotNil(t, snapshot.LastSuccess)
+}
+
+func TestHealth(t *testing.T) {
+	stats := NewStats()
+	sut := NewServer("", stats, time.Hour)
+
+	res := httptest.NewRecorder()
+	sut.Handler.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, res.Code, "agent is healthy within window after start")
+
+	sut.unhealthy = -time.Second
+	res = httptest.NewRecorder()
+	sut.Handler.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, res.Code)
+
+	stats.ObserveSend(nil)
+	res = httptest.NewRecorder()
+	sut.Handler.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/stats", nil))
+	require.Equal(t, http.StatusOK, res.Code)
+	var snapshot Snapshot
+	require.NoError(t, json.Unmarshal(res.Body.Bytes(), &snapshot))
+	assert.Equal(t, int64(1), snapshot.SendSuccess)
+}
+
+func TestStartReportsBusyAddress(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	sut := NewServer(listener.Addr().String(), NewStats(), time.Hour)
+	assert.Error(t, sut.Start(ctx), "address in use must be reported")
+}
@@ -0,0 +1,323 @@
+// Package transfer encode and decode whole metric set in portable CSV, JSON and NDJSON formats,
+// so state can be moved between keepers regardless of repository backend.
+//
+// Every format carries id, type and value of gauge or delta of counter. CSV starts with header
+// naming columns, JSON is single array and NDJSON is one object per line.
+package transfer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"strconv"
+
+	"github.com/DimKa163/go-metrics/internal/models"
+)
+
+const (
+	FormatCSV    = "csv"
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson"
+)
+
+var (
+	ErrUnknownFormat = errors.New("unknown format")
+	ErrInvalidRecord = errors.New("invalid record")
+)
+
+// csvHeader columns of exported CSV
+var csvHeader = []string{"id", "type", "value", "delta"}
+
+// record metric as written in JSON formats
+type record struct {
+	ID    string   `json:"id"`
+	Type  string   `json:"type"`
+	Value *float64 `json:"value,omitempty"`
+	Delta *int64   `json:"delta,omitempty"`
+}
+
+// ContentType media type of format
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv"
+	case FormatNDJSON:
+		return "application/x-ndjson"
+	default:
+		return "application/json"
+	}
+}
+
+// FormatOf format named by value, which is format name or media type, empty when it's unknown
+func FormatOf(value string) string {
+	switch value {
+	case FormatCSV, FormatJSON, FormatNDJSON:
+		return value
+	}
+	mediaType, _, err := mime.ParseMediaType(value)
+	if err != nil {
+		return ""
+	}
+	switch mediaType {
+	case "text/csv":
+		return FormatCSV
+	case "application/json":
+		return FormatJSON
+	case "application/x-ndjson", "application/ndjson", "application/jsonl":
+		return FormatNDJSON
+	}
+	return ""
+}
+
+// Encoder write metrics one by one, Close completes output
+type Encoder interface {
+	Encode(metric models.Metric) error
+
+	Close() error
+}
+
+// Decoder read metrics one by one, io.EOF is returned after last one
+type Decoder interface {
+	Decode() (models.Metric, error)
+}
+
+func NewEncoder(writer io.Writer, format string) (Encoder, error) {
+	switch format {
+	case FormatCSV:
+		return &csvEncoder{writer: csv.NewWriter(writer)}, nil
+	case FormatJSON:
+		return &jsonEncoder{writer: writer}, nil
+	case FormatNDJSON:
+		return &ndjsonEncoder{encoder: json.NewEncoder(writer)}, nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+}
+
+func NewDecoder(reader io.Reader, format string) (Decoder, error) {
+	switch format {
+	case FormatCSV:
+		return &csvDecoder{reader: csv.NewReader(reader)}, nil
+	case FormatJSON:
+		return &jsonDecoder{decoder: json.NewDecoder(reader)}, nil
+	case FormatNDJSON:
+		return &ndjsonDecoder{scanner: bufio.NewScanner(reader)}, nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+}
+
+type csvEncoder struct {
+	writer *csv.Writer
+	header bool
+}
+
+func (e *csvEncoder) Encode(metric models.Metric) error {
+	if !e.header {
+		e.header = true
+		if err := e.writer.Write(csvHeader); err != nil {
+			return err
+		}
+	}
+	row := []string{metric.ID, metric.Type, "", ""}
+	if metric.Value != nil {
+		row[2] = strconv.FormatFloat(*metric.Value, 'g', -1, 64)
+	}
+	if metric.Delta != nil {
+		row[3] = strconv.FormatInt(*metric.Delta, 10)
+	}
+	return e.writer.Write(row)
+}
+
+func (e *csvEncoder) Close() error {
+	if !e.header {
+		e.header = true
+		if err := e.writer.Write(csvHeader); err != nil {
+			return err
+		}
+	}
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+type jsonEncoder struct {
+	writer io.Writer
+	count  int
+}
+
+func (e *jsonEncoder) Encode(metric models.Metric) error {
+	data, err := json.Marshal(toRecord(metric))
+	if err != nil {
+		return err
+	}
+	separator := ","
+	if e.count == 0 {
+		separator = "["
+	}
+	e.count++
+	if _, err = io.WriteString(e.writer, separator); err != nil {
+		return err
+	}
+	_, err = e.writer.Write(data)
+	return err
+}
+
+func (e *jsonEncoder) Close() error {
+	closing := "]\n"
+	if e.count == 0 {
+		closing = "[]\n"
+	}
+	_, err := io.WriteString(e.writer, closing)
+	return err
+}
+
+type ndjsonEncoder struct {
+	encoder *json.Encoder
+}
+
+func (e *ndjsonEncoder) Encode(metric models.Metric) error {
+	return e.encoder.Encode(toRecord(metric))
+}
+
+func (e *ndjsonEncoder) Close() error {
+	return nil
+}
+
+type csvDecoder struct {
+	reader  *csv.Reader
+	columns map[string]int
+	line    int
+}
+
+func (d *csvDecoder) Decode() (models.Metric, error) {
+	if d.columns == nil {
+		header, err := d.reader.Read()
+		if err != nil {
+			return models.Metric{}, err
+		}
+		d.line++
+		d.columns = make(map[string]int, len(header))
+		for i, column := range header {
+			d.columns[column] = i
+		}
+		for _, column := range []string{"id", "type"} {
+			if _, ok := d.columns[column]; !ok {
+				return models.Metric{}, fmt.Errorf("%w: header has no %s column", ErrInvalidRecord, column)
+			}
+		}
+	}
+	row, err := d.reader.Read()
+	if err != nil {
+		return models.Metric{}, err
+	}
+	d.line++
+	field := func(column string) string {
+		if i, ok := d.columns[column]; ok {
+			return row[i]
+		}
+		return ""
+	}
+	metric := models.Metric{ID: field("id"), Type: field("type")}
+	if value := field("value"); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return models.Metric{}, fmt.Errorf("%w: line %d: %w", ErrInvalidRecord, d.line, err)
+		}
+		metric.Value = &parsed
+	}
+	if delta := field("delta"); delta != "" {
+		parsed, err := strconv.ParseInt(delta, 10, 64)
+		if err != nil {
+			return models.Metric{}, fmt.Errorf("%w: line %d: %w", ErrInvalidRecord, d.line, err)
+		}
+		metric.Delta = &parsed
+	}
+	return validate(metric, d.line)
+}
+
+type jsonDecoder struct {
+	decoder *json.Decoder
+	started bool
+	count   int
+}
+
+func (d *jsonDecoder) Decode() (models.Metric, error) {
+	if !d.started {
+		d.started = true
+		token, err := d.decoder.Token()
+		if err != nil {
+			return models.Metric{}, err
+		}
+		if delim, ok := token.(json.Delim); !ok || delim != '[' {
+			return models.Metric{}, fmt.Errorf("%w: array expected", ErrInvalidRecord)
+		}
+	}
+	if !d.decoder.More() {
+		if _, err := d.decoder.Token(); err != nil {
+			return models.Metric{}, err
+		}
+		return models.Metric{}, io.EOF
+	}
+	d.count++
+	var item record
+	if err := d.decoder.Decode(&item); err != nil {
+		return models.Metric{}, fmt.Errorf("%w: item %d: %w", ErrInvalidRecord, d.count, err)
+	}
+	return validate(item.metric(), d.count)
+}
+
+type ndjsonDecoder struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (d *ndjsonDecoder) Decode() (models.Metric, error) {
+	for d.scanner.Scan() {
+		d.line++
+		data := d.scanner.Bytes()
+		if len(data) == 0 {
+			continue
+		}
+		var item record
+		if err := json.Unmarshal(data, &item); err != nil {
+			return models.Metric{}, fmt.Errorf("%w: line %d: %w", ErrInvalidRecord, d.line, err)
+		}
+		return validate(item.metric(), d.line)
+	}
+	if err := d.scanner.Err(); err != nil {
+		return models.Metric{}, err
+	}
+	return models.Metric{}, io.EOF
+}
+
+func toRecord(metric models.Metric) record {
+	return record{ID: metric.ID, Type: metric.Type, Value: metric.Value, Delta: metric.Delta}
+}
+
+func (r record) metric() models.Metric {
+	return models.Metric{ID: r.ID, Type: r.Type, Value: r.Value, Delta: r.Delta}
+}
+
+// validate metric has id and value matching its type, position is record number used in error
+func validate(metric models.Metric, position int) (models.Metric, error) {
+	if metric.ID == "" {
+		return models.Metric{}, fmt.Errorf("%w: record %d has no id", ErrInvalidRecord, position)
+	}
+	switch metric.Type {
+	case models.GaugeType:
+		if metric.Value == nil {
+			return models.Metric{}, fmt.Errorf("%w: gauge %s has no value", ErrInvalidRecord, metric.ID)
+		}
+		metric.Delta = nil
+	case models.CounterType:
+		if metric.Delta == nil {
+			return models.Metric{}, fmt.Errorf("%w: counter %s has no delta", ErrInvalidRecord, metric.ID)
+		}
+		metric.Value = nil
+	default:
+		return models.Metric{}, fmt.Errorf("%w: %s: %w", ErrInvalidRecord, metric.ID, models.ErrUnknownMetricType)
+	}
+	return metric, nil
+}
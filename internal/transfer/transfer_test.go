@@ -0,0 +1,97 @@
+package transfer
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DimKa163/go-metrics/internal/models"
+)
+
+func TestRoundTrip(t *testing.T) {
+	metrics := []models.Metric{
+		*models.CreateGauge(`disk_free{mount="/"}`, 0.1),
+		*models.CreateCounter("PollCount", -3),
+		*models.CreateGauge("with,comma", 1e21),
+	}
+	for _, format := range []string{FormatCSV, FormatJSON, FormatNDJSON} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			encoder, err := NewEncoder(&buf, format)
+			require.NoError(t, err)
+			for _, metric := range metrics {
+				require.NoError(t, encoder.Encode(metric))
+			}
+			require.NoError(t, encoder.Close())
+
+			decoded := decodeAll(t, &buf, format)
+			assert.Equal(t, metrics, decoded)
+		})
+	}
+}
+
+func TestEmpty(t *testing.T) {
+	for format, expected := range map[string]string{FormatCSV: "id,type,value,delta\n", FormatJSON: "[]\n", FormatNDJSON: ""} {
+		var buf bytes.Buffer
+		encoder, err := NewEncoder(&buf, format)
+		require.NoError(t, err)
+		require.NoError(t, encoder.Close())
+		assert.Equal(t, expected, buf.String(), format)
+		assert.Empty(t, decodeAll(t, &buf, format), format)
+	}
+}
+
+func TestDecodeCSVColumns(t *testing.T) {
+	input := "type,delta,id\ncounter,5,PollCount\n"
+	assert.Equal(t, []models.Metric{*models.CreateCounter("PollCount", 5)}, decodeAll(t, strings.NewReader(input), FormatCSV))
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	cases := map[string]struct {
+		format string
+		input  string
+	}{
+		"no id column":     {FormatCSV, "type,value\ngauge,1\n"},
+		"bad value":        {FormatCSV, "id,type,value\nAlloc,gauge,x\n"},
+		"gauge no value":   {FormatNDJSON, `{"id":"Alloc","type":"gauge","delta":1}`},
+		"unknown type":     {FormatJSON, `[{"id":"Alloc","type":"histogram","value":1}]`},
+		"no id":            {FormatJSON, `[{"type":"counter","delta":1}]`},
+		"not array":        {FormatJSON, `{"id":"Alloc"}`},
+		"counter no delta": {FormatNDJSON, `{"id":"PollCount","type":"counter","value":1}`},
+	}
+	for name, tc := range cases {
+		decoder, err := NewDecoder(strings.NewReader(tc.input), tc.format)
+		require.NoError(t, err)
+		_, err = decoder.Decode()
+		assert.ErrorIs(t, err, ErrInvalidRecord, name)
+	}
+	_, err := NewDecoder(strings.NewReader(""), "xml")
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+}
+
+func TestFormatOf(t *testing.T) {
+	assert.Equal(t, FormatCSV, FormatOf("text/csv; charset=utf-8"))
+	assert.Equal(t, FormatNDJSON, FormatOf("application/x-ndjson"))
+	assert.Equal(t, FormatJSON, FormatOf("json"))
+	assert.Empty(t, FormatOf("text/html"))
+}
+
+func decodeAll(t *testing.T, reader io.Reader, format string) []models.Metric {
+	t.Helper()
+	decoder, err := NewDecoder(reader, format)
+	require.NoError(t, err)
+	var result []models.Metric
+	for {
+		metric, err := decoder.Decode()
+		if errors.Is(err, io.EOF) {
+			return result
+		}
+		require.NoError(t, err)
+		result = append(result, metric)
+	}
+}
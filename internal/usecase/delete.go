@@ -0,0 +1,110 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/DimKa163/go-metrics/internal/audit"
+	"github.com/DimKa163/go-metrics/internal/models"
+)
+
+// Delete remove metric
+func (ms *MetricService) Delete(ctx context.Context, id string) error {
+	_, err := ms.delete(ctx, []string{id})
+	return err
+}
+
+// DeleteMatching remove metrics selected by type, prefix or pattern and return how many were removed
+func (ms *MetricService) DeleteMatching(ctx context.Context, req ListRequest) (int, error) {
+	ids, err := ms.matching(ctx, req)
+	if err != nil || len(ids) == 0 {
+		return 0, err
+	}
+	return ms.delete(ctx, ids)
+}
+
+// Reset set value of metric to zero
+func (ms *MetricService) Reset(ctx context.Context, id string) error {
+	_, err := ms.reset(ctx, []string{id})
+	return err
+}
+
+// ResetMatching zero metrics selected by type, prefix or pattern and return how many were reset
+func (ms *MetricService) ResetMatching(ctx context.Context, req ListRequest) (int, error) {
+	ids, err := ms.matching(ctx, req)
+	if err != nil || len(ids) == 0 {
+		return 0, err
+	}
+	return ms.reset(ctx, ids)
+}
+
+func (ms *MetricService) delete(ctx context.Context, ids []string) (int, error) {
+	deleted, err := ms.repository.Delete(ctx, ids)
+	if err != nil {
+		return 0, fmt.Errorf("db unhandled error %w", err)
+	}
+	if deleted == 0 {
+		return 0, ErrMetricNotFound
+	}
+	ms.removed(ctx, ids)
+	return deleted, nil
+}
+
+// removed forget history and names of deleted metrics and audit their removal
+func (ms *MetricService) removed(ctx context.Context, ids []string) {
+	tenant := models.TenantFromContext(ctx)
+	for _, id := range ids {
+		if ms.history != nil {
+			ms.history.Forget(tenant, id)
+		}
+	}
+	ms.cardinality.forget(ctx, ids)
+	ms.audit(ctx, audit.ActionDelete, ids)
+}
+
+func (ms *MetricService) reset(ctx context.Context, ids []string) (int, error) {
+	reset, err := ms.repository.Reset(ctx, ids)
+	if err != nil {
+		return 0, fmt.Errorf("db unhandled error %w", err)
+	}
+	if reset == 0 {
+		return 0, ErrMetricNotFound
+	}
+	// stored zeros keep type of metric, so counter history gets counter sample and subscribers see it reset
+	zero := make([]models.Metric, 0, len(ids))
+	for _, id := range ids {
+		metric, err := ms.repository.Find(ctx, id)
+		if err != nil {
+			continue
+		}
+		zero = append(zero, *metric)
+	}
+	ms.record(ctx, zero)
+	ms.publish(ctx, zero)
+	ms.audit(ctx, audit.ActionReset, ids)
+	return reset, nil
+}
+
+// matching ids of metrics selected by filter, filter can't be empty so nothing is removed by mistake
+func (ms *MetricService) matching(ctx context.Context, req ListRequest) ([]string, error) {
+	if req.Type == "" && req.Prefix == "" && req.Pattern == "" {
+		return nil, fmt.Errorf("%w: type, prefix or pattern is required", ErrInvalidFilter)
+	}
+	filter, err := listFilter(req)
+	if err != nil {
+		return nil, err
+	}
+	filter.Limit = 0
+	filter.After = nil
+	metrics, err := ms.repository.List(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("db unhandled error %w", err)
+	}
+	ids := make([]string, len(metrics))
+	for i, metric := range metrics {
+		ids[i] = metric.ID
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
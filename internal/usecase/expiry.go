@@ -0,0 +1,45 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/DimKa163/go-metrics/internal/audit"
+	"github.com/DimKa163/go-metrics/internal/models"
+)
+
+// Stale report whether metric was not updated within ttl, metrics without update time never go stale
+func (ms *MetricService) Stale(metric models.Metric) bool {
+	if ms.ttl <= 0 || metric.LastUpdated.IsZero() {
+		return false
+	}
+	return time.Since(metric.LastUpdated) > ms.ttl
+}
+
+// Expire remove metrics of every tenant not updated within gc and return how many were removed,
+// metrics which are only stale are kept
+func (ms *MetricService) Expire(ctx context.Context) (int, error) {
+	if ms.ttl <= 0 {
+		return 0, nil
+	}
+	removed, err := ms.repository.Expire(ctx, time.Now().Add(-ms.gc))
+	if err != nil {
+		return 0, fmt.Errorf("db unhandled error %w", err)
+	}
+	count := 0
+	for tenant, ids := range removed {
+		sort.Strings(ids)
+		tenantCtx := models.WithTenant(ctx, tenant)
+		if ms.history != nil {
+			for _, id := range ids {
+				ms.history.Forget(tenant, id)
+			}
+		}
+		ms.cardinality.forget(tenantCtx, ids)
+		ms.audit(tenantCtx, audit.ActionExpire, ids)
+		count += len(ids)
+	}
+	return count, nil
+}
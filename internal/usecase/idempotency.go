@@ -0,0 +1,97 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/DimKa163/go-metrics/internal/models"
+	"github.com/DimKa163/go-metrics/internal/persistence"
+)
+
+// maxIdempotencyKeyLength longest accepted idempotency key
+const maxIdempotencyKeyLength = 255
+
+// IdempotencyLease how long key stays pending when request holding it never finishes, e.g. keeper crashed
+const IdempotencyLease = 30 * time.Second
+
+var (
+	ErrInvalidIdempotencyKey = errors.New("idempotency key must be 1-255 printable ASCII characters")
+	ErrIdempotencyKeyPending = errors.New("request with idempotency key is in flight")
+)
+
+// IdempotencyService remember idempotency keys of applied requests for ttl, so retried request
+// is acknowledged without being applied twice. Key is pending while its request is applied
+// and done only once request succeeded
+type IdempotencyService struct {
+	repository persistence.IdempotencyRepository
+	ttl        time.Duration
+	lease      time.Duration
+}
+
+func NewIdempotencyService(repository persistence.IdempotencyRepository, ttl time.Duration) *IdempotencyService {
+	return &IdempotencyService{repository: repository, ttl: ttl, lease: min(ttl, IdempotencyLease)}
+}
+
+// Claim take key of tenant in context for request, false means request with the key was applied already
+// and ErrIdempotencyKeyPending that it is being applied now
+func (s *IdempotencyService) Claim(ctx context.Context, key string) (bool, error) {
+	if err := validateIdempotencyKey(key); err != nil {
+		return false, err
+	}
+	state, err := s.repository.ClaimKey(ctx, key, time.Now().Add(s.lease))
+	if err != nil {
+		return false, fmt.Errorf("db unhandled error %w", err)
+	}
+	switch state {
+	case persistence.KeyClaimed:
+		return true, nil
+	case persistence.KeyPending:
+		return false, ErrIdempotencyKeyPending
+	default:
+		return false, nil
+	}
+}
+
+// Bind attach claimed key to context, repository writing metrics in transaction completes key in it
+func (s *IdempotencyService) Bind(ctx context.Context, key string) context.Context {
+	return models.WithIdempotencyKey(ctx, models.IdempotencyKey{Key: key, Expires: time.Now().Add(s.ttl)})
+}
+
+// Complete mark key of applied request done, so its retry is acknowledged until ttl elapses
+func (s *IdempotencyService) Complete(ctx context.Context, key string) error {
+	if err := s.repository.CompleteKey(ctx, key, time.Now().Add(s.ttl)); err != nil {
+		return fmt.Errorf("db unhandled error %w", err)
+	}
+	return nil
+}
+
+// Release forget key of request which was not applied, so its retry is applied
+func (s *IdempotencyService) Release(ctx context.Context, key string) error {
+	if err := s.repository.ReleaseKey(ctx, key); err != nil {
+		return fmt.Errorf("db unhandled error %w", err)
+	}
+	return nil
+}
+
+// Purge remove expired keys of every tenant
+func (s *IdempotencyService) Purge(ctx context.Context) (int, error) {
+	removed, err := s.repository.PurgeKeys(ctx, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("db unhandled error %w", err)
+	}
+	return removed, nil
+}
+
+func validateIdempotencyKey(key string) error {
+	if key == "" || len(key) > maxIdempotencyKeyLength {
+		return ErrInvalidIdempotencyKey
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x21 || key[i] > 0x7e {
+			return ErrInvalidIdempotencyKey
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package usecase
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/DimKa163/go-metrics/internal/models"
+	"github.com/DimKa163/go-metrics/internal/persistence"
+)
+
+const (
+	DefaultPageSize = 100
+	MaxPageSize     = 1000
+)
+
+var (
+	ErrInvalidFilter = errors.New("invalid filter")
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// ListRequest filter and page of metric listing, empty fields select everything
+type ListRequest struct {
+	Type   string
+	Prefix string
+	// Pattern regular expression id must match
+	Pattern string
+	// Sort id or type, id by default
+	Sort string
+	Desc bool
+	// Cursor next page cursor returned with previous page
+	Cursor string
+	// Limit page size, DefaultPageSize when 0
+	Limit int
+}
+
+// MetricPage metrics of page, Next is empty on last page
+type MetricPage struct {
+	Metrics []models.Metric
+	Next    string
+}
+
+// List metrics of page ordered by id or by type and id
+func (ms *MetricService) List(ctx context.Context, req ListRequest) (MetricPage, error) {
+	filter, err := listFilter(req)
+	if err != nil {
+		return MetricPage{}, err
+	}
+	if ms.ttl > 0 {
+		filter.Since = time.Now().Add(-ms.ttl)
+	}
+	limit := filter.Limit
+	// one extra metric tells whether there is next page
+	filter.Limit++
+	metrics, err := ms.repository.List(ctx, filter)
+	if err != nil {
+		return MetricPage{}, fmt.Errorf("db unhandled error %w", err)
+	}
+	page := MetricPage{Metrics: metrics}
+	if len(metrics) > limit {
+		page.Metrics = metrics[:limit]
+		page.Next = encodeCursor(persistence.Key(&page.Metrics[limit-1]))
+	}
+	return page, nil
+}
+
+func listFilter(req ListRequest) (persistence.ListFilter, error) {
+	filter := persistence.ListFilter{Type: req.Type, Prefix: req.Prefix, Desc: req.Desc, Limit: req.Limit}
+	if req.Type != "" && req.Type != models.GaugeType && req.Type != models.CounterType {
+		return filter, fmt.Errorf("%w: %w", ErrInvalidFilter, models.ErrUnknownMetricType)
+	}
+	switch persistence.SortField(req.Sort) {
+	case "", persistence.SortByID:
+		filter.Sort = persistence.SortByID
+	case persistence.SortByType:
+		filter.Sort = persistence.SortByType
+	default:
+		return filter, fmt.Errorf("%w: unknown sort %q", ErrInvalidFilter, req.Sort)
+	}
+	if req.Pattern != "" {
+		pattern, err := regexp.Compile(req.Pattern)
+		if err != nil {
+			return filter, fmt.Errorf("%w: %w", ErrInvalidFilter, err)
+		}
+		filter.Pattern = pattern
+	}
+	switch {
+	case filter.Limit == 0:
+		filter.Limit = DefaultPageSize
+	case filter.Limit < 0 || filter.Limit > MaxPageSize:
+		return filter, fmt.Errorf("%w: limit must be 1-%d", ErrInvalidFilter, MaxPageSize)
+	}
+	if req.Cursor != "" {
+		key, err := decodeCursor(req.Cursor)
+		if err != nil {
+			return filter, err
+		}
+		filter.After = &key
+	}
+	return filter, nil
+}
+
+// encodeCursor opaque cursor holding type and id of last listed metric
+func encodeCursor(key persistence.ListKey) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(key.Type + "\x00" + key.ID))
+}
+
+func decodeCursor(cursor string) (persistence.ListKey, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return persistence.ListKey{}, ErrInvalidCursor
+	}
+	tt, id, ok := strings.Cut(string(raw), "\x00")
+	if !ok {
+		return persistence.ListKey{}, ErrInvalidCursor
+	}
+	return persistence.ListKey{Type: tt, ID: id}, nil
+}
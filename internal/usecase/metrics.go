@@ -4,23 +4,78 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
+	"time"
 
+	"github.com/DimKa163/go-metrics/internal/audit"
 	"github.com/DimKa163/go-metrics/internal/logging"
 	"github.com/DimKa163/go-metrics/internal/models"
 	"github.com/DimKa163/go-metrics/internal/persistence"
+	"github.com/DimKa163/go-metrics/internal/query"
 )
 
 var ErrMetricNotFound = errors.New("metric not found")
 
+// Auditor receive accepted updates, Record must not block
+type Auditor interface {
+	Record(event audit.Event)
+}
+
+// Publisher receive accepted updates for live subscribers, Publish must not block
+type Publisher interface {
+	Publish(tenant string, metrics []models.Metric)
+}
+
+// MetricOptions optional parts of MetricService, zero value accepts every metric and keeps no history
+type MetricOptions struct {
+	Policy MetricPolicy
+	// Auditor receive accepted updates, nil disables audit
+	Auditor Auditor
+	// History record values of accepted updates for range queries, nil disables it
+	History *query.History
+	// Publisher receive accepted updates, nil disables streaming
+	Publisher Publisher
+	// TTL metrics not updated within ttl are hidden from reads, 0 keeps them forever
+	TTL time.Duration
+	// GC metrics not updated within gc are removed by Expire, it's never shorter than TTL
+	// and 0 is MetricGCFactor times TTL, so hidden metric can still be read as stale for a while
+	GC time.Duration
+}
+
+// MetricGCFactor how many ttls hidden metrics are kept by default before they are removed
+const MetricGCFactor = 10
+
 type MetricService struct {
-	repository persistence.Repository
+	repository  persistence.Repository
+	policy      MetricPolicy
+	cardinality *cardinality
+	auditor     Auditor
+	publisher   Publisher
+	history     *query.History
+	ttl         time.Duration
+	gc          time.Duration
+	engine      *query.Engine
 }
 
-func NewMetricService(repository persistence.Repository) *MetricService {
-	return &MetricService{repository: repository}
+func NewMetricService(repository persistence.Repository, options MetricOptions) *MetricService {
+	ms := &MetricService{
+		repository:  repository,
+		policy:      options.Policy,
+		cardinality: newCardinality(options.TTL),
+		auditor:     options.Auditor,
+		publisher:   options.Publisher,
+		history:     options.History,
+		ttl:         options.TTL,
+		gc:          max(options.GC, options.TTL),
+	}
+	if options.GC <= 0 {
+		ms.gc = MetricGCFactor * options.TTL
+	}
+	ms.engine = query.NewEngine(queryStorage{ms}, query.DefaultLookback)
+	return ms
 }
 
-// Get get metric
+// Get get metric, expired counter is not found while expired gauge is returned and reported by Stale
 func (ms *MetricService) Get(ctx context.Context, id string) (models.Metric, error) {
 	model, err := ms.repository.Find(ctx, id)
 	if err != nil {
@@ -29,65 +84,177 @@ func (ms *MetricService) Get(ctx context.Context, id string) (models.Metric, err
 		}
 		return models.Metric{}, fmt.Errorf("db unhandled error %w", err)
 	}
+	if model.Type != models.GaugeType && ms.Stale(*model) {
+		return models.Metric{}, ErrMetricNotFound
+	}
 	return *model, nil
 }
 
-// GetAll get all metric
+// GetAll get all metric not expired yet
 func (ms *MetricService) GetAll(ctx context.Context) ([]models.Metric, error) {
-	return ms.repository.GetAll(ctx)
+	metrics, err := ms.repository.GetAll(ctx)
+	if err != nil || ms.ttl <= 0 {
+		return metrics, err
+	}
+	fresh := metrics[:0]
+	for _, metric := range metrics {
+		if !ms.Stale(metric) {
+			fresh = append(fresh, metric)
+		}
+	}
+	return fresh, nil
 }
 
-// Upsert create/update metric
+// Upsert create/update metric, metric violating policy is rejected
 func (ms *MetricService) Upsert(ctx context.Context, newMetric models.Metric) (models.Metric, error) {
+	if err := ms.policy.CheckName(newMetric.ID); err != nil {
+		return models.Metric{}, err
+	}
+	finish, err := ms.cardinality.admit(ctx, ms.repository, &ms.policy, []string{newMetric.ID}, nil)
+	if err != nil {
+		return models.Metric{}, err
+	}
 	m, err := ms.processMetric(ctx, newMetric)
 	if err != nil {
+		finish(false)
 		return models.Metric{}, err
 	}
 	err = ms.repository.Upsert(ctx, &m)
+	finish(err == nil)
 	if err != nil {
 		return models.Metric{}, fmt.Errorf("db unhandled error %w", err)
 	}
+	ms.record(ctx, []models.Metric{m})
+	ms.publish(ctx, []models.Metric{m})
+	ms.audit(ctx, audit.ActionUpdate, []string{m.ID})
 	return m, nil
 }
 
-// BatchUpdate create/update metrics
+// BatchUpdate create/update metrics, whole batch is rejected when any metric violates policy
 func (ms *MetricService) BatchUpdate(ctx context.Context, metricList []models.Metric) error {
+	return ms.update(ctx, metricList, true, nil)
+}
+
+// update store batch of metrics, counters are added to stored ones when merge is set and overwrite them otherwise.
+// Stored metrics listed in remove are deleted in the same write, so batch either replaces them or changes nothing
+func (ms *MetricService) update(ctx context.Context, metricList []models.Metric, merge bool, remove []string) error {
 	var err error
 	mapMetric := make(map[string]models.Metric)
 	for _, metric := range metricList {
+		if err = ms.policy.CheckName(metric.ID); err != nil {
+			return err
+		}
 		it, ok := mapMetric[metric.ID]
 		if ok {
 			switch it.Type {
 			case models.GaugeType:
 				mapMetric[metric.ID] = metric
 			case models.CounterType:
-				*it.Delta = *metric.Delta + *it.Delta
+				if merge {
+					*it.Delta = *metric.Delta + *it.Delta
+				} else {
+					it = metric
+				}
 				mapMetric[metric.ID] = it
 			}
 			continue
 		}
 		mapMetric[metric.ID] = metric
 	}
+	names := make([]string, 0, len(mapMetric))
+	for name := range mapMetric {
+		names = append(names, name)
+	}
+	finish, err := ms.cardinality.admit(ctx, ms.repository, &ms.policy, names, remove)
+	if err != nil {
+		return err
+	}
 	resultList := make([]models.Metric, 0)
 	var m models.Metric
 	for _, metric := range mapMetric {
+		if !merge {
+			resultList = append(resultList, metric)
+			continue
+		}
 		m, err = ms.processMetric(ctx, metric)
 		if err != nil {
+			finish(false)
 			return err
 		}
 		resultList = append(resultList, m)
 	}
-	if err = ms.repository.BatchUpsert(ctx, resultList); err != nil {
+	if len(remove) > 0 {
+		err = ms.repository.Replace(ctx, remove, resultList)
+	} else {
+		err = ms.repository.BatchUpsert(ctx, resultList)
+	}
+	finish(err == nil)
+	if err != nil {
 		return fmt.Errorf("db unhandled error %w", err)
 	}
+	if len(remove) > 0 {
+		ms.removed(ctx, remove)
+	}
+	ms.record(ctx, resultList)
+	ms.publish(ctx, resultList)
+	sort.Strings(names)
+	ms.audit(ctx, audit.ActionUpdate, names)
 	return nil
 }
 
+// record stored values in history
+func (ms *MetricService) record(ctx context.Context, metrics []models.Metric) {
+	if ms.history == nil {
+		return
+	}
+	tenant := models.TenantFromContext(ctx)
+	now := time.Now()
+	for _, metric := range metrics {
+		sample := query.Sample{Time: now}
+		switch {
+		case metric.Value != nil:
+			sample.Value = *metric.Value
+		case metric.Delta != nil:
+			sample.Value = float64(*metric.Delta)
+		default:
+			continue
+		}
+		ms.history.Add(tenant, metric.ID, sample)
+	}
+}
+
+// publish stored values to live subscribers
+func (ms *MetricService) publish(ctx context.Context, metrics []models.Metric) {
+	if ms.publisher == nil {
+		return
+	}
+	ms.publisher.Publish(models.TenantFromContext(ctx), metrics)
+}
+
+// audit record accepted change with client taken from context
+func (ms *MetricService) audit(ctx context.Context, action string, names []string) {
+	if ms.auditor == nil {
+		return
+	}
+	ms.auditor.Record(audit.Event{
+		Time:    time.Now().UTC(),
+		Action:  action,
+		Tenant:  models.TenantFromContext(ctx),
+		Agent:   models.AgentFromContext(ctx),
+		Address: models.AddressFromContext(ctx),
+		Metrics: names,
+	})
+}
+
 func (ms *MetricService) processMetric(ctx context.Context, metric models.Metric) (models.Metric, error) {
 	m, err := ms.repository.Find(ctx, metric.ID)
 	if err != nil && !errors.Is(err, persistence.ErrMetricNotFound) {
 		return models.Metric{}, fmt.Errorf("db unhandled error %w", err)
 	}
+	if m != nil && ms.Stale(*m) {
+		// expired metric starts over as if it was removed already
+		m = nil
+	}
 	if m == nil {
 		logging.Log.Info("metric not found. adding new metric")
 		m = &metric
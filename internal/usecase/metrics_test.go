@@ -2,14 +2,21 @@ package usecase
 
 import (
 	"context"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"github.com/DimKa163/go-metrics/internal/audit"
+	"github.com/DimKa163/go-metrics/internal/files"
 	"github.com/DimKa163/go-metrics/internal/mocks"
 	"github.com/DimKa163/go-metrics/internal/models"
 	"github.com/DimKa163/go-metrics/internal/persistence"
+	"github.com/DimKa163/go-metrics/internal/persistence/mem"
+	"github.com/DimKa163/go-metrics/internal/query"
 )
 
 func TestGetShouldReturnMetricWhenMetricExists(t *testing.T) {
@@ -18,7 +25,7 @@ func TestGetShouldReturnMetricWhenMetricExists(t *testing.T) {
 	ctx := context.Background()
 
 	mockRepository := mocks.NewMockRepository(ctrl)
-	service := NewMetricService(mockRepository)
+	service := NewMetricService(mockRepository, MetricOptions{})
 	metric := getTestCounterMetric(500)
 	id := metric.ID
 	mockRepository.EXPECT().Find(ctx, id).Return(&metric, nil)
@@ -35,7 +42,7 @@ func TestGetShouldReturnMetricWhenMetricDoesNotExist(t *testing.T) {
 	ctx := context.Background()
 
 	mockRepository := mocks.NewMockRepository(ctrl)
-	service := NewMetricService(mockRepository)
+	service := NewMetricService(mockRepository, MetricOptions{})
 	id := "NotExistsMetric"
 	metric := models.Metric{}
 	mockRepository.EXPECT().Find(ctx, id).Return(nil, persistence.ErrMetricNotFound)
@@ -51,7 +58,7 @@ func TestGetAllShouldSuccess(t *testing.T) {
 	ctx := context.Background()
 
 	mockRepository := mocks.NewMockRepository(ctrl)
-	service := NewMetricService(mockRepository)
+	service := NewMetricService(mockRepository, MetricOptions{})
 	metrics := []models.Metric{
 		getTestCounterMetric(5),
 		getTestGaugeMetric(23.32),
@@ -70,7 +77,7 @@ func TestUpdateGaugeWhenMetricExistShouldSuccess(t *testing.T) {
 	ctx := context.Background()
 
 	mockRepository := mocks.NewMockRepository(ctrl)
-	service := NewMetricService(mockRepository)
+	service := NewMetricService(mockRepository, MetricOptions{})
 	exitsMetric := getTestGaugeMetric(23.32)
 	newMetric := exitsMetric
 	value := float64(300.23)
@@ -91,7 +98,7 @@ func TestUpdateGaugeWhenMetricDoesNotExistShouldSuccess(t *testing.T) {
 	ctx := context.Background()
 
 	mockRepository := mocks.NewMockRepository(ctrl)
-	service := NewMetricService(mockRepository)
+	service := NewMetricService(mockRepository, MetricOptions{})
 	newMetric := getTestGaugeMetric(23.23)
 	id := newMetric.ID
 	mockRepository.EXPECT().Find(ctx, id).Return(nil, persistence.ErrMetricNotFound)
@@ -109,7 +116,7 @@ func TestUpdateCounterWhenMetricExistShouldSuccess(t *testing.T) {
 	ctx := context.Background()
 
 	mockRepository := mocks.NewMockRepository(ctrl)
-	service := NewMetricService(mockRepository)
+	service := NewMetricService(mockRepository, MetricOptions{})
 	exitsMetric := getTestCounterMetric(5)
 	expectedMetric := getTestCounterMetric(155)
 	newMetric := exitsMetric
@@ -133,7 +140,7 @@ func TestUpdateCounterWhenMetricDoesNotExistShouldSuccess(t *testing.T) {
 	ctx := context.Background()
 
 	mockRepository := mocks.NewMockRepository(ctrl)
-	service := NewMetricService(mockRepository)
+	service := NewMetricService(mockRepository, MetricOptions{})
 	newMetric := getTestCounterMetric(500)
 	id := newMetric.ID
 	mockRepository.EXPECT().Find(ctx, id).Return(nil, persistence.ErrMetricNotFound)
@@ -166,3 +173,83 @@ func getTestCounterMetric(delta int64) models.Metric {
 	}
 	return metric
 }
+
+type auditRecorder struct {
+	events []audit.Event
+}
+
+func (r *auditRecorder) Record(event audit.Event) {
+	r.events = append(r.events, event)
+}
+
+func TestMetricServiceAuditsAcceptedUpdates(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := models.WithAddress(models.WithAgent(models.WithTenant(context.Background(), "alpha"), "token:a"), "10.0.0.1")
+
+	mockRepository := mocks.NewMockRepository(ctrl)
+	recorder := &auditRecorder{}
+	service := NewMetricService(mockRepository, MetricOptions{Auditor: recorder})
+	mockRepository.EXPECT().Find(ctx, gomock.Any()).Return(nil, persistence.ErrMetricNotFound).Times(2)
+	mockRepository.EXPECT().BatchUpsert(ctx, gomock.Any()).Return(nil)
+
+	batch := []models.Metric{getTestGaugeMetric(1), getTestCounterMetric(1)}
+	assert.NoError(t, service.BatchUpdate(ctx, batch))
+	_, err := service.Upsert(ctx, models.Metric{ID: "", Type: models.GaugeType})
+	assert.ErrorIs(t, err, ErrInvalidMetricName)
+
+	require.Len(t, recorder.events, 1, "rejected updates are not audited")
+	event := recorder.events[0]
+	assert.Equal(t, "alpha", event.Tenant)
+	assert.Equal(t, "token:a", event.Agent)
+	assert.Equal(t, "10.0.0.1", event.Address)
+	assert.Equal(t, []string{"TestCounterMetric", "TestGaugeMetric"}, event.Metrics)
+}
+
+type publishRecorder struct {
+	metrics []models.Metric
+}
+
+func (r *publishRecorder) Publish(_ string, metrics []models.Metric) {
+	r.metrics = append(r.metrics, metrics...)
+}
+
+func TestMetricServiceResetPublishesTypedZero(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+
+	mockRepository := mocks.NewMockRepository(ctrl)
+	publisher := &publishRecorder{}
+	history := query.NewHistory(10)
+	service := NewMetricService(mockRepository, MetricOptions{Publisher: publisher, History: history})
+	zero := getTestCounterMetric(0)
+	mockRepository.EXPECT().Reset(ctx, []string{zero.ID}).Return(1, nil)
+	mockRepository.EXPECT().Find(ctx, zero.ID).Return(&zero, nil)
+
+	require.NoError(t, service.Reset(ctx, zero.ID))
+
+	assert.Equal(t, []models.Metric{zero}, publisher.metrics, "reset is published like update")
+	samples := history.Range(models.TenantFromContext(ctx), zero.ID, time.Time{}, time.Now())
+	require.Len(t, samples, 1)
+	assert.Equal(t, float64(0), samples[0].Value)
+}
+
+func TestMetricServiceResetRefreshesIdleMetric(t *testing.T) {
+	ctx := context.Background()
+	store, err := mem.NewStore(files.NewFiler(filepath.Join(t.TempDir(), "dump"), []int{1}), mem.StoreOption{})
+	require.NoError(t, err)
+	service := NewMetricService(store, MetricOptions{TTL: 50 * time.Millisecond, GC: 50 * time.Millisecond})
+	require.NoError(t, service.BatchUpdate(ctx, []models.Metric{getTestCounterMetric(5), getTestGaugeMetric(1.5)}))
+	time.Sleep(100 * time.Millisecond)
+
+	_, err = service.ResetMatching(ctx, ListRequest{Prefix: "Test"})
+	require.NoError(t, err)
+	removed, err := service.Expire(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, removed, "reset metric is not expired")
+	metric, err := service.Get(ctx, getTestCounterMetric(0).ID)
+	require.NoError(t, err)
+	assert.False(t, service.Stale(metric))
+	assert.Equal(t, int64(0), *metric.Delta)
+}
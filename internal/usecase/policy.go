@@ -0,0 +1,245 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DimKa163/go-metrics/internal/models"
+	"github.com/DimKa163/go-metrics/internal/persistence"
+)
+
+var (
+	ErrInvalidMetricName   = errors.New("invalid metric name")
+	ErrMetricNameTooLong   = errors.New("metric name is too long")
+	ErrMetricNameRejected  = errors.New("metric name is not allowed")
+	ErrMetricLimitExceeded = errors.New("too many distinct metrics")
+)
+
+// MetricPolicy restrict names and number of metrics accepted by MetricService, zero value accepts everything
+type MetricPolicy struct {
+	// NamePattern every metric name must match
+	NamePattern *regexp.Regexp
+	// MaxNameLength in bytes, 0 is unlimited
+	MaxNameLength int
+	// Allow glob patterns, when set name must match one of them
+	Allow []string
+	// Block glob patterns, matching names are rejected even when allowed
+	Block []string
+	// MaxTenantMetrics distinct metrics stored by tenant, 0 is unlimited
+	MaxTenantMetrics int
+	// MaxAgentMetrics distinct metrics written by single agent, 0 is unlimited
+	MaxAgentMetrics int
+}
+
+// ParseNamePatterns split comma separated glob patterns and check their syntax
+func ParseNamePatterns(value string) ([]string, error) {
+	var patterns []string
+	for _, pattern := range strings.Split(value, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("%q: %w", pattern, err)
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+// CheckName validate metric name against policy
+func (p *MetricPolicy) CheckName(name string) error {
+	if name == "" {
+		return fmt.Errorf("%w: name is empty", ErrInvalidMetricName)
+	}
+	if p.MaxNameLength > 0 && len(name) > p.MaxNameLength {
+		return fmt.Errorf("%w: %q is longer than %d", ErrMetricNameTooLong, name, p.MaxNameLength)
+	}
+	if p.NamePattern != nil && !p.NamePattern.MatchString(name) {
+		return fmt.Errorf("%w: %q does not match %s", ErrInvalidMetricName, name, p.NamePattern)
+	}
+	if len(p.Allow) > 0 && !matchAny(p.Allow, name) {
+		return fmt.Errorf("%w: %q is not in allow list", ErrMetricNameRejected, name)
+	}
+	if matchAny(p.Block, name) {
+		return fmt.Errorf("%w: %q is in block list", ErrMetricNameRejected, name)
+	}
+	return nil
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// cardinality remember distinct metric names of tenants and of agents writing to them.
+// Tenant names are loaded from repository on first write of tenant, metrics hidden by ttl are not counted
+type cardinality struct {
+	mutex   sync.Mutex
+	ttl     time.Duration
+	tenants map[string]*nameSet
+	// agents names written by agent per tenant, agent is dropped once all its names are forgotten
+	agents map[string]map[string]*nameSet
+}
+
+// nameSet names stored and names admitted by requests still writing them. Pending names are counted
+// per request, so request failing to write name doesn't drop it while another request writes it too
+type nameSet struct {
+	stored  map[string]struct{}
+	pending map[string]int
+}
+
+func newNameSet() *nameSet {
+	return &nameSet{stored: make(map[string]struct{}), pending: make(map[string]int)}
+}
+
+func (n *nameSet) has(name string) bool {
+	if _, ok := n.stored[name]; ok {
+		return true
+	}
+	return n.pending[name] > 0
+}
+
+// count names set would have with names added and freed ones removed, freed name still written
+// by other request keeps its slot
+func (n *nameSet) count(names []string, freed []string) int {
+	count := len(n.stored)
+	for name := range n.pending {
+		if _, ok := n.stored[name]; !ok {
+			count++
+		}
+	}
+	for _, name := range names {
+		if !n.has(name) {
+			count++
+		}
+	}
+	for _, name := range freed {
+		if _, ok := n.stored[name]; ok && n.pending[name] == 0 {
+			count--
+		}
+	}
+	return count
+}
+
+func (n *nameSet) empty() bool {
+	return len(n.stored) == 0 && len(n.pending) == 0
+}
+
+func newCardinality(ttl time.Duration) *cardinality {
+	return &cardinality{
+		ttl:     ttl,
+		tenants: make(map[string]*nameSet),
+		agents:  make(map[string]map[string]*nameSet),
+	}
+}
+
+// admit hold names written by request or reject all of them when any limit would be exceeded,
+// freed names are removed by the same request and don't count towards limits.
+// Returned finish must be called once write is over, names are recorded as stored when it succeeded
+func (c *cardinality) admit(ctx context.Context, repository persistence.Repository, policy *MetricPolicy, names []string, freed []string) (func(written bool), error) {
+	if policy.MaxTenantMetrics <= 0 && policy.MaxAgentMetrics <= 0 {
+		return func(bool) {}, nil
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	tenant := models.TenantFromContext(ctx)
+	agent := models.AgentFromContext(ctx)
+	var tenantNames, agentNames *nameSet
+	if policy.MaxTenantMetrics > 0 {
+		tenantNames = c.tenants[tenant]
+		if tenantNames == nil {
+			stored, err := repository.GetAll(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("db unhandled error %w", err)
+			}
+			tenantNames = newNameSet()
+			for _, metric := range stored {
+				if c.ttl <= 0 || metric.LastUpdated.IsZero() || time.Since(metric.LastUpdated) <= c.ttl {
+					tenantNames.stored[metric.ID] = struct{}{}
+				}
+			}
+			c.tenants[tenant] = tenantNames
+		}
+		if tenantNames.count(names, freed) > policy.MaxTenantMetrics {
+			return nil, fmt.Errorf("%w: tenant limit is %d", ErrMetricLimitExceeded, policy.MaxTenantMetrics)
+		}
+	}
+	if policy.MaxAgentMetrics > 0 && agent != "" {
+		agentNames = c.agents[tenant][agent]
+		if agentNames == nil {
+			agentNames = newNameSet()
+		}
+		if agentNames.count(names, freed) > policy.MaxAgentMetrics {
+			return nil, fmt.Errorf("%w: agent limit is %d", ErrMetricLimitExceeded, policy.MaxAgentMetrics)
+		}
+		if c.agents[tenant] == nil {
+			c.agents[tenant] = make(map[string]*nameSet)
+		}
+		c.agents[tenant][agent] = agentNames
+	}
+	sets := make([]*nameSet, 0, 2)
+	for _, set := range []*nameSet{tenantNames, agentNames} {
+		if set != nil {
+			sets = append(sets, set)
+			for _, name := range names {
+				set.pending[name]++
+			}
+		}
+	}
+	return func(written bool) {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		for _, set := range sets {
+			for _, name := range names {
+				if set.pending[name]--; set.pending[name] <= 0 {
+					delete(set.pending, name)
+				}
+				if written {
+					set.stored[name] = struct{}{}
+				}
+			}
+		}
+		if agentNames != nil && agentNames.empty() {
+			c.dropAgent(tenant, agent)
+		}
+	}, nil
+}
+
+// forget names removed from tenant, agents left without names are dropped
+func (c *cardinality) forget(ctx context.Context, names []string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	tenant := models.TenantFromContext(ctx)
+	if tenantNames := c.tenants[tenant]; tenantNames != nil {
+		for _, name := range names {
+			delete(tenantNames.stored, name)
+		}
+	}
+	for agent, agentNames := range c.agents[tenant] {
+		for _, name := range names {
+			delete(agentNames.stored, name)
+		}
+		if agentNames.empty() {
+			c.dropAgent(tenant, agent)
+		}
+	}
+}
+
+// dropAgent remove agent whose names were all forgotten, caller must hold mutex
+func (c *cardinality) dropAgent(tenant string, agent string) {
+	delete(c.agents[tenant], agent)
+	if len(c.agents[tenant]) == 0 {
+		delete(c.agents, tenant)
+	}
+}
@@ -0,0 +1,152 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DimKa163/go-metrics/internal/files"
+	"github.com/DimKa163/go-metrics/internal/mocks"
+	"github.com/DimKa163/go-metrics/internal/models"
+	"github.com/DimKa163/go-metrics/internal/persistence"
+	"github.com/DimKa163/go-metrics/internal/persistence/mem"
+)
+
+func TestMetricPolicyCheckName(t *testing.T) {
+	allow, err := ParseNamePatterns("Heap*, PollCount")
+	require.NoError(t, err)
+	policy := MetricPolicy{
+		NamePattern:   regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`),
+		MaxNameLength: 12,
+		Allow:         allow,
+		Block:         []string{"HeapReleased"},
+	}
+	cases := []struct {
+		name     string
+		expected error
+	}{
+		{"HeapAlloc", nil},
+		{"PollCount", nil},
+		{"", ErrInvalidMetricName},
+		{"HeapAllocationBytes", ErrMetricNameTooLong},
+		{"Heap-Alloc", ErrInvalidMetricName},
+		{"RandomValue", ErrMetricNameRejected},
+		{"HeapReleased", ErrMetricNameRejected},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.ErrorIs(t, policy.CheckName(c.name), c.expected)
+		})
+	}
+	_, err = ParseNamePatterns("Heap[")
+	assert.Error(t, err)
+}
+
+func TestMetricServiceCardinality(t *testing.T) {
+	store, err := mem.NewStore(files.NewFiler(filepath.Join(t.TempDir(), "dump"), []int{1}), mem.StoreOption{})
+	require.NoError(t, err)
+	alpha := models.WithTenant(context.Background(), "alpha")
+	require.NoError(t, store.Upsert(alpha, models.CreateGauge("Stored", 1)))
+	service := NewMetricService(store, MetricOptions{Policy: MetricPolicy{MaxTenantMetrics: 3, MaxAgentMetrics: 2}})
+
+	first := models.WithAgent(alpha, "ip:10.0.0.1")
+	second := models.WithAgent(alpha, "ip:10.0.0.2")
+	require.NoError(t, service.BatchUpdate(first, []models.Metric{*models.CreateGauge("A", 1), *models.CreateGauge("B", 1)}))
+	_, err = service.Upsert(first, *models.CreateGauge("C", 1))
+	assert.ErrorIs(t, err, ErrMetricLimitExceeded, "agent limit")
+	_, err = service.Upsert(first, *models.CreateGauge("A", 2))
+	assert.NoError(t, err, "known metric is always accepted")
+
+	err = service.BatchUpdate(second, []models.Metric{*models.CreateGauge("C", 1), *models.CreateGauge("D", 1)})
+	assert.ErrorIs(t, err, ErrMetricLimitExceeded, "tenant limit counts stored metrics")
+	_, err = service.Get(alpha, "C")
+	assert.ErrorIs(t, err, ErrMetricNotFound, "rejected batch is not written")
+
+	_, err = service.Upsert(models.WithTenant(second, "beta"), *models.CreateGauge("C", 1))
+	assert.NoError(t, err, "other tenant has own limit")
+}
+
+func TestMetricServiceCardinalityFailedWrite(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := models.WithAgent(context.Background(), "ip:10.0.0.1")
+	repository := mocks.NewMockRepository(ctrl)
+	service := NewMetricService(repository, MetricOptions{Policy: MetricPolicy{MaxTenantMetrics: 1, MaxAgentMetrics: 1}})
+
+	repository.EXPECT().GetAll(gomock.Any()).Return(nil, nil)
+	repository.EXPECT().Find(gomock.Any(), gomock.Any()).Return(nil, persistence.ErrMetricNotFound).Times(2)
+	repository.EXPECT().BatchUpsert(gomock.Any(), gomock.Any()).Return(errors.New("connection lost"))
+	assert.Error(t, service.BatchUpdate(ctx, []models.Metric{*models.CreateGauge("A", 1)}))
+
+	repository.EXPECT().BatchUpsert(gomock.Any(), gomock.Any()).Return(nil)
+	assert.NoError(t, service.BatchUpdate(ctx, []models.Metric{*models.CreateGauge("B", 1)}),
+		"names of failed write don't take slots")
+}
+
+func TestCardinalityForgetDropsAgents(t *testing.T) {
+	c := newCardinality(0)
+	policy := &MetricPolicy{MaxAgentMetrics: 2}
+	ctx := models.WithAgent(models.WithTenant(context.Background(), "alpha"), "ip:10.0.0.1")
+	finish, err := c.admit(ctx, nil, policy, []string{"A", "B"}, nil)
+	require.NoError(t, err)
+	finish(true)
+
+	c.forget(ctx, []string{"A"})
+	assert.Len(t, c.agents["alpha"]["ip:10.0.0.1"].stored, 1)
+	c.forget(ctx, []string{"B"})
+	assert.Empty(t, c.agents, "agent without names is dropped")
+
+	finish, err = c.admit(ctx, nil, policy, []string{"C"}, nil)
+	require.NoError(t, err)
+	finish(false)
+	assert.Empty(t, c.agents, "failed write drops agent as well")
+}
+
+func TestCardinalityConcurrentFailedWrite(t *testing.T) {
+	c := newCardinality(0)
+	policy := &MetricPolicy{MaxAgentMetrics: 1}
+	ctx := models.WithAgent(models.WithTenant(context.Background(), "alpha"), "ip:10.0.0.1")
+	failed, err := c.admit(ctx, nil, policy, []string{"A"}, nil)
+	require.NoError(t, err)
+	written, err := c.admit(ctx, nil, policy, []string{"A"}, nil)
+	require.NoError(t, err, "name held by other request is known")
+
+	written(true)
+	failed(false)
+	_, err = c.admit(ctx, nil, policy, []string{"B"}, nil)
+	assert.ErrorIs(t, err, ErrMetricLimitExceeded, "name written by other request still takes slot")
+
+	failed, err = c.admit(ctx, nil, policy, []string{"A"}, nil)
+	require.NoError(t, err)
+	_, err = c.admit(ctx, nil, policy, []string{"B"}, []string{"A"})
+	assert.ErrorIs(t, err, ErrMetricLimitExceeded, "name being written can't be freed")
+	failed(false)
+	finish, err := c.admit(ctx, nil, policy, []string{"B"}, []string{"A"})
+	assert.NoError(t, err)
+	finish(true)
+}
+
+func TestCardinalitySkipsExpiredMetrics(t *testing.T) {
+	store, err := mem.NewStore(files.NewFiler(filepath.Join(t.TempDir(), "dump"), []int{1}), mem.StoreOption{})
+	require.NoError(t, err)
+	ctx := context.Background()
+	stale := models.CreateGauge("Stale", 1)
+	require.NoError(t, store.Upsert(ctx, stale))
+	time.Sleep(50 * time.Millisecond)
+	service := NewMetricService(store, MetricOptions{
+		TTL:    20 * time.Millisecond,
+		Policy: MetricPolicy{MaxTenantMetrics: 1},
+	})
+
+	_, err = service.Upsert(ctx, *models.CreateGauge("Fresh", 1))
+	assert.NoError(t, err, "expired metric doesn't take slot")
+	_, err = service.Upsert(ctx, *models.CreateGauge("Other", 1))
+	assert.ErrorIs(t, err, ErrMetricLimitExceeded)
+}
@@ -0,0 +1,231 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/DimKa163/go-metrics/internal/models"
+	"github.com/DimKa163/go-metrics/internal/persistence"
+)
+
+var (
+	ErrGroupNotFound = errors.New("group not found")
+	ErrMissingValue  = errors.New("metric value is missing")
+)
+
+// labels metrics of group are namespaced with
+const (
+	JobLabel      = "job"
+	InstanceLabel = "instance"
+)
+
+// PushService keep metrics pushed by short-lived jobs. Metrics of group are stored through MetricService
+// with job and instance labels added to their ids, so pushed metric is read, queried, streamed and
+// exported like any other one, and every stored metric labelled with job belongs to a group
+type PushService struct {
+	// mutex keep replace of group from interleaving with other changes of groups
+	mutex   sync.Mutex
+	metrics *MetricService
+	tenants persistence.TenantLister
+}
+
+// NewPushService create push service storing groups through metrics, tenants is used to expire groups
+// of every tenant and can be nil when groups never expire
+func NewPushService(metrics *MetricService, tenants persistence.TenantLister) *PushService {
+	return &PushService{
+		metrics: metrics,
+		tenants: tenants,
+	}
+}
+
+// Push store metrics in group, replace drops metrics missing in the request otherwise counters are added up
+func (ps *PushService) Push(ctx context.Context, key models.GroupKey, metrics []models.Metric, replace bool) error {
+	list := make([]models.Metric, len(metrics))
+	for i, metric := range metrics {
+		if err := models.ValidateMetric(&metric); err != nil {
+			return err
+		}
+		if metric.Type == models.GaugeType && metric.Value == nil || metric.Type == models.CounterType && metric.Delta == nil {
+			return ErrMissingValue
+		}
+		list[i] = copyMetric(metric)
+		list[i].ID = groupID(key, metric.ID)
+	}
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+	if !replace {
+		return ps.metrics.update(ctx, list, true, nil)
+	}
+	stored, err := ps.group(ctx, key)
+	if err != nil {
+		return err
+	}
+	pushed := make(map[string]struct{}, len(list))
+	for _, metric := range list {
+		pushed[metric.ID] = struct{}{}
+	}
+	var missing []string
+	for _, metric := range stored {
+		if _, ok := pushed[metric.ID]; !ok {
+			missing = append(missing, metric.ID)
+		}
+	}
+	// missing metrics are removed in the same write, so rejected or failed replace keeps the old group
+	return ps.metrics.update(ctx, list, false, missing)
+}
+
+// Delete remove whole group
+func (ps *PushService) Delete(ctx context.Context, key models.GroupKey) error {
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+	stored, err := ps.group(ctx, key)
+	if err != nil {
+		return err
+	}
+	if len(stored) == 0 {
+		return ErrGroupNotFound
+	}
+	if _, err = ps.metrics.delete(ctx, metricIDs(stored)); err != nil {
+		if errors.Is(err, ErrMetricNotFound) {
+			return ErrGroupNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// Groups all groups of tenant ordered by job and instance, metric ids are reported without group labels
+func (ps *PushService) Groups(ctx context.Context) ([]models.Group, error) {
+	metrics, err := ps.metrics.GetAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("db unhandled error %w", err)
+	}
+	groups := make(map[models.GroupKey]*models.Group)
+	for _, metric := range metrics {
+		key, id, ok := splitGroupID(metric.ID)
+		if !ok {
+			continue
+		}
+		group, ok := groups[key]
+		if !ok {
+			group = &models.Group{GroupKey: key}
+			groups[key] = group
+		}
+		if metric.LastUpdated.After(group.UpdatedAt) {
+			group.UpdatedAt = metric.LastUpdated
+		}
+		metric.ID = id
+		metric.LastUpdated = time.Time{}
+		group.Metrics = append(group.Metrics, metric)
+	}
+	result := make([]models.Group, 0, len(groups))
+	for _, group := range groups {
+		sort.Slice(group.Metrics, func(i, j int) bool { return group.Metrics[i].ID < group.Metrics[j].ID })
+		result = append(result, *group)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Job != result[j].Job {
+			return result[i].Job < result[j].Job
+		}
+		return result[i].Instance < result[j].Instance
+	})
+	return result, nil
+}
+
+// Expire remove groups of every tenant not updated within ttl and return how many were removed
+func (ps *PushService) Expire(ctx context.Context, ttl time.Duration) (int, error) {
+	if ps.tenants == nil {
+		return 0, nil
+	}
+	tenants, err := ps.tenants.Tenants(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("db unhandled error %w", err)
+	}
+	deadline := time.Now().Add(-ttl)
+	removed := 0
+	for _, tenant := range tenants {
+		tenantCtx := models.WithTenant(ctx, tenant)
+		groups, err := ps.Groups(tenantCtx)
+		if err != nil {
+			return removed, err
+		}
+		for _, group := range groups {
+			if !group.UpdatedAt.Before(deadline) {
+				continue
+			}
+			if err = ps.Delete(tenantCtx, group.GroupKey); err != nil && !errors.Is(err, ErrGroupNotFound) {
+				return removed, err
+			}
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// group stored metrics of group with their full ids
+func (ps *PushService) group(ctx context.Context, key models.GroupKey) ([]models.Metric, error) {
+	metrics, err := ps.metrics.GetAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("db unhandled error %w", err)
+	}
+	var result []models.Metric
+	for _, metric := range metrics {
+		if k, _, ok := splitGroupID(metric.ID); ok && k == key {
+			result = append(result, metric)
+		}
+	}
+	return result, nil
+}
+
+// groupID id metric of group is stored under, job and instance labels of pushed id are overwritten
+func groupID(key models.GroupKey, id string) string {
+	name, labels := models.ParseID(id)
+	if labels == nil {
+		labels = make(map[string]string, 2)
+	}
+	labels[JobLabel] = key.Job
+	delete(labels, InstanceLabel)
+	if key.Instance != "" {
+		labels[InstanceLabel] = key.Instance
+	}
+	return models.ComposeID(name, labels)
+}
+
+// splitGroupID group of stored id and id metric was pushed with, ok is false for id without job label
+func splitGroupID(id string) (models.GroupKey, string, bool) {
+	name, labels := models.ParseID(id)
+	job, ok := labels[JobLabel]
+	if !ok {
+		return models.GroupKey{}, "", false
+	}
+	key := models.GroupKey{Job: job, Instance: labels[InstanceLabel]}
+	delete(labels, JobLabel)
+	delete(labels, InstanceLabel)
+	return key, models.ComposeID(name, labels), true
+}
+
+func metricIDs(metrics []models.Metric) []string {
+	result := make([]string, len(metrics))
+	for i, metric := range metrics {
+		result[i] = metric.ID
+	}
+	sort.Strings(result)
+	return result
+}
+
+// copyMetric detach value pointers so stored metric can't be changed by caller
+func copyMetric(metric models.Metric) models.Metric {
+	if metric.Delta != nil {
+		delta := *metric.Delta
+		metric.Delta = &delta
+	}
+	if metric.Value != nil {
+		value := *metric.Value
+		metric.Value = &value
+	}
+	return metric
+}
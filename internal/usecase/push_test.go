@@ -0,0 +1,179 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DimKa163/go-metrics/internal/files"
+	"github.com/DimKa163/go-metrics/internal/models"
+	"github.com/DimKa163/go-metrics/internal/persistence/mem"
+	"github.com/DimKa163/go-metrics/internal/persistence/pg"
+)
+
+func newTestPushService(t *testing.T) (*PushService, *MetricService) {
+	store, err := mem.NewStore(files.NewFiler(filepath.Join(t.TempDir(), "dump"), []int{1}), mem.StoreOption{})
+	require.NoError(t, err)
+	metrics := NewMetricService(store, MetricOptions{})
+	return NewPushService(metrics, store), metrics
+}
+
+// groupMetrics groups of tenant in context
+func groupMetrics(t *testing.T, service *PushService, ctx context.Context) []models.Group {
+	groups, err := service.Groups(ctx)
+	require.NoError(t, err)
+	return groups
+}
+
+func TestPushMergeAddsCounters(t *testing.T) {
+	ctx := context.Background()
+	service, metrics := newTestPushService(t)
+	key := models.GroupKey{Job: "backup", Instance: "db1"}
+
+	require.NoError(t, service.Push(ctx, key, []models.Metric{getTestCounterMetric(5), getTestGaugeMetric(1.5)}, false))
+	require.NoError(t, service.Push(ctx, key, []models.Metric{getTestCounterMetric(7)}, false))
+
+	groups := groupMetrics(t, service, ctx)
+	require.Len(t, groups, 1)
+	assert.Equal(t, key, groups[0].GroupKey)
+	assert.False(t, groups[0].UpdatedAt.IsZero())
+	assert.Equal(t, []models.Metric{getTestCounterMetric(12), getTestGaugeMetric(1.5)}, groups[0].Metrics)
+
+	stored, err := metrics.Get(ctx, `TestCounterMetric{instance="db1",job="backup"}`)
+	require.NoError(t, err, "pushed metric is stored with group labels")
+	assert.Equal(t, int64(12), *stored.Delta)
+}
+
+func TestPushReplaceDropsMissingMetrics(t *testing.T) {
+	ctx := context.Background()
+	service, metrics := newTestPushService(t)
+	key := models.GroupKey{Job: "backup"}
+
+	require.NoError(t, service.Push(ctx, key, []models.Metric{getTestCounterMetric(5), getTestGaugeMetric(1.5)}, false))
+	require.NoError(t, service.Push(ctx, models.GroupKey{Job: "backup", Instance: "db1"}, []models.Metric{getTestGaugeMetric(3)}, false))
+	require.NoError(t, service.Push(ctx, key, []models.Metric{getTestCounterMetric(7)}, true))
+
+	groups := groupMetrics(t, service, ctx)
+	require.Len(t, groups, 2)
+	assert.Equal(t, []models.Metric{getTestCounterMetric(7)}, groups[0].Metrics)
+	assert.Equal(t, []models.Metric{getTestGaugeMetric(3)}, groups[1].Metrics, "other group is kept")
+	_, err := metrics.Get(ctx, `TestGaugeMetric{job="backup"}`)
+	assert.ErrorIs(t, err, ErrMetricNotFound)
+}
+
+func TestPushRejectsMetricWithoutValue(t *testing.T) {
+	service, _ := newTestPushService(t)
+	err := service.Push(context.Background(), models.GroupKey{Job: "backup"}, []models.Metric{{ID: "Empty", Type: models.CounterType}}, false)
+	assert.ErrorIs(t, err, ErrMissingValue)
+	assert.Empty(t, groupMetrics(t, service, context.Background()))
+}
+
+func TestDeleteAndExpireGroups(t *testing.T) {
+	ctx := context.Background()
+	service, metrics := newTestPushService(t)
+	require.NoError(t, service.Push(ctx, models.GroupKey{Job: "a"}, []models.Metric{getTestGaugeMetric(1)}, false))
+	require.NoError(t, service.Push(ctx, models.GroupKey{Job: "b"}, []models.Metric{getTestGaugeMetric(2)}, false))
+	require.NoError(t, metrics.BatchUpdate(ctx, []models.Metric{getTestGaugeMetric(3)}))
+
+	require.NoError(t, service.Delete(ctx, models.GroupKey{Job: "a"}))
+	assert.ErrorIs(t, service.Delete(ctx, models.GroupKey{Job: "a"}), ErrGroupNotFound)
+
+	removed, err := service.Expire(ctx, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 0, removed)
+	removed, err = service.Expire(ctx, -time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	assert.Empty(t, groupMetrics(t, service, ctx))
+
+	_, err = metrics.Get(ctx, "TestGaugeMetric")
+	assert.NoError(t, err, "metric outside of groups is kept")
+}
+
+func TestPushFollowsMetricPolicy(t *testing.T) {
+	store, err := mem.NewStore(files.NewFiler(filepath.Join(t.TempDir(), "dump"), []int{1}), mem.StoreOption{})
+	require.NoError(t, err)
+	metrics := NewMetricService(store, MetricOptions{Policy: MetricPolicy{Block: []string{"Debug*"}, MaxTenantMetrics: 2}})
+	service := NewPushService(metrics, store)
+	ctx := context.Background()
+	key := models.GroupKey{Job: "backup"}
+
+	err = service.Push(ctx, key, []models.Metric{*models.CreateGauge("DebugValue", 1)}, false)
+	assert.ErrorIs(t, err, ErrMetricNameRejected)
+	err = service.Push(ctx, key, []models.Metric{*models.CreateGauge("DebugValue", 1)}, true)
+	assert.ErrorIs(t, err, ErrMetricNameRejected)
+
+	require.NoError(t, service.Push(ctx, key, []models.Metric{*models.CreateGauge("A", 1), *models.CreateGauge("B", 1)}, false))
+	err = service.Push(ctx, models.GroupKey{Job: "restore"}, []models.Metric{*models.CreateGauge("A", 1)}, false)
+	assert.ErrorIs(t, err, ErrMetricLimitExceeded)
+	require.NoError(t, service.Push(ctx, key, []models.Metric{*models.CreateGauge("C", 1)}, true),
+		"replaced metrics free their slots")
+}
+
+// failingReplaceStore store whose replace always fails
+type failingReplaceStore struct {
+	*mem.MemoryStore
+}
+
+func (s failingReplaceStore) Replace(context.Context, []string, []models.Metric) error {
+	return errors.New("replace failed")
+}
+
+func TestPushFailedReplaceKeepsGroup(t *testing.T) {
+	ctx := context.Background()
+	store, err := mem.NewStore(files.NewFiler(filepath.Join(t.TempDir(), "dump"), []int{1}), mem.StoreOption{})
+	require.NoError(t, err)
+	key := models.GroupKey{Job: "backup"}
+	old := []models.Metric{getTestCounterMetric(5), getTestGaugeMetric(1.5)}
+
+	limited := NewPushService(NewMetricService(store, MetricOptions{Policy: MetricPolicy{MaxTenantMetrics: 2}}), store)
+	require.NoError(t, limited.Push(ctx, key, old, false))
+	err = limited.Push(ctx, key, []models.Metric{*models.CreateGauge("A", 1), *models.CreateGauge("B", 1), *models.CreateGauge("C", 1)}, true)
+	assert.ErrorIs(t, err, ErrMetricLimitExceeded)
+	groups := groupMetrics(t, limited, ctx)
+	require.Len(t, groups, 1)
+	assert.Equal(t, old, groups[0].Metrics, "rejected replace keeps old group")
+
+	failing := failingReplaceStore{store}
+	broken := NewPushService(NewMetricService(failing, MetricOptions{}), failing)
+	assert.Error(t, broken.Push(ctx, key, []models.Metric{getTestGaugeMetric(3)}, true))
+	groups = groupMetrics(t, broken, ctx)
+	require.Len(t, groups, 1)
+	assert.Equal(t, old, groups[0].Metrics, "failed replace keeps old group")
+}
+
+// TestPushLabelledGroupToDatabase needs postgres, DSN is taken from TEST_DATABASE_DSN
+func TestPushLabelledGroupToDatabase(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+	// migrations are read relative to repository root like in keeper
+	t.Chdir(filepath.Join("..", ".."))
+	ctx := models.WithTenant(context.Background(), "push-test-"+strconv.FormatInt(time.Now().UnixNano(), 36))
+	pool, err := pgxpool.New(ctx, dsn)
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+	store, err := pg.NewStore(pool, []int{1})
+	require.NoError(t, err)
+	metrics := NewMetricService(store, MetricOptions{Policy: MetricPolicy{MaxNameLength: pg.MaxIDLength}})
+	service := NewPushService(metrics, store)
+	key := models.GroupKey{Job: "nightly-backup", Instance: "db1.example.com:9100"}
+	t.Cleanup(func() { _ = service.Delete(context.WithoutCancel(ctx), key) })
+
+	pushed := *models.CreateCounter(`http_requests_total{code="200",method="GET"}`, 3)
+	require.NoError(t, service.Push(ctx, key, []models.Metric{pushed}, false))
+
+	groups := groupMetrics(t, service, ctx)
+	require.Len(t, groups, 1)
+	assert.Equal(t, key, groups[0].GroupKey)
+	assert.Equal(t, []models.Metric{pushed}, groups[0].Metrics)
+}
@@ -0,0 +1,48 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"github.com/DimKa163/go-metrics/internal/models"
+	"github.com/DimKa163/go-metrics/internal/query"
+)
+
+// Query evaluate query at moment, zero moment evaluates current values
+func (ms *MetricService) Query(ctx context.Context, q string, at time.Time) (query.Result, error) {
+	expr, err := query.Parse(q)
+	if err != nil {
+		return query.Result{}, err
+	}
+	return ms.engine.Instant(ctx, expr, at)
+}
+
+// QueryRange evaluate query at every step between start and end
+func (ms *MetricService) QueryRange(ctx context.Context, q string, start time.Time, end time.Time, step time.Duration) (query.Result, error) {
+	expr, err := query.Parse(q)
+	if err != nil {
+		return query.Result{}, err
+	}
+	return ms.engine.Range(ctx, expr, start, end, step)
+}
+
+// queryStorage metrics of tenant in context for query engine
+type queryStorage struct {
+	ms *MetricService
+}
+
+func (s queryStorage) Metrics(ctx context.Context) ([]models.Metric, error) {
+	return s.ms.GetAll(ctx)
+}
+
+func (s queryStorage) Samples(ctx context.Context, id string, from time.Time, to time.Time) []query.Sample {
+	return s.ms.Samples(ctx, id, from, to)
+}
+
+// Samples recorded values of metric within (from, to], nil when history is disabled
+func (ms *MetricService) Samples(ctx context.Context, id string, from time.Time, to time.Time) []query.Sample {
+	if ms.history == nil {
+		return nil
+	}
+	return ms.history.Range(models.TenantFromContext(ctx), id, from, to)
+}
@@ -0,0 +1,134 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/DimKa163/go-metrics/internal/models"
+	"github.com/DimKa163/go-metrics/internal/persistence"
+)
+
+var (
+	ErrInvalidToken   = errors.New("invalid token")
+	ErrTokenNotFound  = errors.New("token not found")
+	ErrForeignTenant  = errors.New("tokens of other tenants can't be managed")
+	ErrScopesRequired = errors.New("at least one scope is required")
+)
+
+// rootTokenID id of admin token set in keeper config
+const rootTokenID = "root"
+
+// TokenService issue, revoke and check tenant API tokens.
+// Tenant is taken from context, admins of DefaultTenant manage tokens of every tenant.
+type TokenService struct {
+	repository persistence.TokenRepository
+	rootToken  string
+}
+
+// NewTokenService create service, rootToken is admin token of DefaultTenant which is never stored
+func NewTokenService(repository persistence.TokenRepository, rootToken string) *TokenService {
+	return &TokenService{repository: repository, rootToken: rootToken}
+}
+
+// Authenticate find token by its secret
+func (ts *TokenService) Authenticate(ctx context.Context, secret string) (*models.Token, error) {
+	if secret == "" {
+		return nil, ErrInvalidToken
+	}
+	if ts.rootToken != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(ts.rootToken)) == 1 {
+		return &models.Token{ID: rootTokenID, Tenant: models.DefaultTenant, Scopes: []models.Scope{models.ScopeAdmin}}, nil
+	}
+	token, err := ts.repository.FindToken(ctx, hashSecret(secret))
+	if err != nil {
+		if errors.Is(err, persistence.ErrTokenNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, fmt.Errorf("db unhandled error %w", err)
+	}
+	return token, nil
+}
+
+// Create issue token for tenant and return it with secret, secret can't be recovered later
+func (ts *TokenService) Create(ctx context.Context, tenant string, scopes []models.Scope) (models.Token, string, error) {
+	if err := models.ValidateTenant(tenant); err != nil {
+		return models.Token{}, "", err
+	}
+	if err := ts.checkTenant(ctx, tenant); err != nil {
+		return models.Token{}, "", err
+	}
+	if len(scopes) == 0 {
+		return models.Token{}, "", ErrScopesRequired
+	}
+	id, err := randomHex(8)
+	if err != nil {
+		return models.Token{}, "", err
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		return models.Token{}, "", err
+	}
+	secret = id + "." + secret
+	token := models.Token{
+		ID:        id,
+		Tenant:    tenant,
+		Scopes:    scopes,
+		Hash:      hashSecret(secret),
+		CreatedAt: time.Now().UTC(),
+	}
+	if err = ts.repository.CreateToken(ctx, &token); err != nil {
+		return models.Token{}, "", fmt.Errorf("db unhandled error %w", err)
+	}
+	return token, secret, nil
+}
+
+// List tokens visible to context tenant
+func (ts *TokenService) List(ctx context.Context) ([]models.Token, error) {
+	return ts.repository.ListTokens(ctx, ts.managedTenant(ctx))
+}
+
+// Revoke delete token, it stops working immediately
+func (ts *TokenService) Revoke(ctx context.Context, id string) error {
+	err := ts.repository.DeleteToken(ctx, ts.managedTenant(ctx), id)
+	if err != nil {
+		if errors.Is(err, persistence.ErrTokenNotFound) {
+			return ErrTokenNotFound
+		}
+		return fmt.Errorf("db unhandled error %w", err)
+	}
+	return nil
+}
+
+func (ts *TokenService) checkTenant(ctx context.Context, tenant string) error {
+	if managed := ts.managedTenant(ctx); managed != "" && managed != tenant {
+		return ErrForeignTenant
+	}
+	return nil
+}
+
+// managedTenant tenant whose tokens context may manage, empty means every tenant
+func (ts *TokenService) managedTenant(ctx context.Context) string {
+	tenant := models.TenantFromContext(ctx)
+	if tenant == models.DefaultTenant {
+		return ""
+	}
+	return tenant
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,83 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/DimKa163/go-metrics/internal/models"
+	"github.com/DimKa163/go-metrics/internal/persistence"
+)
+
+const (
+	// exportPage metrics read from repository at once during export
+	exportPage = 1000
+)
+
+// ImportMode how imported counters are applied, gauges always overwrite stored values
+type ImportMode string
+
+const (
+	// ImportMerge add imported counters to stored ones
+	ImportMerge ImportMode = "merge"
+	// ImportReplace overwrite stored counters with imported ones
+	ImportReplace ImportMode = "replace"
+)
+
+var (
+	ErrInvalidImportMode = errors.New("import mode must be merge or replace")
+	// ErrInvalidImport imported data can't be read
+	ErrInvalidImport = errors.New("invalid import")
+)
+
+// Export pass every metric of tenant not expired yet to fn in id order, repository is read page by page
+func (ms *MetricService) Export(ctx context.Context, fn func(metric models.Metric) error) error {
+	filter := persistence.ListFilter{Sort: persistence.SortByID, Limit: exportPage}
+	if ms.ttl > 0 {
+		filter.Since = time.Now().Add(-ms.ttl)
+	}
+	for {
+		metrics, err := ms.repository.List(ctx, filter)
+		if err != nil {
+			return fmt.Errorf("db unhandled error %w", err)
+		}
+		for _, metric := range metrics {
+			if err = fn(metric); err != nil {
+				return err
+			}
+		}
+		if len(metrics) < exportPage {
+			return nil
+		}
+		last := persistence.Key(&metrics[len(metrics)-1])
+		filter.After = &last
+	}
+}
+
+// Import store metrics returned by next until io.EOF in one write passing the same policy as updates,
+// so failed import leaves nothing applied and request can be retried with the same idempotency key
+func (ms *MetricService) Import(ctx context.Context, next func() (models.Metric, error), mode ImportMode) (int, error) {
+	if mode != ImportMerge && mode != ImportReplace {
+		return 0, ErrInvalidImportMode
+	}
+	metrics := make([]models.Metric, 0)
+	for {
+		metric, err := next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("%w: %w", ErrInvalidImport, err)
+		}
+		metrics = append(metrics, metric)
+	}
+	if len(metrics) == 0 {
+		return 0, nil
+	}
+	if err := ms.update(ctx, metrics, mode == ImportMerge, nil); err != nil {
+		return 0, err
+	}
+	return len(metrics), nil
+}
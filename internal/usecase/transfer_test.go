@@ -0,0 +1,74 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DimKa163/go-metrics/internal/files"
+	"github.com/DimKa163/go-metrics/internal/models"
+	"github.com/DimKa163/go-metrics/internal/persistence/mem"
+)
+
+// failingBatchStore store whose batch writes fail while fail is set
+type failingBatchStore struct {
+	*mem.MemoryStore
+	fail *bool
+}
+
+func (s failingBatchStore) BatchUpsert(ctx context.Context, metrics []models.Metric) error {
+	if *s.fail {
+		return errors.New("connection lost")
+	}
+	return s.MemoryStore.BatchUpsert(ctx, metrics)
+}
+
+// importStream metrics of counters with delta 1, the last one fails to decode when broken is set
+func importStream(count int, broken bool) func() (models.Metric, error) {
+	i := 0
+	return func() (models.Metric, error) {
+		if i == count {
+			return models.Metric{}, io.EOF
+		}
+		i++
+		if broken && i == count {
+			return models.Metric{}, errors.New("unexpected end of body")
+		}
+		return *models.CreateCounter(fmt.Sprintf("c%04d", i), 1), nil
+	}
+}
+
+func TestImportFailureAppliesNothing(t *testing.T) {
+	store, err := mem.NewStore(files.NewFiler(filepath.Join(t.TempDir(), "dump"), []int{1}), mem.StoreOption{})
+	require.NoError(t, err)
+	fail := false
+	service := NewMetricService(failingBatchStore{store, &fail}, MetricOptions{})
+	ctx := context.Background()
+
+	_, err = service.Import(ctx, importStream(1200, true), ImportMerge)
+	assert.ErrorIs(t, err, ErrInvalidImport)
+	_, err = service.Get(ctx, "c0001")
+	assert.ErrorIs(t, err, ErrMetricNotFound, "metrics read before broken one are not stored")
+
+	fail = true
+	_, err = service.Import(ctx, importStream(1200, false), ImportMerge)
+	assert.Error(t, err)
+	_, err = service.Get(ctx, "c0001")
+	assert.ErrorIs(t, err, ErrMetricNotFound, "failed write stores nothing")
+
+	fail = false
+	count, err := service.Import(ctx, importStream(1200, false), ImportMerge)
+	require.NoError(t, err)
+	assert.Equal(t, 1200, count)
+	for _, id := range []string{"c0001", "c0600", "c1200"} {
+		metric, err := service.Get(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), *metric.Delta, "retried import adds counter once")
+	}
+}
@@ -0,0 +1,4 @@
+DELETE FROM metrics WHERE tenant <> 'default';
+ALTER TABLE metrics DROP CONSTRAINT IF EXISTS metrics_pkey;
+ALTER TABLE metrics DROP COLUMN IF EXISTS tenant;
+ALTER TABLE metrics ADD PRIMARY KEY (id);
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS tokens
\ No newline at end of file
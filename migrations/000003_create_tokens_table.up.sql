@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS tokens(
+    id VARCHAR(32) PRIMARY KEY,
+    tenant VARCHAR(64) NOT NULL,
+    scopes TEXT NOT NULL,
+    hash VARCHAR(64) NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL
+)
\ No newline at end of file
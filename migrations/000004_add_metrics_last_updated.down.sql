@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS metrics_last_updated_idx;
+ALTER TABLE metrics DROP COLUMN IF EXISTS last_updated;
@@ -0,0 +1,2 @@
+ALTER TABLE metrics ADD COLUMN IF NOT EXISTS last_updated TIMESTAMPTZ NOT NULL DEFAULT now();
+CREATE INDEX IF NOT EXISTS metrics_last_updated_idx ON metrics (last_updated);
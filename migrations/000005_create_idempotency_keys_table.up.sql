@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS idempotency_keys(
+    tenant VARCHAR(64) NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (tenant, key)
+);
+CREATE INDEX IF NOT EXISTS idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
@@ -0,0 +1,2 @@
+DELETE FROM metrics WHERE char_length(id) > 25;
+ALTER TABLE metrics ALTER COLUMN id TYPE VARCHAR(25);